@@ -0,0 +1,157 @@
+package config
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AdminConfig 运行时管理接口
+type AdminConfig struct {
+	Addr  string `yaml:"addr"`  //监听地址，例如 127.0.0.1:8081，为空时不启动
+	Token string `yaml:"token"` //POST接口需要的 Authorization: Bearer <token>，为空时不能POST
+}
+
+// adminTable GET /tables 中的一个表
+type adminTable struct {
+	FileName  string    `json:"file_name"`
+	Loaded    bool      `json:"loaded"`
+	Version   uint64    `json:"version,omitzero"`   //发布当前数据的版本
+	LoadTime  time.Time `json:"load_time,omitzero"` //发布当前数据的时间
+	Hash      string    `json:"hash,omitzero"`      //当前数据的sha256
+	Error     string    `json:"error,omitzero"`     //最后一次加载失败的错误，之后加载成功时为空
+	ErrorTime time.Time `json:"error_time,omitzero"`
+}
+
+// adminGeneration GET /generations 中的一个版本
+type adminGeneration struct {
+	Version uint64    `json:"version"`
+	Time    time.Time `json:"time"`
+	Current bool      `json:"current"`
+}
+
+// adminResult POST接口的结果，失败时Errors为每个表的错误
+type adminResult struct {
+	OK     bool     `json:"ok"`
+	Errors []string `json:"errors,omitzero"`
+}
+
+// AdminHandler 运行时查看和管理配置的http接口，例如 http.Handle("/config/", http.StripPrefix("/config", m.AdminHandler(token)))
+//
+//	GET  /tables                  所有注册的表：文件名、发布的版本和时间、sha256、最后一次加载错误
+//	GET  /tables/<文件名>          表的当前数据
+//	GET  /generations             保留的历史版本
+//	GET  /metrics                 Prometheus文本格式的指标
+//	POST /reload[?file=<文件名>]   重新加载，可以有多个file，为空时重新加载所有已经加载的表
+//	POST /validate[?file=<文件名>] 只加载和校验，不发布，为空时检查所有注册的表
+//	POST /rollback?version=<版本>  回滚到历史版本
+//
+// POST接口需要 Authorization: Bearer <token>，token为空时不能POST。
+// 开始监听后reload和rollback在监听goroutine中执行并等待结果，订阅回调和监听触发的重新加载一样在监听goroutine中调用
+func (m *ConfigManager) AdminHandler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /tables", m.adminTables)
+	mux.HandleFunc("GET /tables/{fileName...}", m.adminTable)
+	mux.HandleFunc("GET /generations", m.adminGenerations)
+	mux.Handle("GET /metrics", m.MetricsHandler())
+	mux.Handle("POST /reload", adminAuth(token, func(w http.ResponseWriter, r *http.Request) {
+		fileNames := r.URL.Query()["file"]
+		writeAdminResult(w, m.inWatchLoop(r.Context(), func() error {
+			return m.Reload(fileNames...)
+		}))
+	}))
+	mux.Handle("POST /validate", adminAuth(token, func(w http.ResponseWriter, r *http.Request) {
+		writeAdminResult(w, m.DryRun(r.URL.Query()["file"]...))
+	}))
+	mux.Handle("POST /rollback", adminAuth(token, func(w http.ResponseWriter, r *http.Request) {
+		version, err := strconv.ParseUint(r.URL.Query().Get("version"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid version", http.StatusBadRequest)
+			return
+		}
+		writeAdminResult(w, m.inWatchLoop(r.Context(), func() error {
+			return m.Rollback(version)
+		}))
+	}))
+	return mux
+}
+
+// adminAuth 比较token时不泄露耗时
+func adminAuth(token string, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	})
+}
+
+func (m *ConfigManager) adminTables(w http.ResponseWriter, r *http.Request) {
+	gen := m.Current()
+	tables := m.registered()
+	list := make([]adminTable, len(tables))
+	for i, table := range tables {
+		fileName := table.receiver.GetFileName()
+		item := adminTable{FileName: fileName, Loaded: table.isLoaded(), Hash: gen.Hash(fileName)}
+		if _, ok := gen.Get(fileName); ok {
+			item.Version, item.LoadTime = gen.Published(fileName)
+		}
+		if e := m.loadError(fileName); e.err != nil {
+			item.Error, item.ErrorTime = e.err.Error(), e.time
+		}
+		list[i] = item
+	}
+	writeAdminJSON(w, http.StatusOK, list)
+}
+
+// adminTable 只返回已经发布的数据，不会触发加载
+func (m *ConfigManager) adminTable(w http.ResponseWriter, r *http.Request) {
+	v, ok := m.Current().Get(r.PathValue("fileName"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, v)
+}
+
+func (m *ConfigManager) adminGenerations(w http.ResponseWriter, r *http.Request) {
+	current := m.Current()
+	generations := m.Generations()
+	list := make([]adminGeneration, len(generations))
+	for i, gen := range generations {
+		list[i] = adminGeneration{Version: gen.Version, Time: gen.Time, Current: gen == current}
+	}
+	writeAdminJSON(w, http.StatusOK, list)
+}
+
+func writeAdminResult(w http.ResponseWriter, err error) {
+	if err == nil {
+		writeAdminJSON(w, http.StatusOK, adminResult{OK: true})
+		return
+	}
+	status := http.StatusUnprocessableEntity
+	switch {
+	case errors.Is(err, ErrTableNotFound) || errors.Is(err, ErrGenerationNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		//等待监听goroutine时请求结束，可能仍然会执行
+		status = http.StatusServiceUnavailable
+	}
+	//errors.Join的错误按行分开
+	writeAdminJSON(w, status, adminResult{Errors: strings.Split(err.Error(), "\n")})
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	encoder.Encode(v)
+}
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+const adminTestToken = "secret"
+
+// adminRequest 发送请求，返回状态码和内容
+func adminRequest(t *testing.T, handler http.Handler, method, target, token string) (int, string) {
+	t.Helper()
+	r := httptest.NewRequest(method, target, nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w.Code, w.Body.String()
+}
+
+func TestAdminHandler(t *testing.T) {
+	fsys := fstest.MapFS{"a.json": testFile(1), "b.json": testFile(1)}
+	m := newTestManager(fsys, "a.json", "b.json")
+	if err := m.LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+	first := m.Current().Version
+	fsys["a.json"] = testFile(2)
+	handler := m.AdminHandler(adminTestToken)
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		token      string
+		change     func()
+		wantStatus int
+		wantBody   string //内容中包含
+		wantA      int    //请求后当前版本中a的数据
+	}{
+		{name: "no token", method: "POST", target: "/reload", wantStatus: http.StatusUnauthorized, wantA: 1},
+		{name: "wrong token", method: "POST", target: "/reload", token: "wrong", wantStatus: http.StatusUnauthorized, wantA: 1},
+		{name: "wrong method", method: "GET", target: "/reload", token: adminTestToken, wantStatus: http.StatusMethodNotAllowed, wantA: 1},
+		{name: "post tables", method: "POST", target: "/tables", token: adminTestToken, wantStatus: http.StatusMethodNotAllowed, wantA: 1},
+		{name: "unknown table", method: "POST", target: "/reload?file=c.json", token: adminTestToken, wantStatus: http.StatusNotFound, wantBody: "c.json", wantA: 1},
+		{name: "unknown table data", method: "GET", target: "/tables/c.json", wantStatus: http.StatusNotFound, wantA: 1},
+		{name: "validate unknown table", method: "POST", target: "/validate?file=c.json", token: adminTestToken, wantStatus: http.StatusNotFound, wantA: 1},
+		{name: "validate", method: "POST", target: "/validate", token: adminTestToken, wantStatus: http.StatusOK, wantA: 1},
+		{
+			name: "validate failed", method: "POST", target: "/validate?file=b.json", token: adminTestToken,
+			change: func() { fsys["b.json"] = testFile(-1) }, wantStatus: http.StatusUnprocessableEntity, wantBody: "b.json: after load: negative", wantA: 1,
+		},
+		{name: "reload failed", method: "POST", target: "/reload?file=a.json&file=b.json", token: adminTestToken, wantStatus: http.StatusUnprocessableEntity, wantBody: "b.json", wantA: 2},
+		{
+			name: "reload", method: "POST", target: "/reload", token: adminTestToken,
+			change: func() { fsys["a.json"], fsys["b.json"] = testFile(3), testFile(3) }, wantStatus: http.StatusOK, wantA: 3,
+		},
+		{name: "table data", method: "GET", target: "/tables/a.json", wantStatus: http.StatusOK, wantBody: `"N": 3`, wantA: 3},
+		{name: "invalid version", method: "POST", target: "/rollback?version=x", token: adminTestToken, wantStatus: http.StatusBadRequest, wantA: 3},
+		{name: "unknown version", method: "POST", target: "/rollback?version=100", token: adminTestToken, wantStatus: http.StatusNotFound, wantA: 3},
+		{name: "rollback", method: "POST", target: fmt.Sprintf("/rollback?version=%d", first), token: adminTestToken, wantStatus: http.StatusOK, wantA: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.change != nil {
+				tt.change()
+			}
+			status, body := adminRequest(t, handler, tt.method, tt.target, tt.token)
+			if status != tt.wantStatus || !strings.Contains(body, tt.wantBody) {
+				t.Errorf("got %d %s, want %d %q", status, body, tt.wantStatus, tt.wantBody)
+			}
+			if got := testValue(m.Current(), "a.json"); got != tt.wantA {
+				t.Errorf("a %d, want %d", got, tt.wantA)
+			}
+		})
+	}
+
+	//没有token时不能POST
+	if status, _ := adminRequest(t, m.AdminHandler(""), "POST", "/reload", " "); status != http.StatusUnauthorized {
+		t.Errorf("empty token %d, want %d", status, http.StatusUnauthorized)
+	}
+}
+
+func TestAdminTables(t *testing.T) {
+	fsys := fstest.MapFS{"a.json": testFile(1), "b.json": testFile(1)}
+	m := newTestManager(fsys, "a.json", "b.json")
+	if err := m.LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+	fsys["b.json"] = testFile(-1)
+	m.Reload("b.json")
+	status, body := adminRequest(t, m.AdminHandler(adminTestToken), "GET", "/tables", "")
+	if status != http.StatusOK {
+		t.Fatalf("status %d", status)
+	}
+	var tables []adminTable
+	if err := json.Unmarshal([]byte(body), &tables); err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 2 || tables[0].FileName != "a.json" || tables[1].FileName != "b.json" {
+		t.Fatalf("tables %+v", tables)
+	}
+	for _, table := range tables {
+		if !table.Loaded || table.Version == 0 || table.Hash != m.Current().Hash(table.FileName) {
+			t.Errorf("table %+v", table)
+		}
+	}
+	if tables[0].Error != "" || !strings.Contains(tables[1].Error, "negative") {
+		t.Errorf("errors %q, %q", tables[0].Error, tables[1].Error)
+	}
+}
+
+// TestAdminWatchLoop 开始监听后重新加载在监听goroutine中执行，和监听触发的重新加载串行
+func TestAdminWatchLoop(t *testing.T) {
+	fsys := &lockedFS{fsys: fstest.MapFS{"a.json": testFile(1)}}
+	m, events := startTestWatch(t, WatchConfig{PollInterval: time.Hour}, NewFSSource(fsys), "a.json")
+	handler := m.AdminHandler(adminTestToken)
+
+	//监听goroutine忙时等待
+	release := make(chan struct{})
+	m.requests <- func() { <-release }
+	fsys.set("a.json", `{"N":2}`)
+	done := make(chan int)
+	go func() {
+		status, _ := adminRequest(t, handler, "POST", "/reload", adminTestToken)
+		done <- status
+	}()
+	select {
+	case status := <-done:
+		t.Fatalf("reload finished with %d while the watch loop was busy", status)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if status := <-done; status != http.StatusOK {
+		t.Errorf("status %d", status)
+	}
+	//返回时已经通知订阅者
+	select {
+	case event := <-events:
+		if event.New.(*testData).N != 2 {
+			t.Errorf("event %+v", event)
+		}
+	default:
+		t.Error("subscriber not called before response")
+	}
+
+	//请求结束时不再等待
+	release = make(chan struct{})
+	defer close(release)
+	m.requests <- func() { <-release }
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := httptest.NewRequest("POST", "/reload", nil).WithContext(ctx)
+	r.Header.Set("Authorization", "Bearer "+adminTestToken)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("canceled status %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
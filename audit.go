@@ -0,0 +1,75 @@
+package config
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// auditRecord 审计日志中的一行json
+type auditRecord struct {
+	Time     time.Time `json:"time"`
+	Action   string    `json:"action"` //load：第一次加载，reload：重新加载，delete：文件被删除，rollback：回滚
+	FileName string    `json:"file"`
+	Version  uint64    `json:"version,omitzero"`  //发布的版本，没有发布时为空
+	OldHash  string    `json:"old_hash,omitzero"` //之前数据的sha256
+	NewHash  string    `json:"new_hash,omitzero"` //发布的数据的sha256，没有发布时为空
+	Result   string    `json:"result"`            //ok、failed
+	Error    string    `json:"error,omitzero"`
+	Duration float64   `json:"duration_ms"`
+}
+
+// auditLog 只追加的审计日志，没有设置文件时不记录
+type auditLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// SetAuditLog 把每个表的加载、重新加载和回滚追加到文件，每行一个json，在StartService之前调用
+func (m *ConfigManager) SetAuditLog(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	m.audit.mu.Lock()
+	defer m.audit.mu.Unlock()
+	if m.audit.file != nil {
+		m.audit.file.Close()
+	}
+	m.audit.file = file
+	return nil
+}
+
+// record gen为发布后的版本，没有发布时为空
+func (a *auditLog) record(action, fileName string, old, gen *Generation, elapsed time.Duration, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		return
+	}
+	r := auditRecord{
+		Time:     time.Now(),
+		Action:   action,
+		FileName: fileName,
+		OldHash:  old.Hash(fileName),
+		Result:   "ok",
+		Duration: float64(elapsed.Microseconds()) / 1000,
+	}
+	if gen != nil {
+		r.Version, r.NewHash = gen.Version, gen.Hash(fileName)
+	}
+	if err != nil {
+		r.Result, r.Error = "failed", err.Error()
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		slog.Warn("config audit failed:", "fileName", fileName, "err", err)
+		return
+	}
+	//每条记录一次写入，O_APPEND保证多个进程写入时不交错
+	if _, err := a.file.Write(append(data, '\n')); err != nil {
+		slog.Warn("config audit failed:", "fileName", fileName, "err", err)
+	}
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestAuditLog(t *testing.T) {
+	fsys := fstest.MapFS{"a.json": testFile(1), "b.json": testFile(-1)}
+	m := newTestManager(fsys, "a.json", "b.json")
+	path := filepath.Join(t.TempDir(), "audit.log")
+	//追加到已有的文件
+	if err := os.WriteFile(path, []byte(`{"action":"old"}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetAuditLog(path); err != nil {
+		t.Fatal(err)
+	}
+	defer m.audit.file.Close()
+
+	m.LoadFile(testConfig("a.json"))
+	m.LoadFile(testConfig("b.json"))
+	first := m.Current().Version
+	fsys["a.json"] = testFile(2)
+	m.Reload("a.json", "b.json")
+	m.Rollback(first - 1)
+	delete(fsys, "a.json")
+	m.Reload("a.json")
+
+	hash := func(n int) string {
+		sum := sha256.Sum256(testFile(n).Data)
+		return hex.EncodeToString(sum[:])
+	}
+	want := []auditRecord{
+		{Action: "old"},
+		{Action: "load", FileName: "a.json", Version: 1, NewHash: hash(1), Result: "ok"},
+		//发布了空数据，没有原始数据
+		{Action: "load", FileName: "b.json", Version: 2, Result: "failed", Error: "b.json: after load: negative"},
+		{Action: "reload", FileName: "a.json", Version: 3, OldHash: hash(1), NewHash: hash(2), Result: "ok"},
+		{Action: "reload", FileName: "b.json", Result: "failed", Error: "after load: negative"},
+		{Action: "rollback", FileName: "a.json", Version: 4, OldHash: hash(2), NewHash: hash(1), Result: "ok"},
+		{Action: "delete", FileName: "a.json", OldHash: hash(1), Result: "ok"},
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var got []auditRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var r auditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		if r.Action != "old" && (r.Time.IsZero() || r.Duration < 0) {
+			t.Errorf("record %+v", r)
+		}
+		//时间和耗时每次不同，只比较其他字段
+		r.Time, r.Duration = time.Time{}, 0
+		got = append(got, r)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d records, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("record %d\ngot  %+v\nwant %+v", i, got[i], want[i])
+		}
+	}
+
+	//没有设置文件时不记录
+	m.audit.file.Close()
+	m.audit.file = nil
+	m.Reload("b.json")
+	data, _ := os.ReadFile(path)
+	if n := strings.Count(string(data), "\n"); n != len(want) {
+		t.Errorf("%d lines after closing, want %d", n, len(want))
+	}
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mogebingxue/game_config_manager/datafile"
+)
+
+// 二进制数据格式和写入见datafile，这里只读取
+
+var ErrBinaryCorrupted = errors.New("binary data corrupted")
+
+// ErrBinarySchemaMismatch 二进制文件和生成代码的元数据不一致，需要重新导出
+var ErrBinarySchemaMismatch = errors.New("binary schema hash mismatch")
+
+// ErrBinaryStale 二进制文件不是从当前的json数据导出的，需要重新导出
+var ErrBinaryStale = errors.New("binary source hash mismatch")
+
+// CheckBinarySource 检查二进制文件是否从sourceHash对应的json数据导出，只读取文件头
+func CheckBinarySource(data []byte, sourceHash [sha256.Size]byte) error {
+	//旧格式或无法识别的文件头也需要重新导出
+	if len(data) < datafile.BinaryHeaderSize || string(data[:len(datafile.BinaryMagic)]) != datafile.BinaryMagic {
+		return fmt.Errorf("%w: unknown header", ErrBinaryStale)
+	}
+	if !bytes.Equal(data[len(datafile.BinaryMagic)+4:datafile.BinaryHeaderSize], sourceHash[:]) {
+		return ErrBinaryStale
+	}
+	return nil
+}
+
+// IBinaryConfig 生成代码实现，用于不依赖反射解码二进制数据
+type IBinaryConfig interface {
+	GetSchemaHash() uint32 //元数据hash，和二进制文件不一致时不能解码
+	DecodeBinary(r *BinaryReader)
+}
+
+// BinaryReader 读二进制数据，出错后后续读取都返回零值，最后用Err检查
+type BinaryReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+// NewBinaryReader 检查文件头，schemaHash和文件不一致时返回错误
+func NewBinaryReader(data []byte, schemaHash uint32) (*BinaryReader, error) {
+	if len(data) < datafile.BinaryHeaderSize || string(data[:len(datafile.BinaryMagic)]) != datafile.BinaryMagic {
+		return nil, ErrBinaryCorrupted
+	}
+	if hash := binary.LittleEndian.Uint32(data[len(datafile.BinaryMagic):]); hash != schemaHash {
+		return nil, fmt.Errorf("%w: file %08x, code %08x", ErrBinarySchemaMismatch, hash, schemaHash)
+	}
+	return &BinaryReader{data: data, pos: datafile.BinaryHeaderSize}, nil
+}
+
+func (r *BinaryReader) ReadInt() int {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.data[r.pos:])
+	if n <= 0 {
+		r.err = ErrBinaryCorrupted
+		return 0
+	}
+	r.pos += n
+	return int(v)
+}
+
+func (r *BinaryReader) ReadLen() int {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data[r.pos:])
+	//每个元素至少占1字节，长度不会超过剩余字节数
+	if n <= 0 || v > uint64(len(r.data)-r.pos-n) {
+		r.err = ErrBinaryCorrupted
+		return 0
+	}
+	r.pos += n
+	return int(v)
+}
+
+func (r *BinaryReader) ReadString() string {
+	n := r.ReadLen()
+	if r.err != nil {
+		return ""
+	}
+	s := string(r.data[r.pos : r.pos+n])
+	r.pos += n
+	return s
+}
+
+func (r *BinaryReader) ReadBool() bool {
+	if r.err != nil {
+		return false
+	}
+	if r.pos >= len(r.data) {
+		r.err = ErrBinaryCorrupted
+		return false
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b != 0
+}
+
+// Err 返回读取过程中的错误，数据没有读完也认为是错误
+func (r *BinaryReader) Err() error {
+	if r.err == nil && r.pos != len(r.data) {
+		return ErrBinaryCorrupted
+	}
+	return r.err
+}
+
+// DecodeBinary 解码完整的二进制文件
+func DecodeBinary(data []byte, receiver IBinaryConfig) error {
+	r, err := NewBinaryReader(data, receiver.GetSchemaHash())
+	if err != nil {
+		return err
+	}
+	receiver.DecodeBinary(r)
+	return r.Err()
+}
+
+// BinaryFileName json数据文件对应的二进制文件名
+func BinaryFileName(fileName string) string {
+	return strings.TrimSuffix(fileName, ".json") + ".bin"
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/mogebingxue/game_config_manager/datafile"
+)
+
+// binaryTestTable 二进制测试用的表，相当于生成的代码
+type binaryTestTable struct {
+	N int
+	S string
+}
+
+const binaryTestSchema = 0x01020304
+
+func (cfg *binaryTestTable) GetSchemaHash() uint32 {
+	return binaryTestSchema
+}
+
+func (cfg *binaryTestTable) DecodeBinary(r *BinaryReader) {
+	cfg.N = r.ReadInt()
+	cfg.S = r.ReadString()
+}
+
+// binaryTestConfig 加载到binaryTestTable的表
+type binaryTestConfig string
+
+func (c binaryTestConfig) GetFileName() string {
+	return string(c)
+}
+
+func (c binaryTestConfig) NewResult() interface{} {
+	return new(binaryTestTable)
+}
+
+func binaryTestFile(schemaHash uint32, sourceHash [32]byte, n int, s string) []byte {
+	w := datafile.NewBinaryWriter(schemaHash, sourceHash)
+	w.WriteInt(n)
+	w.WriteString(s)
+	return w.Bytes()
+}
+
+func TestLoadBinary(t *testing.T) {
+	const fileName = "p/T.json"
+	base, overlay := []byte(`{"N":1,"S":"json"}`), []byte(`{"S":"overlay"}`)
+	fresh := datafile.BinarySourceHash(base)
+	oldHeader := append([]byte("GCB1\x04\x03\x02\x01"), binaryTestFile(binaryTestSchema, fresh, 2, "bin")[datafile.BinaryHeaderSize:]...)
+	tests := []struct {
+		name     string
+		files    fstest.MapFS
+		overlays fstest.MapFS
+		want     binaryTestTable
+		wantErr  error
+	}{
+		{
+			name:  "fresh",
+			files: fstest.MapFS{fileName: {Data: base}, "p/T.bin": {Data: binaryTestFile(binaryTestSchema, fresh, 2, "bin")}},
+			want:  binaryTestTable{2, "bin"},
+		},
+		{
+			name:  "stale",
+			files: fstest.MapFS{fileName: {Data: base}, "p/T.bin": {Data: binaryTestFile(binaryTestSchema, datafile.BinarySourceHash([]byte(`{}`)), 2, "bin")}},
+			want:  binaryTestTable{1, "json"},
+		},
+		{
+			name:  "old header",
+			files: fstest.MapFS{fileName: {Data: base}, "p/T.bin": {Data: oldHeader}},
+			want:  binaryTestTable{1, "json"},
+		},
+		{
+			name:  "schema mismatch",
+			files: fstest.MapFS{fileName: {Data: base}, "p/T.bin": {Data: binaryTestFile(binaryTestSchema+1, fresh, 2, "bin")}},
+			want:  binaryTestTable{1, "json"},
+		},
+		{
+			name:     "overlay not in bin",
+			files:    fstest.MapFS{fileName: {Data: base}, "p/T.bin": {Data: binaryTestFile(binaryTestSchema, fresh, 2, "bin")}},
+			overlays: fstest.MapFS{fileName: {Data: overlay}},
+			want:     binaryTestTable{1, "overlay"},
+		},
+		{
+			name:     "overlay in bin",
+			files:    fstest.MapFS{fileName: {Data: base}, "p/T.bin": {Data: binaryTestFile(binaryTestSchema, datafile.BinarySourceHash(base, overlay), 2, "bin")}},
+			overlays: fstest.MapFS{fileName: {Data: overlay}},
+			want:     binaryTestTable{2, "bin"},
+		},
+		{
+			name:  "bin only",
+			files: fstest.MapFS{"p/T.bin": {Data: binaryTestFile(binaryTestSchema, datafile.BinarySourceHash([]byte(`{}`)), 2, "bin")}},
+			want:  binaryTestTable{2, "bin"},
+		},
+		{
+			name:    "bin only corrupted",
+			files:   fstest.MapFS{"p/T.bin": {Data: binaryTestFile(binaryTestSchema, fresh, 2, "bin")[:datafile.BinaryHeaderSize+1]}},
+			wantErr: ErrBinaryCorrupted,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &ConfigManager{base: NewFSSource(tt.files)}
+			if tt.overlays != nil {
+				m.overlays = []Source{NewFSSource(tt.overlays)}
+			}
+			var got binaryTestTable
+			source, _, err := m.loadDataFromFile(fileName, &got)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			//保存到last_good_path的原始数据加载出相同的数据
+			m.history.LastGoodPath = t.TempDir()
+			if err := m.saveLastGood(fileName, source); err != nil {
+				t.Fatal(err)
+			}
+			lastGood, _, err := m.loadLastGood(binaryTestConfig(fileName))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *lastGood.(*binaryTestTable) != tt.want {
+				t.Errorf("last good %+v, want %+v", lastGood, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mogebingxue/game_config_manager"
+	"github.com/mogebingxue/game_config_manager/utils"
+)
+
+// 比较两个版本的元数据，检查不兼容的变化，并扫描受影响的数据
+// 用法：go run ./compat -old <旧元数据目录> -new <新元数据目录>
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run 返回退出码：0没有不兼容的变化，1有不兼容的变化，2参数或读取错误
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("compat", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	oldPath := flags.String("old", "", "旧版本元数据目录")
+	newPath := flags.String("new", "", "新版本元数据目录，默认为conf.yaml的metadata_path")
+	dataPath := flags.String("data", "", "数据目录，默认为conf.yaml的data_path")
+	confPath := flags.String("conf", "./conf.yaml", "配置文件")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *oldPath == "" {
+		flags.Usage()
+		return 2
+	}
+	if *newPath == "" || *dataPath == "" {
+		cfg, err := config.LoadConfig(*confPath)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		if *newPath == "" {
+			*newPath = cfg.MetadataPath
+		}
+		if *dataPath == "" {
+			*dataPath = cfg.DataPath
+		}
+	}
+	oldSchema, err := utils.LoadSchema(*oldPath)
+	if err != nil {
+		fmt.Fprintln(stderr, "load old metadata:", err)
+		return 2
+	}
+	newSchema, err := utils.LoadSchema(*newPath)
+	if err != nil {
+		fmt.Fprintln(stderr, "load new metadata:", err)
+		return 2
+	}
+	files, err := utils.LoadDataDir(*dataPath)
+	if err != nil {
+		fmt.Fprintln(stderr, "load data:", err)
+		return 2
+	}
+
+	changes := utils.DiffSchema(oldSchema, newSchema)
+	breaking := 0
+	for _, c := range changes {
+		if c.Level == utils.BREAKING {
+			breaking++
+		}
+		fmt.Fprintln(stdout, c)
+	}
+	affected := utils.FindAffectedData(oldSchema, changes, files)
+	if len(affected) > 0 {
+		fmt.Fprintln(stdout)
+		fmt.Fprintln(stdout, "affected data:")
+		for _, a := range affected {
+			fmt.Fprintln(stdout, "  ", a)
+		}
+	}
+	fmt.Fprintf(stdout, "\n%d changes, %d breaking, %d affected values\n", len(changes), breaking, len(affected))
+	if breaking > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const compatTestXml = `<conf package="p">
+	<enum name="E">
+		<var name="A" default="1"/>
+		<var name="B" default="2"/>
+	</enum>
+	<table name="T">
+		<var name="E" type="E"/>
+	</table>
+</conf>`
+
+func TestRunExitCode(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return filepath.Dir(path)
+	}
+	oldDir := write("old/p.xml", compatTestXml)
+	compatible := write("compatible/p.xml", strings.Replace(compatTestXml, `<var name="B" default="2"/>`, `<var name="B" default="2"/><var name="C" default="3"/>`, 1))
+	breaking := write("breaking/p.xml", strings.Replace(compatTestXml, `<var name="B" default="2"/>`, ``, 1))
+	invalid := write("invalid/p.xml", `<conf package="p"><table name="T"><var name="E" type="Unknown"/></table></conf>`)
+	dataDir := write("data/p/T.json", `{"E":2}`)
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+		out  string //标准输出中包含的内容
+	}{
+		{"same", []string{"-old", oldDir, "-new", oldDir, "-data", dataDir}, 0, "0 changes, 0 breaking, 0 affected values"},
+		{"compatible", []string{"-old", oldDir, "-new", compatible, "-data", dataDir}, 0, "[compatible] p.E.C added"},
+		{"breaking", []string{"-old", oldDir, "-new", breaking, "-data", dataDir}, 1, "#/E: [breaking] p.E.B removed"},
+		{"no old", []string{"-new", oldDir, "-data", dataDir}, 2, ""},
+		{"invalid metadata", []string{"-old", oldDir, "-new", invalid, "-data", dataDir}, 2, ""},
+		{"unknown flag", []string{"-unknown"}, 2, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if got := run(tt.args, &stdout, &stderr); got != tt.want {
+				t.Fatalf("exit code %d, want %d\nstdout: %s\nstderr: %s", got, tt.want, stdout.String(), stderr.String())
+			}
+			if !strings.Contains(stdout.String(), tt.out) {
+				t.Errorf("stdout %q does not contain %q", stdout.String(), tt.out)
+			}
+		})
+	}
+}
@@ -10,6 +10,20 @@ type Config struct {
 	ConfGoPath   string `yaml:"conf_go_path"`
 	DataPath     string `yaml:"data_path"`
 	MetadataPath string `yaml:"metadata_path"`
+	//overlay数据目录，按顺序合并到data_path的数据上，例如 ./example/overlay/qa/
+	Overlays []string `yaml:"overlays"`
+	//运行时的数据来源，为空时使用data_path目录
+	Source SourceConfig `yaml:"source"`
+	//运行时监听数据目录的方式
+	Watch WatchConfig `yaml:"watch"`
+	//运行时保留的历史版本
+	History HistoryConfig `yaml:"history"`
+	//严格模式，有表加载失败时不启动
+	Strict bool `yaml:"strict"`
+	//运行时管理接口
+	Admin AdminConfig `yaml:"admin"`
+	//运行时审计日志，每次加载、重新加载和回滚追加一行json，为空时不记录
+	AuditLog string `yaml:"audit_log"`
 }
 
 func LoadConfig(filePath string) (*Config, error) {
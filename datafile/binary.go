@@ -0,0 +1,65 @@
+package datafile
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// 二进制数据格式：4字节magic + 4字节元数据hash(小端) + 32字节json数据的sha256 + 按元数据顺序编码的字段
+// int和枚举为zigzag varint，bool为1字节，string为长度+内容，list、map为长度+元素
+const (
+	BinaryMagic      = "GCB2"
+	BinaryHeaderSize = 40
+)
+
+// BinarySourceHash 导出二进制文件的json数据的sha256，base为基础数据的文件内容(不存在时为nil)，
+// overlays为按顺序合并的overlay文件内容；每部分带长度，不同的拆分不会得到相同的hash
+func BinarySourceHash(base []byte, overlays ...[]byte) [sha256.Size]byte {
+	h := sha256.New()
+	for _, part := range append([][]byte{base}, overlays...) {
+		h.Write(binary.LittleEndian.AppendUint64(nil, uint64(len(part))))
+		h.Write(part)
+	}
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	return sum
+}
+
+// BinaryWriter 写二进制数据，由export_bin使用
+type BinaryWriter struct {
+	buf []byte
+}
+
+// NewBinaryWriter sourceHash为BinarySourceHash计算的json数据的hash
+func NewBinaryWriter(schemaHash uint32, sourceHash [sha256.Size]byte) *BinaryWriter {
+	w := &BinaryWriter{}
+	w.buf = append(w.buf, BinaryMagic...)
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, schemaHash)
+	w.buf = append(w.buf, sourceHash[:]...)
+	return w
+}
+
+func (w *BinaryWriter) WriteInt(v int) {
+	w.buf = binary.AppendVarint(w.buf, int64(v))
+}
+
+func (w *BinaryWriter) WriteLen(n int) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(n))
+}
+
+func (w *BinaryWriter) WriteString(s string) {
+	w.WriteLen(len(s))
+	w.buf = append(w.buf, s...)
+}
+
+func (w *BinaryWriter) WriteBool(b bool) {
+	if b {
+		w.buf = append(w.buf, 1)
+	} else {
+		w.buf = append(w.buf, 0)
+	}
+}
+
+func (w *BinaryWriter) Bytes() []byte {
+	return w.buf
+}
@@ -0,0 +1,43 @@
+package datafile
+
+import "testing"
+
+func TestBinarySourceHash(t *testing.T) {
+	base, overlay := []byte(`{"N":1}`), []byte(`{"S":"qa"}`)
+	hash := BinarySourceHash(base, overlay)
+	tests := []struct {
+		name string
+		hash [32]byte
+		same bool
+	}{
+		{"same", BinarySourceHash([]byte(`{"N":1}`), []byte(`{"S":"qa"}`)), true},
+		{"base changed", BinarySourceHash([]byte(`{"N":2}`), overlay), false},
+		{"overlay changed", BinarySourceHash(base, []byte(`{"S":"dev"}`)), false},
+		{"without overlay", BinarySourceHash(base), false},
+		{"split differently", BinarySourceHash([]byte(`{"N":1}{"S"`), []byte(`:"qa"}`)), false},
+		{"no base", BinarySourceHash(nil, base, overlay), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if (tt.hash == hash) != tt.same {
+				t.Errorf("hash equal %v, want %v", tt.hash == hash, tt.same)
+			}
+		})
+	}
+}
+
+func TestBinaryWriter(t *testing.T) {
+	w := NewBinaryWriter(0x01020304, BinarySourceHash([]byte(`{}`)))
+	if got := w.Bytes(); len(got) != BinaryHeaderSize || string(got[:len(BinaryMagic)]) != BinaryMagic {
+		t.Fatalf("header %x", got)
+	}
+	w.WriteInt(-1)
+	w.WriteLen(300)
+	w.WriteString("ab")
+	w.WriteBool(true)
+	//zigzag -1=0x01，300=0xac 0x02，"ab"=长度2+内容，true=1
+	want := []byte{0x01, 0xac, 0x02, 0x02, 'a', 'b', 0x01}
+	if got := w.Bytes()[BinaryHeaderSize:]; string(got) != string(want) {
+		t.Errorf("body %x, want %x", got, want)
+	}
+}
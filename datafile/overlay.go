@@ -0,0 +1,61 @@
+package datafile
+
+import (
+	"maps"
+	"slices"
+)
+
+// overlay数据和基础数据的目录结构相同，加载时按顺序深度合并到基础数据上：
+// 对象逐个字段合并，字段值为null表示删除该字段，对象中 "$replace": true 表示整体替换不再合并；
+// list和其他值整体替换
+const OverlayReplaceKey = "$replace"
+
+// MergeOverlay 把overlay合并到base上，返回合并结果，不修改参数
+func MergeOverlay(base, overlay any) any {
+	overlayMap, ok := overlay.(map[string]any)
+	if !ok {
+		return overlay
+	}
+	baseMap, _ := base.(map[string]any)
+	if replace, _ := overlayMap[OverlayReplaceKey].(bool); replace {
+		baseMap = nil
+	}
+	result := make(map[string]any, len(baseMap)+len(overlayMap))
+	for k, v := range baseMap {
+		result[k] = v
+	}
+	for k, v := range overlayMap {
+		if k == OverlayReplaceKey {
+			continue
+		}
+		if v == nil {
+			delete(result, k)
+			continue
+		}
+		result[k] = MergeOverlay(result[k], v)
+	}
+	return result
+}
+
+// OverlayLeaves 返回overlay修改的字段路径，删除、整体替换的字段和list、标量一样作为叶子
+func OverlayLeaves(overlay map[string]any) [][]string {
+	var leaves [][]string
+	appendOverlayLeaves(nil, overlay, &leaves)
+	return leaves
+}
+
+func appendOverlayLeaves(prefix []string, overlay map[string]any, leaves *[][]string) {
+	for _, k := range slices.Sorted(maps.Keys(overlay)) {
+		if k == OverlayReplaceKey {
+			continue
+		}
+		path := append(slices.Clone(prefix), k)
+		if sub, ok := overlay[k].(map[string]any); ok {
+			if replace, _ := sub[OverlayReplaceKey].(bool); !replace {
+				appendOverlayLeaves(path, sub, leaves)
+				continue
+			}
+		}
+		*leaves = append(*leaves, path)
+	}
+}
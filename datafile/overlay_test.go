@@ -0,0 +1,75 @@
+package datafile
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func jsonValue(t *testing.T, s string) any {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("%s: %v", s, err)
+	}
+	return v
+}
+
+func TestMergeOverlay(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    string
+		overlay string
+		want    string
+	}{
+		{"add field", `{"a":1}`, `{"b":2}`, `{"a":1,"b":2}`},
+		{"replace scalar", `{"a":1,"b":2}`, `{"a":3}`, `{"a":3,"b":2}`},
+		{"null deletes", `{"a":1,"b":2}`, `{"a":null}`, `{"b":2}`},
+		{"null missing field", `{"a":1}`, `{"b":null}`, `{"a":1}`},
+		{"nested merge", `{"s":{"x":1,"y":2}}`, `{"s":{"y":3}}`, `{"s":{"x":1,"y":3}}`},
+		{"nested delete", `{"s":{"x":1,"y":2}}`, `{"s":{"x":null}}`, `{"s":{"y":2}}`},
+		{"list replaced", `{"l":[1,2,3]}`, `{"l":[4]}`, `{"l":[4]}`},
+		{"replace object", `{"s":{"x":1,"y":2}}`, `{"s":{"$replace":true,"z":3}}`, `{"s":{"z":3}}`},
+		{"replace false merges", `{"s":{"x":1}}`, `{"s":{"$replace":false,"z":3}}`, `{"s":{"x":1,"z":3}}`},
+		{"replace top level", `{"a":1,"b":2}`, `{"$replace":true,"c":3}`, `{"c":3}`},
+		{"replace nested null", `{"s":{"x":1}}`, `{"s":{"$replace":true,"x":null}}`, `{"s":{}}`},
+		{"object over scalar", `{"a":1}`, `{"a":{"x":1}}`, `{"a":{"x":1}}`},
+		{"scalar over object", `{"a":{"x":1}}`, `{"a":1}`, `{"a":1}`},
+		{"map rows", `{"m":{"r1":{"x":1},"r2":{"x":2}}}`, `{"m":{"r2":null,"r3":{"x":3}}}`, `{"m":{"r1":{"x":1},"r3":{"x":3}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := jsonValue(t, tt.base)
+			got := MergeOverlay(base, jsonValue(t, tt.overlay))
+			if want := jsonValue(t, tt.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+			//不修改参数
+			if !reflect.DeepEqual(base, jsonValue(t, tt.base)) {
+				t.Errorf("base modified: %v", base)
+			}
+		})
+	}
+}
+
+func TestOverlayLeaves(t *testing.T) {
+	tests := []struct {
+		name    string
+		overlay string
+		want    [][]string
+	}{
+		{"empty", `{}`, nil},
+		{"scalars sorted", `{"b":1,"a":2}`, [][]string{{"a"}, {"b"}}},
+		{"nested", `{"s":{"x":1,"y":null},"l":[1]}`, [][]string{{"l"}, {"s", "x"}, {"s", "y"}}},
+		{"replace is leaf", `{"s":{"$replace":true,"x":1}}`, [][]string{{"s"}}},
+		{"top level replace key skipped", `{"$replace":true,"a":1}`, [][]string{{"a"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := OverlayLeaves(jsonValue(t, tt.overlay).(map[string]any))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
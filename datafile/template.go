@@ -0,0 +1,159 @@
+// Package datafile 数据文件的格式：行继承、overlay合并和二进制文件，运行时(config)和工具(utils)共用，只依赖标准库
+package datafile
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// 数据文件中的行继承：对象中 "$base": "<名字>" 表示继承同一个map中key为该名字的行，
+// 找不到时继承文件顶层 "$templates" 中的模板；模板之间也可以继承。
+// 继承按overlay的规则合并，只需要填写不同的字段，字段为null表示不继承该字段
+const (
+	BaseKey      = "$base"
+	TemplatesKey = "$templates"
+)
+
+// TemplateRef 数据中的一处继承，编辑器用来区分继承和覆盖的字段
+type TemplateRef struct {
+	Path      []string       //继承的对象的路径，list元素为下标
+	Base      string         //$base的值
+	BaseVal   map[string]any //解析后的被继承对象
+	Overrides []string       //对象中自己填写的字段
+	Nulls     []string       //对象中为null的字段，表示不继承，保存时需要写回
+}
+
+// HasTemplates 快速判断json数据中是否用到了继承，没有时不需要解析
+func HasTemplates(data []byte) bool {
+	return bytes.Contains(data, []byte(`"`+BaseKey+`"`)) || bytes.Contains(data, []byte(`"`+TemplatesKey+`"`))
+}
+
+// ResolveTemplates 解析数据中的继承，返回展开后的数据(不含$templates)和所有继承的位置，不修改参数
+func ResolveTemplates(data map[string]any) (map[string]any, []TemplateRef, error) {
+	r := &templateResolver{
+		resolved:  make(map[string]any),
+		resolving: make(map[string]bool),
+	}
+	r.templates, _ = data[TemplatesKey].(map[string]any)
+	root := make(map[string]any, len(data))
+	for k, v := range data {
+		if k != TemplatesKey {
+			root[k] = v
+		}
+	}
+	val, err := r.resolveObject(root, nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return val, r.refs, nil
+}
+
+type templateResolver struct {
+	templates map[string]any
+	resolved  map[string]any  //key：被继承对象的路径
+	resolving map[string]bool //正在解析的路径，用来检查循环继承
+	stack     []string
+	refs      []TemplateRef
+}
+
+// resolveValue siblings为同一个对象中的其他字段，用来查找$base
+func (r *templateResolver) resolveValue(val any, siblings map[string]any, path []string) (any, error) {
+	switch v := val.(type) {
+	case map[string]any:
+		return r.resolveObject(v, siblings, path)
+	case []any:
+		list := make([]any, len(v))
+		for i, item := range v {
+			sub, err := r.resolveValue(item, nil, appendPath(path, strconv.Itoa(i)))
+			if err != nil {
+				return nil, err
+			}
+			list[i] = sub
+		}
+		return list, nil
+	}
+	return val, nil
+}
+
+func (r *templateResolver) resolveObject(obj map[string]any, siblings map[string]any, path []string) (map[string]any, error) {
+	result := make(map[string]any, len(obj))
+	var nulls []string
+	for _, k := range slices.Sorted(maps.Keys(obj)) {
+		if k == BaseKey {
+			continue
+		}
+		if obj[k] == nil {
+			nulls = append(nulls, k)
+		}
+		sub, err := r.resolveValue(obj[k], obj, appendPath(path, k))
+		if err != nil {
+			return nil, err
+		}
+		result[k] = sub
+	}
+	baseName, ok := obj[BaseKey]
+	if !ok {
+		return result, nil
+	}
+	name, ok := baseName.(string)
+	if !ok {
+		return nil, fmt.Errorf("/%s: %s must be string", strings.Join(appendPath(path, BaseKey), "/"), BaseKey)
+	}
+	baseVal, err := r.resolveBase(name, siblings, path)
+	if err != nil {
+		return nil, err
+	}
+	r.refs = append(r.refs, TemplateRef{
+		Path:      path,
+		Base:      name,
+		BaseVal:   baseVal,
+		Overrides: slices.Sorted(maps.Keys(result)),
+		Nulls:     nulls,
+	})
+	return MergeOverlay(baseVal, result).(map[string]any), nil
+}
+
+// resolveBase 先在同一个map中查找，再查找模板
+func (r *templateResolver) resolveBase(name string, siblings map[string]any, path []string) (map[string]any, error) {
+	var basePath []string
+	base, ok := siblings[name]
+	if ok && len(path) > 0 && path[len(path)-1] != name {
+		basePath = appendPath(path[:len(path)-1], name)
+	} else if base, ok = r.templates[name]; ok {
+		basePath, siblings = []string{TemplatesKey, name}, r.templates
+	} else {
+		return nil, fmt.Errorf("/%s: %s %q not found", strings.Join(path, "/"), BaseKey, name)
+	}
+	key := strings.Join(basePath, "/")
+	if val, ok := r.resolved[key]; ok {
+		return val.(map[string]any), nil
+	}
+	if r.resolving[key] {
+		return nil, fmt.Errorf("/%s: %s cycle: %s -> %s", strings.Join(path, "/"), BaseKey, strings.Join(r.stack, " -> "), name)
+	}
+	baseObj, ok := base.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("/%s: %s %q is not object", strings.Join(path, "/"), BaseKey, name)
+	}
+	r.resolving[key] = true
+	r.stack = append(r.stack, name)
+	//被继承对象中的继承位置在解析自身时已经记录，这里不重复记录
+	refs := r.refs
+	val, err := r.resolveObject(baseObj, siblings, basePath)
+	r.refs = refs
+	r.stack = r.stack[:len(r.stack)-1]
+	delete(r.resolving, key)
+	if err != nil {
+		return nil, err
+	}
+	r.resolved[key] = val
+	return val, nil
+}
+
+func appendPath(path []string, name string) []string {
+	return append(slices.Clone(path), name)
+}
@@ -0,0 +1,125 @@
+package datafile
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolveTemplates(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    string
+		wantErr string //错误中包含的内容
+	}{
+		{name: "no templates", data: `{"a":1,"m":{"r":{"x":1}}}`, want: `{"a":1,"m":{"r":{"x":1}}}`},
+		{
+			name: "sibling row",
+			data: `{"m":{"r1":{"x":1,"y":2},"r2":{"$base":"r1","y":3}}}`,
+			want: `{"m":{"r1":{"x":1,"y":2},"r2":{"x":1,"y":3}}}`,
+		},
+		{
+			name: "template",
+			data: `{"m":{"r":{"$base":"T","y":3}},"$templates":{"T":{"x":1,"y":2}}}`,
+			want: `{"m":{"r":{"x":1,"y":3}}}`,
+		},
+		{
+			name: "sibling before template",
+			data: `{"m":{"T":{"x":2},"r":{"$base":"T"}},"$templates":{"T":{"x":1}}}`,
+			want: `{"m":{"T":{"x":2},"r":{"x":2}}}`,
+		},
+		{
+			name: "template chain",
+			data: `{"m":{"r":{"$base":"B","z":3}},"$templates":{"A":{"x":1},"B":{"$base":"A","y":2}}}`,
+			want: `{"m":{"r":{"x":1,"y":2,"z":3}}}`,
+		},
+		{
+			name: "row chain",
+			data: `{"m":{"r1":{"x":1},"r2":{"$base":"r1","y":2},"r3":{"$base":"r2","z":3}}}`,
+			want: `{"m":{"r1":{"x":1},"r2":{"x":1,"y":2},"r3":{"x":1,"y":2,"z":3}}}`,
+		},
+		{
+			name: "null not inherited",
+			data: `{"m":{"r1":{"x":1,"y":2},"r2":{"$base":"r1","y":null}}}`,
+			want: `{"m":{"r1":{"x":1,"y":2},"r2":{"x":1}}}`,
+		},
+		{
+			name: "nested merge",
+			data: `{"m":{"r1":{"s":{"x":1,"y":2},"l":[1,2]},"r2":{"$base":"r1","s":{"y":3},"l":[3]}}}`,
+			want: `{"m":{"r1":{"s":{"x":1,"y":2},"l":[1,2]},"r2":{"s":{"x":1,"y":3},"l":[3]}}}`,
+		},
+		{
+			name: "table inherits template",
+			data: `{"$base":"T","a":2,"$templates":{"T":{"a":1,"b":1}}}`,
+			want: `{"a":2,"b":1}`,
+		},
+		{
+			name: "self falls back to template",
+			data: `{"m":{"T":{"$base":"T","y":2}},"$templates":{"T":{"x":1}}}`,
+			want: `{"m":{"T":{"x":1,"y":2}}}`,
+		},
+		{
+			name: "list element",
+			data: `{"l":[{"$base":"T"}],"$templates":{"T":{"x":1}}}`,
+			want: `{"l":[{"x":1}]}`,
+		},
+		{name: "self not found", data: `{"m":{"r":{"$base":"r"}}}`, wantErr: `/m/r: $base "r" not found`},
+		{name: "not found", data: `{"m":{"r":{"$base":"missing"}}}`, wantErr: `/m/r: $base "missing" not found`},
+		{name: "base not string", data: `{"m":{"r":{"$base":1}}}`, wantErr: `/m/r/$base: $base must be string`},
+		{name: "base not object", data: `{"m":{"r1":1,"r2":{"$base":"r1"}}}`, wantErr: `$base "r1" is not object`},
+		{name: "row cycle", data: `{"m":{"a":{"$base":"b"},"b":{"$base":"a"}}}`, wantErr: `/m/a: $base cycle: b -> a -> b`},
+		{name: "three row cycle", data: `{"m":{"a":{"$base":"c"},"b":{"$base":"a"},"c":{"$base":"b"}}}`, wantErr: `/m/a: $base cycle: c -> b -> a -> c`},
+		{
+			name:    "template cycle",
+			data:    `{"m":{"r":{"$base":"A"}},"$templates":{"A":{"$base":"B"},"B":{"$base":"A"}}}`,
+			wantErr: `$base cycle: A -> B -> A`,
+		},
+		{
+			name:    "template self cycle",
+			data:    `{"m":{"r":{"$base":"A"}},"$templates":{"A":{"$base":"A"}}}`,
+			wantErr: `$base cycle: A -> A`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := jsonValue(t, tt.data).(map[string]any)
+			got, _, err := ResolveTemplates(data)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := jsonValue(t, tt.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+			//不修改参数
+			if !reflect.DeepEqual(data, jsonValue(t, tt.data)) {
+				t.Errorf("data modified: %v", data)
+			}
+		})
+	}
+}
+
+func TestResolveTemplatesRefs(t *testing.T) {
+	data := jsonValue(t, `{
+		"m": {"r1": {"x": 1, "y": 2}, "r2": {"$base": "r1", "y": null, "z": 3}},
+		"l": [{"$base": "T", "x": 5}],
+		"$templates": {"T": {"x": 1}}
+	}`).(map[string]any)
+	_, refs, err := ResolveTemplates(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []TemplateRef{
+		{Path: []string{"l", "0"}, Base: "T", BaseVal: map[string]any{"x": float64(1)}, Overrides: []string{"x"}},
+		{Path: []string{"m", "r2"}, Base: "r1", BaseVal: map[string]any{"x": float64(1), "y": float64(2)}, Overrides: []string{"y", "z"}, Nulls: []string{"y"}},
+	}
+	if !reflect.DeepEqual(refs, want) {
+		t.Errorf("got %+v\nwant %+v", refs, want)
+	}
+}
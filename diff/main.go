@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"os"
+	"strings"
+
+	"github.com/mogebingxue/game_config_manager"
+	"github.com/mogebingxue/game_config_manager/utils"
+)
+
+// 按元数据比较两个版本的数据，用别名输出每个表、每行、每个字段的变化，可以附在版本说明中
+// 用法：go run ./diff -old <旧数据目录> [-new <新数据目录>] [-format text|json|html]
+func main() {
+	oldPath := flag.String("old", "", "旧版本数据目录")
+	newPath := flag.String("new", "", "新版本数据目录，默认为conf.yaml的data_path")
+	format := flag.String("format", "text", "输出格式，text、json或html")
+	confPath := flag.String("conf", "./conf.yaml", "配置文件")
+	flag.Parse()
+	if *oldPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	cfg, err := config.LoadConfig(*confPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if *newPath == "" {
+		*newPath = cfg.DataPath
+	}
+	schema, err := utils.LoadSchema(cfg.MetadataPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "load metadata:", err)
+		os.Exit(2)
+	}
+	oldFiles, err := utils.LoadDataDir(*oldPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "load old data:", err)
+		os.Exit(2)
+	}
+	newFiles, err := utils.LoadDataDir(*newPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "load new data:", err)
+		os.Exit(2)
+	}
+	changes, err := utils.DiffData(schema, oldFiles, newFiles)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	switch *format {
+	case "text":
+		for i, c := range changes {
+			if i > 0 && c.Table != changes[i-1].Table {
+				fmt.Println()
+			}
+			fmt.Println(c)
+		}
+		fmt.Printf("\n%d changes\n", len(changes))
+	case "json":
+		if changes == nil {
+			changes = []utils.DataChange{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "    ")
+		if err := enc.Encode(changes); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	case "html":
+		if err := htmlTmpl.Execute(os.Stdout, groupByTable(changes)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, "unknown format:", *format)
+		os.Exit(2)
+	}
+}
+
+type tableChanges struct {
+	Package string
+	Alias   string
+	Changes []utils.DataChange
+}
+
+func groupByTable(changes []utils.DataChange) []tableChanges {
+	var tables []tableChanges
+	for _, c := range changes {
+		if n := len(tables); n == 0 || tables[n-1].Package != c.Package || tables[n-1].Alias != c.TableAlias {
+			tables = append(tables, tableChanges{Package: c.Package, Alias: c.TableAlias})
+		}
+		tables[len(tables)-1].Changes = append(tables[len(tables)-1].Changes, c)
+	}
+	return tables
+}
+
+var htmlTmpl = template.Must(template.New("diff").Funcs(template.FuncMap{
+	"join": func(path []string) string {
+		return strings.Join(path, " / ")
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>配置变化</title>
+<style>
+table { border-collapse: collapse; margin-bottom: 16px; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+.added { background: #e6ffed; }
+.removed { background: #ffeef0; }
+</style>
+</head>
+<body>
+{{- range .}}
+<h3>{{.Alias}} ({{.Package}})</h3>
+<table>
+<tr><th>字段</th><th>旧值</th><th>新值</th></tr>
+{{- range .Changes}}
+<tr class="{{.Kind}}"><td>{{if .Path}}{{join .Path}}{{else}}{{.Kind}}{{end}}</td><td>{{.Old}}</td><td>{{.New}}</td></tr>
+{{- end}}
+</table>
+{{- else}}
+<p>没有变化</p>
+{{- end}}
+</body>
+</html>
+`))
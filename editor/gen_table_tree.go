@@ -1,20 +1,25 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"github.com/mogebingxue/game_config_manager/datafile"
 	"github.com/mogebingxue/game_config_manager/utils"
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 )
 
-func GenTableTrees(confMap map[string]*utils.Conf, alltypMap map[string]map[string]utils.Meta, dataMap map[string]map[string]map[string]any) {
-	for pack, conf := range confMap {
+func GenTableTrees(schema *utils.Schema, dataMap map[string]map[string]map[string]any) {
+	for _, pack := range schema.Packages() {
 		tableTree := make(map[string]*TableTree)
-		typMap := alltypMap[pack]
-		for _, table := range conf.Tables {
+		typMap := schema.TypeMap(pack)
+		for _, table := range schema.Tables(pack) {
 			tableTree[table.Name] = InitTableTree(pack, utils.Table(table), typMap)
+			tableTree[table.Name].Consts = schema.Consts(pack)
 		}
 		tableTreeMap[pack] = tableTree
 	}
@@ -28,13 +33,105 @@ func GenTableTrees(confMap map[string]*utils.Conf, alltypMap map[string]map[stri
 			if !ok {
 				continue
 			}
-			typMap := alltypMap[pack]
 			for _, node := range tableTree.Nodes {
 				val, ok := tableData[node.Name]
 				if !ok {
 					continue
 				}
-				FillNodeData(node, typMap, val)
+				FillNodeData(node, tableTree.TypMap, val)
+			}
+		}
+	}
+}
+
+// MarkOverlays 标记来自overlay的节点，overlay修改的是list元素或者删除了字段时标记到上层节点
+func MarkOverlays(baseMap map[string]map[string]map[string]any, leafMap map[string]map[string][]OverlayLeaf) {
+	for pack, tableLeaves := range leafMap {
+		for table, leaves := range tableLeaves {
+			tableTree, ok := tableTreeMap[pack][table]
+			if !ok {
+				continue
+			}
+			tableTree.OverlayBase = baseMap[pack][table]
+			tableTree.OverlayLeaves = leaves
+			for _, leaf := range leaves {
+				if node, _ := findNode(tableTree.Nodes, leaf.Path); node != nil {
+					markOverlay(node, leaf.Overlay)
+				}
+			}
+		}
+	}
+}
+
+// markOverlay 整个子树都来自overlay
+func markOverlay(node *TreeNode, overlay string) {
+	if node == nil {
+		return
+	}
+	node.Overlay = overlay
+	for _, child := range node.Nodes {
+		markOverlay(child, overlay)
+	}
+	if list, ok := node.Val.([]*TreeNode); ok {
+		for _, child := range list {
+			markOverlay(child, overlay)
+		}
+	}
+}
+
+// findNode 按字段路径查找最深的节点，路径全部找到时返回true
+func findNode(nodes []*TreeNode, path []string) (*TreeNode, bool) {
+	var found *TreeNode
+	for _, name := range path {
+		var next *TreeNode
+		for _, node := range nodes {
+			if node != nil && node.Name == name {
+				next = node
+			}
+		}
+		//map的子节点按key查找，list的子节点按下标查找
+		if found != nil && (found.Typ == "map" || found.Typ == "list") {
+			list, _ := found.Val.([]*TreeNode)
+			for i, node := range list {
+				if (found.Typ == "map" && node.Key == name) || (found.Typ == "list" && strconv.Itoa(i) == name) {
+					next = node
+				}
+			}
+		}
+		if next == nil {
+			return found, false
+		}
+		found, nodes = next, next.Nodes
+	}
+	return found, true
+}
+
+// MarkTemplates 标记行继承，继承的字段和覆盖的字段在编辑器中区分显示
+func MarkTemplates(templateMap map[string]map[string]*TemplateInfo) {
+	for pack, tableTemplates := range templateMap {
+		for table, info := range tableTemplates {
+			tableTree, ok := tableTreeMap[pack][table]
+			if !ok {
+				continue
+			}
+			tableTree.Templates = info.Templates
+			for _, ref := range info.Refs {
+				nodes := tableTree.Nodes
+				if len(ref.Path) == 0 {
+					tableTree.Base, tableTree.BaseVal, tableTree.BaseNulls = ref.Base, ref.BaseVal, ref.Nulls
+				} else {
+					node, ok := findNode(tableTree.Nodes, ref.Path)
+					if !ok {
+						continue
+					}
+					node.Base, node.BaseVal, node.BaseNulls = ref.Base, ref.BaseVal, ref.Nulls
+					nodes = node.Nodes
+				}
+				for _, child := range nodes {
+					if child != nil && !slices.Contains(ref.Overrides, child.Name) {
+						child.Inherited = true
+					}
+				}
 			}
 		}
 	}
@@ -45,6 +142,7 @@ func InitTableTree(pack string, table utils.Table, typMap map[string]utils.Meta)
 	tableTree.Pack = pack
 	tableTree.Name = table.Name
 	tableTree.Alias = table.Alias
+	tableTree.TypMap = typMap
 	tableTree.Nodes = make([]*TreeNode, len(table.Vars))
 	for i, structVar := range table.Vars {
 		tableTree.Nodes[i] = InitTableNode(1, structVar, typMap)
@@ -61,10 +159,14 @@ func InitTableNode(level int, structVar utils.StructVar, typMap map[string]utils
 	treeNode.Alias = structVar.Alias
 	treeNode.Level = level + 1
 	treeNode.Typ = structVar.Typ
+	treeNode.Min = structVar.Min
+	treeNode.Max = structVar.Max
+	treeNode.Annotations = structVar.Annotations
 	switch structVar.Typ {
 	case "int", "string", "bool":
 	case "list", "map":
 		treeNode.ValTyp = structVar.ValueType
+		treeNode.KeyType = structVar.KeyType
 		switch structVar.ValueType {
 		case "", "list", "map":
 			return nil
@@ -124,8 +226,9 @@ func FillNodeData(node *TreeNode, typMap map[string]utils.Meta, val any) {
 			return
 		}
 		if meta.Typ == utils.STRUCT {
+			valMap, _ := val.(map[string]any)
 			for _, subNode := range node.Nodes {
-				valSub, ok := val.(map[string]any)[subNode.Name]
+				valSub, ok := valMap[subNode.Name]
 				if !ok {
 					continue
 				}
@@ -144,13 +247,16 @@ func FillNodeData(node *TreeNode, typMap map[string]utils.Meta, val any) {
 }
 
 func FillList(node *TreeNode, typMap map[string]utils.Meta, val any) {
-	list := val.([]any)
+	//类型不符时当作空列表，用validate命令检查数据
+	list, _ := val.([]any)
 	nodeList := make([]*TreeNode, len(list))
 	for i, _ := range list {
 		structVar := utils.StructVar{
 			Name:  fmt.Sprintf("%s_%d", node.Name, i),
 			Alias: fmt.Sprintf("%s_%d", node.Alias, i),
 			Typ:   node.ValTyp,
+			Min:   node.Min,
+			Max:   node.Max,
 		}
 		nodeList[i] = InitTableNode(node.Level, structVar, typMap)
 		FillNodeData(nodeList[i], typMap, list[i])
@@ -159,14 +265,16 @@ func FillList(node *TreeNode, typMap map[string]utils.Meta, val any) {
 }
 
 func FillMap(node *TreeNode, typMap map[string]utils.Meta, val any) {
-	valMap := val.(map[string]any)
+	valMap, _ := val.(map[string]any)
 	nodeList := make([]*TreeNode, len(valMap))
 	i := 0
-	for k, _ := range valMap {
+	for _, k := range utils.SortedKeys(valMap) {
 		structVar := utils.StructVar{
 			Name:  fmt.Sprintf("%s_%d", node.Name, i),
 			Alias: fmt.Sprintf("%s_%d", node.Alias, i),
 			Typ:   node.ValTyp,
+			Min:   node.Min,
+			Max:   node.Max,
 		}
 		nodeList[i] = InitTableNode(node.Level, structVar, typMap)
 		FillNodeData(nodeList[i], typMap, valMap[k])
@@ -179,10 +287,19 @@ func FillMap(node *TreeNode, typMap map[string]utils.Meta, val any) {
 var tableTreeMap = make(map[string]map[string]*TableTree)
 
 type TableTree struct {
-	Pack  string
-	Name  string
-	Alias string
-	Nodes []*TreeNode
+	Pack   string
+	Name   string
+	Alias  string
+	Nodes  []*TreeNode
+	TypMap map[string]utils.Meta //所在包的类型表
+	Consts []utils.Const         //所在包的常量，只读展示
+	//有overlay时为合并前的基础数据，保存时overlay修改的字段还原为基础数据
+	OverlayBase   map[string]any
+	OverlayLeaves []OverlayLeaf
+	Templates     any            //数据文件中的$templates，保存时原样写回
+	Base          string         //整个表继承的模板
+	BaseVal       map[string]any //解析后的被继承对象
+	BaseNulls     []string       //为null不继承的字段
 }
 
 type TreeNode struct {
@@ -194,7 +311,18 @@ type TreeNode struct {
 	Val     any
 	Nodes   []*TreeNode
 	Key     string //map类型的子节点的key
+	KeyType string //map的key类型
 	Deleted bool   //标记list元素被删除
+	Min     string //int的最小值，数字或常量名
+	Max     string //int的最大值，数字或常量名
+	Overlay string //值来自的overlay，空表示来自基础数据
+	//行继承，Base为继承的行或模板名，保存时只写和被继承对象不同的字段
+	Base      string
+	BaseVal   map[string]any
+	BaseNulls []string //为null不继承的字段
+	Inherited bool     //值继承自上层对象的$base
+	//自定义属性，支持 editor:widget="multiline" 多行输入，editor:readonly="true" 只读
+	Annotations utils.Annotations
 }
 
 func (t *TableTree) Save() error {
@@ -218,7 +346,7 @@ func (t *TableTree) Save() error {
 func (t *TableTree) CheckCanSave() error {
 	// todo check合法性
 	for _, node := range t.Nodes {
-		if err := node.CheckCanSave(utils.AllTypMap[t.Pack]); err != nil {
+		if err := node.CheckCanSave(t.TypMap); err != nil {
 			return err
 		}
 	}
@@ -228,13 +356,76 @@ func (t *TableTree) CheckCanSave() error {
 func (t *TableTree) ToJson() []byte {
 	m := make(map[string]any)
 	for _, node := range t.Nodes {
-		nodeVal, ok := node.ToJson(utils.AllTypMap[t.Pack])
+		nodeVal, ok := node.ToJson(t.TypMap)
 		if nodeVal != nil && ok {
 			m[node.Name] = nodeVal
 		}
 	}
-	data, _ := json.MarshalIndent(m, "", "    ")
-	return data
+	if t.Base != "" {
+		stripInherited(t.Base, t.BaseVal, m)
+		keepNulls(t.BaseNulls, t.Nodes, m)
+	}
+	if t.Templates != nil {
+		m[datafile.TemplatesKey] = t.Templates
+	}
+	t.restoreBase(m)
+	//和fmt命令使用相同的格式
+	meta, ok := t.TypMap[t.Name]
+	if !ok || meta.Typ != utils.TABLE {
+		data, _ := json.MarshalIndent(m, "", "    ")
+		return data
+	}
+	return utils.FormatData(t.TypMap, meta.Meta.(*utils.Struct), m)
+}
+
+// restoreBase overlay修改的字段还原为基础数据，避免把overlay的值写进基础数据
+func (t *TableTree) restoreBase(m map[string]any) {
+	for _, leaf := range t.OverlayLeaves {
+		if val, ok := utils.GetPath(t.OverlayBase, leaf.Path); ok {
+			utils.SetPath(m, leaf.Path, val)
+		} else {
+			utils.DeletePath(m, leaf.Path)
+		}
+	}
+}
+
+// stripInherited 去掉和被继承对象相同的字段，只保存覆盖的字段
+func stripInherited(base string, baseVal map[string]any, m map[string]any) {
+	for k, v := range m {
+		if baseV, ok := baseVal[k]; ok && sameJson(v, baseV) {
+			delete(m, k)
+		}
+	}
+	m[datafile.BaseKey] = base
+}
+
+// keepNulls 为null不继承的字段在编辑器中仍然没有填写时写回null，否则保存后会重新继承
+func keepNulls(nulls []string, nodes []*TreeNode, m map[string]any) {
+	for _, node := range nodes {
+		if node != nil && slices.Contains(nulls, node.Name) && node.isEmpty() {
+			m[node.Name] = nil
+		}
+	}
+}
+
+// isEmpty 节点和子节点都没有值
+func (n *TreeNode) isEmpty() bool {
+	if n.Val != nil {
+		return false
+	}
+	for _, child := range n.Nodes {
+		if child != nil && !child.isEmpty() {
+			return false
+		}
+	}
+	return true
+}
+
+// sameJson 编辑器中int是int，json中是float64，按json比较
+func sameJson(a, b any) bool {
+	dataA, errA := json.Marshal(a)
+	dataB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(dataA, dataB)
 }
 
 func (n *TreeNode) ToJson(typMap map[string]utils.Meta) (any, bool) {
@@ -261,14 +452,15 @@ func (n *TreeNode) ToJson(typMap map[string]utils.Meta) (any, bool) {
 				return nil, false
 			}
 			if meta.Typ == utils.STRUCT {
-				for i, child := range valList {
-					childNode, ok := child.(*TreeNode)
-					if !ok {
-						return nil, false
+				//结构体元素的值在子节点中
+				valList = valList[:0]
+				for _, v := range list {
+					if v.Deleted {
+						continue
 					}
-					nodeVal, ok := childNode.ToJson(typMap)
+					nodeVal, ok := v.ToJson(typMap)
 					if nodeVal != nil && ok {
-						valList[i] = nodeVal
+						valList = append(valList, nodeVal)
 					}
 				}
 				return valList, true
@@ -296,14 +488,14 @@ func (n *TreeNode) ToJson(typMap map[string]utils.Meta) (any, bool) {
 				return nil, false
 			}
 			if meta.Typ == utils.STRUCT {
-				for k, child := range valMap {
-					childNode, ok := child.(*TreeNode)
-					if !ok {
-						return nil, false
+				//结构体元素的值在子节点中
+				for _, v := range nodeList {
+					if v.Deleted || v.Key == "" {
+						continue
 					}
-					nodeVal, ok := childNode.ToJson(typMap)
+					nodeVal, ok := v.ToJson(typMap)
 					if nodeVal != nil && ok {
-						valMap[k] = nodeVal
+						valMap[v.Key] = nodeVal
 					}
 				}
 				return valMap, true
@@ -324,6 +516,10 @@ func (n *TreeNode) ToJson(typMap map[string]utils.Meta) (any, bool) {
 					subNodes[child.Name] = nodeVal
 				}
 			}
+			if n.Base != "" {
+				stripInherited(n.Base, n.BaseVal, subNodes)
+				keepNulls(n.BaseNulls, n.Nodes, subNodes)
+			}
 			return subNodes, true
 		} else if meta.Typ == utils.ENUM {
 			return n.Val, true
@@ -334,10 +530,23 @@ func (n *TreeNode) ToJson(typMap map[string]utils.Meta) (any, bool) {
 
 func (n *TreeNode) CheckCanSave(typMap map[string]utils.Meta) error {
 	switch n.Typ {
-	case "int", "string", "bool":
+	case "int":
+		return n.CheckBound(typMap)
+	case "string", "bool":
 		return nil
 	case "list":
-		return nil
+		nodeList, ok := n.Val.([]*TreeNode)
+		if !ok {
+			return nil
+		}
+		for _, v := range nodeList {
+			if v.Deleted {
+				continue
+			}
+			if err := v.CheckCanSave(typMap); err != nil {
+				return err
+			}
+		}
 	case "map":
 		nodeList, ok := n.Val.([]*TreeNode)
 		if !ok {
@@ -345,7 +554,13 @@ func (n *TreeNode) CheckCanSave(typMap map[string]utils.Meta) error {
 		}
 		visit := make(map[string]bool)
 		for _, v := range nodeList {
-			if _, ok := visit[v.Key]; !ok {
+			if v.Deleted {
+				continue
+			}
+			if err := utils.CheckMapKey(n.KeyType, v.Key); err != nil {
+				return fmt.Errorf("%s: %w", n.Alias, err)
+			}
+			if visit[v.Key] {
 				return fmt.Errorf("%s has duplicate key:%s", n.Alias, v.Key)
 			}
 			if err := v.CheckCanSave(typMap); err != nil {
@@ -371,3 +586,26 @@ func (n *TreeNode) CheckCanSave(typMap map[string]utils.Meta) error {
 	}
 	return nil
 }
+
+// CheckBound 检查int是否在min、max范围内
+func (n *TreeNode) CheckBound(typMap map[string]utils.Meta) error {
+	val, ok := n.Val.(int)
+	if !ok {
+		return nil
+	}
+	minVal, hasMin, err := utils.ResolveInt(typMap, n.Min)
+	if err != nil {
+		return fmt.Errorf("%s min: %w", n.Alias, err)
+	}
+	if hasMin && val < minVal {
+		return fmt.Errorf("%s(%s) less than min %s(%d)", n.Alias, n.Name, n.Min, minVal)
+	}
+	maxVal, hasMax, err := utils.ResolveInt(typMap, n.Max)
+	if err != nil {
+		return fmt.Errorf("%s max: %w", n.Alias, err)
+	}
+	if hasMax && val > maxVal {
+		return fmt.Errorf("%s(%s) greater than max %s(%d)", n.Alias, n.Name, n.Max, maxVal)
+	}
+	return nil
+}
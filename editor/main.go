@@ -28,17 +28,27 @@ func main() {
 		fmt.Println(err)
 		return
 	}
-	err = utils.LoadAllConfigs(cfg.MetadataPath)
+	schema, err := utils.LoadSchema(cfg.MetadataPath)
 	if err != nil {
+		fmt.Println(err)
 		return
 	}
 	err = LoadAllJsonData(cfg.DataPath)
 	if err != nil {
 		return
 	}
-	utils.WaitLoadConfMap()
 	WaitLoadJsonDataMap()
-	GenTableTrees(utils.GetConfMap(), utils.AllTypMap, jsonDataMap)
+	if err := ApplyOverlays(cfg.Overlays); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if err := ResolveAllTemplates(); err != nil {
+		fmt.Println(err)
+		return
+	}
+	GenTableTrees(schema, jsonDataMap)
+	MarkOverlays(baseJsonDataMap, overlayLeafMap)
+	MarkTemplates(templateMap)
 	a := app.New()
 	w := a.NewWindow("Config Editor")
 	TableDisplay := container.NewVBox()
@@ -57,7 +67,7 @@ func main() {
 					return
 				}
 			})),
-			InitSelect(TableDisplay)),
+			InitSelect(schema, TableDisplay)),
 		nil, nil, nil,
 		TableDisplayScroll,
 	)
@@ -69,7 +79,7 @@ func main() {
 
 var selectTable *TableTree
 
-func InitSelect(tableDisplay *fyne.Container) *fyne.Container {
+func InitSelect(schema *utils.Schema, tableDisplay *fyne.Container) *fyne.Container {
 	packSelect := widget.NewSelect([]string{}, nil)
 	tableSelect := widget.NewSelect([]string{}, nil)
 	packSelect.PlaceHolder = "请选择包"
@@ -78,9 +88,12 @@ func InitSelect(tableDisplay *fyne.Container) *fyne.Container {
 	var pkgArr []string
 	alias2pkg := make(map[string]string)
 	for k := range tableTreeMap {
-		cm := utils.GetConfMap()
-		alias2pkg[cm[k].Alias] = k
-		pkgArr = append(pkgArr, cm[k].Alias)
+		conf, ok := schema.Package(k)
+		if !ok {
+			continue
+		}
+		alias2pkg[conf.Alias] = k
+		pkgArr = append(pkgArr, conf.Alias)
 	}
 	sort.Strings(pkgArr)
 	packSelect.Options = pkgArr
@@ -107,6 +120,7 @@ func InitSelect(tableDisplay *fyne.Container) *fyne.Container {
 		tableSelect.Options = aliasArr
 		tableSelect.ClearSelected()
 		tableDisplay.RemoveAll()
+		AddConsts(schema.Consts(alias2pkg[selected]), tableDisplay)
 		tableDisplay.Refresh()
 	}
 
@@ -117,8 +131,7 @@ func InitSelect(tableDisplay *fyne.Container) *fyne.Container {
 		}
 
 		if table, exists := tableTreeMap[alias2pkg[packSelect.Selected]][selectTableMap[selected]]; exists {
-			typMap := utils.AllTypMap[alias2pkg[packSelect.Selected]]
-			OnSelectTable(table, typMap, tableDisplay)
+			OnSelectTable(table, table.TypMap, tableDisplay)
 		}
 	}
 	return container.NewHBox(packSelect, tableSelect)
@@ -126,12 +139,35 @@ func InitSelect(tableDisplay *fyne.Container) *fyne.Container {
 
 func OnSelectTable(table *TableTree, typMap map[string]utils.Meta, tableDisplay *fyne.Container) {
 	tableDisplay.RemoveAll()
+	AddConsts(table.Consts, tableDisplay)
 	for _, child := range table.Nodes {
 		AddNode(child, typMap, tableDisplay)
 	}
 	selectTable = table
 	tableDisplay.Refresh()
 }
+
+// AddConsts 只读展示包内的常量
+func AddConsts(consts []utils.Const, c *fyne.Container) {
+	if len(consts) == 0 {
+		return
+	}
+	titleContainer := container.NewHBox()
+	titleContainer.Add(widget.NewLabel("常量(只读):"))
+	contentContainer := container.NewVBox()
+	for _, v := range consts {
+		constContainer := container.NewGridWithColumns(4)
+		constContainer.Add(widget.NewLabel(fmt.Sprintf("%s(%s):", v.Alias, v.Name)))
+		constContainer.Add(widget.NewLabel(v.Typ))
+		constContainer.Add(widget.NewLabel(v.Value))
+		contentContainer.Add(constContainer)
+	}
+	editBtn := AddEditBtn("常量", titleContainer, contentContainer, nil)
+	editBtn.SetText("查看")
+	titleContainer.Add(editBtn)
+	c.Add(titleContainer)
+}
+
 func AddNode(node *TreeNode, typMap map[string]utils.Meta, c *fyne.Container) *fyne.Container {
 	contentContainer := container.NewGridWithColumns(4)
 	switch node.Typ {
@@ -162,7 +198,7 @@ func AddNode(node *TreeNode, typMap map[string]utils.Meta, c *fyne.Container) *f
 
 func AddList(node *TreeNode, typMap map[string]utils.Meta, nodeContainer *fyne.Container) {
 	titleContainer := container.NewHBox()
-	titleContainer.Add(widget.NewLabel(fmt.Sprintf("%s(%s):", node.Alias, node.Name)))
+	titleContainer.Add(widget.NewLabel(NodeLabel(node)))
 	contentContainer := container.NewVBox()
 	var addBtn *widget.Button
 	list, ok := node.Val.([]*TreeNode)
@@ -189,6 +225,8 @@ func AddList(node *TreeNode, typMap map[string]utils.Meta, nodeContainer *fyne.C
 			Name:  fmt.Sprintf("%s_%d", node.Name, index),
 			Alias: fmt.Sprintf("%s_%d", node.Alias, index),
 			Typ:   node.ValTyp,
+			Min:   node.Min,
+			Max:   node.Max,
 		}
 		addNode := InitTableNode(node.Level, addStructVar, typMap)
 		FillNodeData(addNode, typMap, nil)
@@ -213,7 +251,7 @@ func AddList(node *TreeNode, typMap map[string]utils.Meta, nodeContainer *fyne.C
 
 func AddMap(node *TreeNode, typMap map[string]utils.Meta, nodeContainer *fyne.Container) {
 	titleContainer := container.NewHBox()
-	titleContainer.Add(widget.NewLabel(fmt.Sprintf("%s(%s):", node.Alias, node.Name)))
+	titleContainer.Add(widget.NewLabel(NodeLabel(node)))
 	contentContainer := container.NewVBox()
 	var addBtn *widget.Button
 	list, ok := node.Val.([]*TreeNode)
@@ -241,6 +279,8 @@ func AddMap(node *TreeNode, typMap map[string]utils.Meta, nodeContainer *fyne.Co
 			Name:  fmt.Sprintf("%s_%d", node.Name, index),
 			Alias: fmt.Sprintf("%s_%d", node.Alias, index),
 			Typ:   node.ValTyp,
+			Min:   node.Min,
+			Max:   node.Max,
 		}
 		addNode := InitTableNode(node.Level, addStructVar, typMap)
 		FillNodeData(addNode, typMap, nil)
@@ -303,13 +343,16 @@ func AddEnum(node *TreeNode, meta utils.Meta, nodeContainer *fyne.Container) {
 	if val > 0 && ok {
 		enumSelect.Selected = selectedEnum
 	}
-	nodeContainer.Add(widget.NewLabel(fmt.Sprintf("%s(%s):", node.Alias, node.Name)))
+	if IsReadonly(node) {
+		enumSelect.Disable()
+	}
+	nodeContainer.Add(widget.NewLabel(NodeLabel(node)))
 	nodeContainer.Add(enumSelect)
 }
 
 func AddStruct(node *TreeNode, typMap map[string]utils.Meta, nodeContainer *fyne.Container) {
 	titleContainer := container.NewHBox()
-	titleContainer.Add(widget.NewLabel(fmt.Sprintf("%s(%s):", node.Alias, node.Name)))
+	titleContainer.Add(widget.NewLabel(NodeLabel(node)))
 	contentContainer := container.NewVBox()
 	editBtn := AddEditBtn(node.Alias, titleContainer, contentContainer, nil)
 	titleContainer.Add(editBtn)
@@ -325,7 +368,7 @@ func AddBool(node *TreeNode, nodeContainer *fyne.Container) {
 		val = false
 	}
 	isTrueStr := "布尔值"
-	nodeContainer.Add(widget.NewLabel(fmt.Sprintf("%s(%s):", node.Alias, node.Name)))
+	nodeContainer.Add(widget.NewLabel(NodeLabel(node)))
 	radio := widget.NewRadioGroup([]string{isTrueStr}, func(text string) {
 		if text == isTrueStr {
 			node.Val = true
@@ -336,6 +379,9 @@ func AddBool(node *TreeNode, nodeContainer *fyne.Container) {
 	if val {
 		radio.Selected = isTrueStr
 	}
+	if IsReadonly(node) {
+		radio.Disable()
+	}
 	nodeContainer.Add(radio)
 }
 
@@ -344,12 +390,19 @@ func AddString(node *TreeNode, nodeContainer *fyne.Container) {
 	if !ok {
 		val = ""
 	}
-	nodeContainer.Add(widget.NewLabel(fmt.Sprintf("%s(%s):", node.Alias, node.Name)))
+	nodeContainer.Add(widget.NewLabel(NodeLabel(node)))
 	input := widget.NewEntryWithData(binding.BindString(&val))
+	if node.Annotations.Get("editor:widget") == "multiline" {
+		input = widget.NewMultiLineEntry()
+		input.Bind(binding.BindString(&val))
+	}
 	input.SetPlaceHolder("enter string")
 	input.OnChanged = func(text string) {
 		node.Val = text
 	}
+	if IsReadonly(node) {
+		input.Disable()
+	}
 	nodeContainer.Add(input)
 }
 
@@ -358,7 +411,7 @@ func AddInt(node *TreeNode, nodeContainer *fyne.Container) {
 	if !ok {
 		val = 0
 	}
-	nodeContainer.Add(widget.NewLabel(fmt.Sprintf("%s(%s):", node.Alias, node.Name)))
+	nodeContainer.Add(widget.NewLabel(NodeLabel(node)))
 	input := widget.NewEntryWithData(binding.IntToString(binding.BindInt(&val)))
 	input.SetPlaceHolder("enter int")
 	input.OnChanged = func(text string) {
@@ -368,9 +421,35 @@ func AddInt(node *TreeNode, nodeContainer *fyne.Container) {
 		}
 		node.Val = num
 	}
+	if IsReadonly(node) {
+		input.Disable()
+	}
 	nodeContainer.Add(input)
 }
 
+// NodeLabel 字段名，int显示取值范围，来自overlay的值显示overlay名
+func NodeLabel(node *TreeNode) string {
+	label := fmt.Sprintf("%s(%s)", node.Alias, node.Name)
+	if node.Typ == "int" && (node.Min != "" || node.Max != "") {
+		label += fmt.Sprintf("[%s,%s]", node.Min, node.Max)
+	}
+	if node.Overlay != "" {
+		label += fmt.Sprintf("[覆盖:%s]", node.Overlay)
+	}
+	if node.Base != "" {
+		label += fmt.Sprintf("[继承:%s]", node.Base)
+	}
+	if node.Inherited {
+		label += "(继承)"
+	}
+	return label + ":"
+}
+
+// IsReadonly 来自overlay的值在overlay文件中修改，编辑器里只读
+func IsReadonly(node *TreeNode) bool {
+	return node.Overlay != "" || node.Annotations.Get("editor:readonly") == "true"
+}
+
 func AddEditBtn(title string, titleContainer, content *fyne.Container, addBtn *widget.Button) *widget.Button {
 	var editBtn *widget.Button
 
@@ -2,6 +2,9 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
+	"github.com/mogebingxue/game_config_manager/datafile"
+	"github.com/mogebingxue/game_config_manager/utils"
 	"io"
 	"log/slog"
 	"os"
@@ -30,7 +33,7 @@ func LoadAllJsonData(path string) error {
 		pack := filepath.Base(filepath.Dir(path))
 		go func() {
 			data := LoadJsonData(path)
-			slog.Debug("LoadData", data)
+			slog.Debug("LoadData", "data", data)
 			jsonDataMapMutex.Lock()
 			if jsonDataMap[pack] == nil {
 				jsonDataMap[pack] = make(map[string]map[string]any)
@@ -59,20 +62,85 @@ var jsonDataMap = make(map[string]map[string]map[string]any)
 func LoadJsonData(path string) map[string]any {
 	jsonFile, err := os.Open(path)
 	if err != nil {
-		slog.Error("Error opening input file:", err)
+		slog.Error("Error opening input file:", "err", err)
 		os.Exit(1)
 	}
 	defer jsonFile.Close()
 	content, err := io.ReadAll(jsonFile)
 	if err != nil {
-		slog.Error("Error reading input file:", err)
+		slog.Error("Error reading input file:", "err", err)
 		os.Exit(1)
 	}
 	res := make(map[string]any)
 	err = json.Unmarshal(content, &res)
 	if err != nil {
-		slog.Error("Error reading input file:", err)
+		slog.Error("Error reading input file:", "err", err)
 		os.Exit(1)
 	}
 	return res
 }
+
+// OverlayLeaf overlay修改的一个字段
+type OverlayLeaf struct {
+	Path    []string
+	Overlay string //overlay目录名，例如qa
+}
+
+// baseJsonDataMap 合并overlay前的基础数据，保存时还原overlay修改的字段
+var baseJsonDataMap = make(map[string]map[string]map[string]any)
+var overlayLeafMap = make(map[string]map[string][]OverlayLeaf)
+
+// ApplyOverlays 按顺序把overlay目录的数据合并到已加载的数据上，记录每个字段来自哪个overlay
+func ApplyOverlays(dirs []string) error {
+	for _, dir := range dirs {
+		files, err := utils.LoadDataDir(dir)
+		if err != nil {
+			return err
+		}
+		name := filepath.Base(filepath.Clean(dir))
+		for _, file := range files {
+			if jsonDataMap[file.Package] == nil {
+				jsonDataMap[file.Package] = make(map[string]map[string]any)
+			}
+			if baseJsonDataMap[file.Package] == nil {
+				baseJsonDataMap[file.Package] = make(map[string]map[string]any)
+				overlayLeafMap[file.Package] = make(map[string][]OverlayLeaf)
+			}
+			base := jsonDataMap[file.Package][file.Table]
+			if _, ok := baseJsonDataMap[file.Package][file.Table]; !ok {
+				baseJsonDataMap[file.Package][file.Table] = base
+			}
+			jsonDataMap[file.Package][file.Table] = datafile.MergeOverlay(base, file.Data).(map[string]any)
+			for _, path := range datafile.OverlayLeaves(file.Data) {
+				overlayLeafMap[file.Package][file.Table] = append(overlayLeafMap[file.Package][file.Table], OverlayLeaf{Path: path, Overlay: name})
+			}
+		}
+	}
+	return nil
+}
+
+// TemplateInfo 数据文件中的行继承
+type TemplateInfo struct {
+	Templates any //$templates，保存时原样写回
+	Refs      []datafile.TemplateRef
+}
+
+var templateMap = make(map[string]map[string]*TemplateInfo)
+
+// ResolveAllTemplates 展开所有数据的行继承，在合并overlay之后调用
+func ResolveAllTemplates() error {
+	for pack, tables := range jsonDataMap {
+		for table, data := range tables {
+			resolved, refs, err := datafile.ResolveTemplates(data)
+			if err != nil {
+				return fmt.Errorf("%s/%s: %w", pack, table, err)
+			}
+			tables[table] = resolved
+			if templateMap[pack] == nil {
+				templateMap[pack] = make(map[string]*TemplateInfo)
+			}
+			templateMap[pack][table] = &TemplateInfo{Templates: data[datafile.TemplatesKey], Refs: refs}
+		}
+	}
+	return nil
+}
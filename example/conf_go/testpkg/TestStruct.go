@@ -3,7 +3,13 @@
 // 测试包
 package testpkg
 
+import "github.com/mogebingxue/game_config_manager"
+
 // 测试结构
 type TestStruct struct {
 	TestSubStruct TestSubStruct // 测试子结构
 }
+
+func (cfg *TestStruct) DecodeBinary(r *config.BinaryReader) {
+	cfg.TestSubStruct.DecodeBinary(r)
+}
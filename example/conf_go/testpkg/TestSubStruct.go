@@ -3,9 +3,17 @@
 // 测试包
 package testpkg
 
+import "github.com/mogebingxue/game_config_manager"
+
 // 测试子结构
 type TestSubStruct struct {
 	TestInt    int    // 测试整型
 	TestString string // 测试字符串
 	TestBool   bool   // 测试布尔值
 }
+
+func (cfg *TestSubStruct) DecodeBinary(r *config.BinaryReader) {
+	cfg.TestInt = r.ReadInt()
+	cfg.TestString = r.ReadString()
+	cfg.TestBool = r.ReadBool()
+}
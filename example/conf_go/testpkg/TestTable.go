@@ -7,44 +7,80 @@ import "github.com/mogebingxue/game_config_manager"
 
 // 测试表
 type TestTable struct {
-	TestInt    int                  // 测试整型
-	TestString string               // 测试字符串
-	TestBool   bool                 // 测试布尔值
-	TestEnum   TEST_ENUM            // 测试枚举
-	TestList   []TEST_ENUM          // 测试列表
-	TestMap    map[string]TEST_ENUM // 测试哈希表
-	TestStruct TestStruct           // 测试结构
+	TestInt       int                      // 测试整型
+	TestString    string                   // 测试字符串
+	TestBool      bool                     // 测试布尔值
+	TestEnum      TEST_ENUM                // 测试枚举
+	TestList      []TEST_ENUM              // 测试列表
+	TestMap       map[string]TEST_ENUM     // 测试哈希表
+	TestStruct    TestStruct               // 测试结构
+	TestStructMap map[string]TestSubStruct // 测试结构哈希表
 }
 
-var testTable *TestTable
-var reloadTestTable *TestTable
+func init() {
+	config.GetConfigManager().Register((*TestTable)(nil))
+}
 
 func (cfg *TestTable) GetFileName() string {
 	return "testpkg/TestTable.json"
 }
 
-func (cfg *TestTable) GetResult() interface{} {
-	return testTable
+func (cfg *TestTable) NewResult() interface{} {
+	return new(TestTable)
 }
 
-func (cfg *TestTable) GetReloadResult(alloc bool) interface{} {
-	if alloc || reloadTestTable == nil {
-		reloadTestTable = new(TestTable)
-	}
-	return reloadTestTable
+func (cfg *TestTable) GetSchemaHash() uint32 {
+	return 0x3aba2ac1
 }
 
-func (cfg *TestTable) OnReloadFinished() {
-	testTable = reloadTestTable
+func (cfg *TestTable) DecodeBinary(r *config.BinaryReader) {
+	cfg.TestInt = r.ReadInt()
+	cfg.TestString = r.ReadString()
+	cfg.TestBool = r.ReadBool()
+	cfg.TestEnum = TEST_ENUM(r.ReadInt())
+	if n := r.ReadLen(); n > 0 {
+		cfg.TestList = make([]TEST_ENUM, n)
+		for i := range cfg.TestList {
+			cfg.TestList[i] = TEST_ENUM(r.ReadInt())
+		}
+	}
+	if n := r.ReadLen(); n > 0 {
+		cfg.TestMap = make(map[string]TEST_ENUM, n)
+		for i := 0; i < n; i++ {
+			k := r.ReadString()
+			v := TEST_ENUM(r.ReadInt())
+			cfg.TestMap[k] = v
+		}
+	}
+	cfg.TestStruct.DecodeBinary(r)
+	if n := r.ReadLen(); n > 0 {
+		cfg.TestStructMap = make(map[string]TestSubStruct, n)
+		for i := 0; i < n; i++ {
+			k := r.ReadString()
+			var v TestSubStruct
+			v.DecodeBinary(r)
+			cfg.TestStructMap[k] = v
+		}
+	}
 }
 
 func GetTestTable() *TestTable {
-	if testTable == nil {
-		testTable = &TestTable{}
-		config.GetConfigManager().LoadFile(testTable)
-	}
-	if config.GetConfigManager().IsDirty(testTable.GetFileName()) {
-		config.GetConfigManager().ReloadFile(testTable)
-	}
-	return testTable
+	return GetTestTableFrom(config.GetConfigManager().Current())
+}
+
+// GetTestTableFrom 返回指定版本中的数据，从同一个版本中读取的多个表是一致的
+func GetTestTableFrom(gen *config.Generation) *TestTable {
+	cfg, _ := config.GetConfigManager().Get(gen, (*TestTable)(nil)).(*TestTable)
+	return cfg
+}
+
+// OnTestTableReloaded 重新加载成功后在监听goroutine中调用，文件删除并保留旧数据时不调用，返回取消订阅的函数
+func OnTestTableReloaded(fn func(oldCfg, newCfg *TestTable)) func() {
+	return config.GetConfigManager().Subscribe("testpkg/TestTable.json", func(event config.ReloadEvent) {
+		if event.Err != nil || event.New == nil {
+			return
+		}
+		oldCfg, _ := event.Old.(*TestTable)
+		fn(oldCfg, event.New.(*TestTable))
+	})
 }
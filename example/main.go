@@ -1,18 +1,60 @@
 package main
 
 import (
+	"embed"
+	"expvar"
 	"github.com/mogebingxue/game_config_manager"
 	"github.com/mogebingxue/game_config_manager/example/conf_go/testpkg"
 	"log/slog"
+	"net/http"
+	"os"
 	"time"
 )
 
+//go:embed data
+var embedded embed.FS
+
 func main() {
 	cfg, err := config.LoadConfig("./conf.yaml")
 	if err != nil {
 		return
 	}
-	config.GetConfigManager().StartService(cfg.DataPath)
+	config.GetConfigManager().SetWatchConfig(cfg.Watch)
+	config.GetConfigManager().SetHistoryConfig(cfg.History)
+	config.GetConfigManager().SetStrict(cfg.Strict)
+	if cfg.AuditLog != "" {
+		if err := config.GetConfigManager().SetAuditLog(cfg.AuditLog); err != nil {
+			slog.Error("config audit log", "err", err)
+		}
+	}
+	//conf.yaml中source的类型为embed时使用编译进程序的数据
+	source, err := cfg.NewSource(embedded)
+	if err != nil {
+		slog.Error("config source", "err", err)
+		os.Exit(1)
+	}
+	overlays := make([]config.Source, len(cfg.Overlays))
+	for i, overlay := range cfg.Overlays {
+		overlays[i] = config.NewDirSource(overlay)
+	}
+	config.GetConfigManager().StartSource(source, overlays...)
+	//启动时加载所有表，严格模式下有错误时不启动
+	if err := config.GetConfigManager().LoadAll(); err != nil {
+		slog.Error("config load failed", "err", err)
+		if cfg.Strict {
+			os.Exit(1)
+		}
+	}
+	//运行时管理接口，例如 curl 127.0.0.1:8081/config/tables，指标在 /metrics 和 /debug/vars
+	if cfg.Admin.Addr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/config/", http.StripPrefix("/config", config.GetConfigManager().AdminHandler(cfg.Admin.Token)))
+		mux.Handle("/metrics", config.GetConfigManager().MetricsHandler())
+		mux.Handle("/debug/vars", expvar.Handler())
+		go func() {
+			slog.Error("config admin stopped", "err", http.ListenAndServe(cfg.Admin.Addr, mux))
+		}()
+	}
 	ticker := time.NewTicker(5 * time.Second)
 	for {
 		select {
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mogebingxue/game_config_manager"
+	"github.com/mogebingxue/game_config_manager/datafile"
+	"github.com/mogebingxue/game_config_manager/utils"
+)
+
+// 把json数据(合并conf.yaml的overlays后)编译为二进制格式 <包名>/<表名>.bin，文件头记录json数据的hash，
+// ConfigManager只加载hash和当前json数据一致的bin文件
+// 导出前先校验数据，有错误时不导出
+// 用法：go run ./export_bin [-out <导出目录，默认为conf.yaml的data_path>]
+func main() {
+	confPath := flag.String("conf", "./conf.yaml", "配置文件")
+	out := flag.String("out", "", "导出目录，默认导出到json旁边")
+	flag.Parse()
+	cfg, err := config.LoadConfig(*confPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if *out == "" {
+		*out = cfg.DataPath
+	}
+	schema, err := utils.LoadSchema(cfg.MetadataPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "load metadata:", err)
+		os.Exit(2)
+	}
+	if errs := utils.ValidateDataDir(schema, cfg.DataPath, cfg.Overlays...); len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Println(err)
+		}
+		fmt.Printf("%d errors\n", len(errs))
+		os.Exit(1)
+	}
+	files, err := utils.LoadDataDir(cfg.DataPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "load data:", err)
+		os.Exit(2)
+	}
+	for _, file := range files {
+		meta, _ := schema.LookupType(file.Package, file.Table)
+		base, err := os.ReadFile(file.Path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		//和ConfigManager一样先合并overlay再展开继承
+		merged, overlays, err := utils.MergeOverlays(file.Data, file.Package, file.Table, cfg.Overlays)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		resolved, _, err := datafile.ResolveTemplates(merged)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, file.Path, err)
+			os.Exit(1)
+		}
+		//二进制中是展开继承后的数据
+		data, err := utils.EncodeBinary(schema.TypeMap(file.Package), meta.Meta.(*utils.Struct), resolved, datafile.BinarySourceHash(base, overlays...))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, file.Path, err)
+			os.Exit(1)
+		}
+		binName := strings.TrimSuffix(utils.DataFileName(file.Package, file.Table), ".json") + ".bin"
+		binPath := filepath.Join(*out, binName)
+		if err := os.MkdirAll(filepath.Dir(binPath), os.ModePerm); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		if err := os.WriteFile(binPath, data, 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		fmt.Printf("%s %d bytes\n", binPath, len(data))
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/mogebingxue/game_config_manager"
+	"github.com/mogebingxue/game_config_manager/utils"
+	"github.com/xuri/excelize/v2"
+)
+
+// 把json数据导出为表格，方便审阅和批量修改，导出的表格可以用import_excel原样导回
+// xlsx每个包一个文件 <out>/<包名>.xlsx，每个表一个sheet；csv每个表一个文件 <out>/<包名>/<表名>.csv
+// 第一行是字段路径，第二行是别名，第三行是数据；用到行继承的数据不展开，对象的"$base"和文件的"$templates"各占一列
+// 用法：go run ./export_excel [-pkg testpkg] [-format xlsx|csv] [-out ./export]
+func main() {
+	confPath := flag.String("conf", "./conf.yaml", "配置文件")
+	pkgName := flag.String("pkg", "", "只导出这个包")
+	format := flag.String("format", "xlsx", "导出格式，xlsx或csv")
+	out := flag.String("out", "./export", "导出目录")
+	flag.Parse()
+	cfg, err := config.LoadConfig(*confPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	schema, err := utils.LoadSchema(cfg.MetadataPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "load metadata:", err)
+		os.Exit(2)
+	}
+	files, err := utils.LoadDataDir(cfg.DataPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "load data:", err)
+		os.Exit(2)
+	}
+	dataMap := make(map[string]map[string]any) //key：包名/表名
+	for _, file := range files {
+		dataMap[utils.DataFileName(file.Package, file.Table)] = file.Data
+	}
+
+	pkgs := schema.Packages()
+	if *pkgName != "" {
+		pkgs = []string{*pkgName}
+	}
+	for _, pkg := range pkgs {
+		var sheets []sheet
+		typMap := schema.TypeMap(pkg)
+		for _, table := range schema.Tables(pkg) {
+			sheets = append(sheets, newSheet(typMap, &table, dataMap[utils.DataFileName(pkg, table.Name)]))
+		}
+		if len(sheets) == 0 {
+			continue
+		}
+		switch *format {
+		case "xlsx":
+			err = writeXlsx(filepath.Join(*out, pkg+".xlsx"), sheets)
+		case "csv":
+			err = writeCsv(filepath.Join(*out, pkg), sheets)
+		default:
+			err = fmt.Errorf("format not support %s", *format)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, pkg, err)
+			os.Exit(1)
+		}
+	}
+}
+
+type sheet struct {
+	Name    string
+	Columns []utils.SheetColumn
+	Rows    [][]string
+}
+
+func newSheet(typMap map[string]utils.Meta, table *utils.Struct, data map[string]any) sheet {
+	//导出原始数据，行继承导出为$base、$templates列，导回后继承关系不变
+	s := sheet{Name: table.Name, Columns: utils.DataSheetColumns(typMap, table, data)}
+	header := make([]string, len(s.Columns))
+	aliases := make([]string, len(s.Columns))
+	for i, column := range s.Columns {
+		header[i], aliases[i] = column.Path, column.Alias
+	}
+	s.Rows = append(s.Rows, header, aliases)
+	//没有数据文件的表只导出表头
+	if data != nil {
+		s.Rows = append(s.Rows, utils.FormatSheetRow(typMap, s.Columns, data))
+	}
+	return s
+}
+
+func writeXlsx(fileName string, sheets []sheet) error {
+	f := excelize.NewFile()
+	defer f.Close()
+	for i, s := range sheets {
+		if i == 0 {
+			if err := f.SetSheetName(f.GetSheetName(0), s.Name); err != nil {
+				return err
+			}
+		} else if _, err := f.NewSheet(s.Name); err != nil {
+			return err
+		}
+		for r, row := range s.Rows {
+			cells := make([]any, len(row))
+			for c, cell := range row {
+				cells[c] = cell
+				//数据行的int写成数字，方便在excel里计算
+				if r >= 2 && s.Columns[c].Var.Typ == "int" {
+					if num, err := strconv.Atoi(cell); err == nil {
+						cells[c] = num
+					}
+				}
+			}
+			axis, err := excelize.CoordinatesToCellName(1, r+1)
+			if err != nil {
+				return err
+			}
+			if err := f.SetSheetRow(s.Name, axis, &cells); err != nil {
+				return err
+			}
+		}
+		//冻结表头
+		err := f.SetPanes(s.Name, &excelize.Panes{Freeze: true, YSplit: 2, TopLeftCell: "A3", ActivePane: "bottomLeft"})
+		if err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(fileName), os.ModePerm); err != nil {
+		return err
+	}
+	if err := f.SaveAs(fileName); err != nil {
+		return err
+	}
+	fmt.Println("export", fileName)
+	return nil
+}
+
+func writeCsv(dir string, sheets []sheet) error {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	for _, s := range sheets {
+		fileName := filepath.Join(dir, s.Name+".csv")
+		file, err := os.Create(fileName)
+		if err != nil {
+			return err
+		}
+		//写入BOM，excel才能正确识别utf-8
+		file.WriteString("\ufeff")
+		w := csv.NewWriter(file)
+		w.WriteAll(s.Rows)
+		err = w.Error()
+		file.Close()
+		if err != nil {
+			return err
+		}
+		fmt.Println("export", fileName)
+	}
+	return nil
+}
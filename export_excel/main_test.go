@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mogebingxue/game_config_manager/utils"
+)
+
+// TestExportInherited 导出用到行继承的数据，再按import_excel的方式导回，数据和继承关系不变
+func TestExportInherited(t *testing.T) {
+	schema, err := utils.LoadSchema("../example/metadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := utils.LoadDataDir("../example/data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data map[string]any
+	for _, file := range files {
+		if file.Package == "testpkg" && file.Table == "TestTable" {
+			data = file.Data
+		}
+	}
+	if _, ok := data["$templates"]; !ok {
+		t.Fatal("fixture does not use templates")
+	}
+	typMap := schema.TypeMap("testpkg")
+	meta, _ := schema.LookupType("testpkg", "TestTable")
+	table := meta.Meta.(*utils.Struct)
+
+	dir := t.TempDir()
+	if err := writeCsv(dir, []sheet{newSheet(typMap, table, data)}); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(filepath.Join(dir, "TestTable.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("rows %d, want 3", len(rows))
+	}
+	header := rows[0]
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	if header[len(header)-1] != "$templates" {
+		t.Errorf("last column %s, want $templates", header[len(header)-1])
+	}
+	got, err := utils.ParseSheetRow(typMap, table, header, rows[2], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, data) {
+		t.Errorf("got %v, want %v", got, data)
+	}
+	if errs := utils.ValidateData(typMap, table, "TestTable.json", got); len(errs) > 0 {
+		t.Error(errs)
+	}
+}
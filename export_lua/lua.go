@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mogebingxue/game_config_manager/utils"
+)
+
+// luaWriter 按元数据把数据写成lua table，结构体字段按元数据顺序，枚举引用enums模块中的定义
+type luaWriter struct {
+	typMap map[string]utils.Meta
+	buffer *strings.Builder
+}
+
+func (w *luaWriter) newLine(indent int) {
+	w.buffer.WriteString("\n")
+	w.buffer.WriteString(strings.Repeat("    ", indent))
+}
+
+func (w *luaWriter) writeStruct(st *utils.Struct, data map[string]any, indent int) {
+	w.buffer.WriteString("{")
+	written := false
+	for _, v := range st.Vars {
+		val, ok := data[v.Name]
+		if !ok {
+			continue
+		}
+		w.newLine(indent + 1)
+		w.buffer.WriteString(luaKey(v.Name) + " = ")
+		w.writeValue(v, val, indent+1)
+		w.buffer.WriteString(",")
+		written = true
+	}
+	if written {
+		w.newLine(indent)
+	}
+	w.buffer.WriteString("}")
+}
+
+func (w *luaWriter) writeValue(v utils.StructVar, val any, indent int) {
+	switch v.Typ {
+	case "list":
+		list, _ := val.([]any)
+		elem := utils.StructVar{Name: v.Name, Typ: v.ValueType}
+		w.buffer.WriteString("{")
+		for _, item := range list {
+			w.newLine(indent + 1)
+			w.writeValue(elem, item, indent+1)
+			w.buffer.WriteString(",")
+		}
+		if len(list) > 0 {
+			w.newLine(indent)
+		}
+		w.buffer.WriteString("}")
+	case "map":
+		m, _ := val.(map[string]any)
+		elem := utils.StructVar{Name: v.Name, Typ: v.ValueType}
+		w.buffer.WriteString("{")
+		for _, k := range sortedKeys(v.KeyType, m) {
+			w.newLine(indent + 1)
+			//int类型的key写成数字
+			if num, err := strconv.Atoi(k); err == nil && v.KeyType == "int" {
+				w.buffer.WriteString(fmt.Sprintf("[%d] = ", num))
+			} else {
+				w.buffer.WriteString(luaKey(k) + " = ")
+			}
+			w.writeValue(elem, m[k], indent+1)
+			w.buffer.WriteString(",")
+		}
+		if len(m) > 0 {
+			w.newLine(indent)
+		}
+		w.buffer.WriteString("}")
+	default:
+		meta, ok := w.typMap[v.Typ]
+		switch {
+		case ok && meta.Typ == utils.STRUCT:
+			m, _ := val.(map[string]any)
+			w.writeStruct(meta.Meta.(*utils.Struct), m, indent)
+		case ok && meta.Typ == utils.ENUM:
+			w.buffer.WriteString(enumRef(meta.Meta.(*utils.Enum), val))
+		default:
+			w.buffer.WriteString(luaScalar(val))
+		}
+	}
+}
+
+// sortedKeys int类型的key按数值排序
+func sortedKeys(keyType string, m map[string]any) []string {
+	keys := utils.SortedKeys(m)
+	if keyType == "int" {
+		sort.SliceStable(keys, func(i, j int) bool {
+			a, errA := strconv.Atoi(keys[i])
+			b, errB := strconv.Atoi(keys[j])
+			if errA != nil || errB != nil {
+				return errA == nil && errB != nil
+			}
+			return a < b
+		})
+	}
+	return keys
+}
+
+// enumRef 枚举值写成 E.枚举名.值名，找不到时写数字
+func enumRef(enum *utils.Enum, val any) string {
+	num, ok := val.(float64)
+	if !ok {
+		return luaScalar(val)
+	}
+	for _, v := range enum.Vars {
+		if v.Default == strconv.FormatFloat(num, 'f', -1, 64) && luaIdent.MatchString(v.Name) && !luaKeywords[v.Name] {
+			return fmt.Sprintf("E.%s.%s", enum.Name, v.Name)
+		}
+	}
+	return luaScalar(val)
+}
+
+func luaScalar(val any) string {
+	switch v := val.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return luaString(v)
+	}
+	return "nil"
+}
+
+// luaString lua字符串，转义引号、反斜杠和控制字符，utf8原样输出
+func luaString(s string) string {
+	var sb strings.Builder
+	sb.WriteString(`"`)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				//后面跟数字时\ddd必须写满3位
+				sb.WriteString(fmt.Sprintf(`\%03d`, c))
+			} else {
+				sb.WriteByte(c)
+			}
+		}
+	}
+	sb.WriteString(`"`)
+	return sb.String()
+}
+
+var luaIdent = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+var luaKeywords = map[string]bool{
+	"and": true, "break": true, "do": true, "else": true, "elseif": true, "end": true,
+	"false": true, "for": true, "function": true, "goto": true, "if": true, "in": true,
+	"local": true, "nil": true, "not": true, "or": true, "repeat": true, "return": true,
+	"then": true, "true": true, "until": true, "while": true,
+}
+
+// luaKey 标识符直接作为key，其他写成["key"]
+func luaKey(key string) string {
+	if luaIdent.MatchString(key) && !luaKeywords[key] {
+		return key
+	}
+	return "[" + luaString(key) + "]"
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mogebingxue/game_config_manager/utils"
+)
+
+const luaTestXml = `<conf package="p">
+	<enum name="E">
+		<var name="A" default="1"/>
+		<var name="end" default="2"/>
+	</enum>
+	<table name="T">
+		<var name="IntMap" type="map" keyType="int" valueType="E"/>
+		<var name="StrMap" type="map" valueType="int"/>
+		<var name="List" type="list" valueType="string"/>
+	</table>
+</conf>`
+
+func luaTestTable(t *testing.T) (map[string]utils.Meta, *utils.Struct) {
+	t.Helper()
+	conf := &utils.Conf{}
+	if err := xml.Unmarshal([]byte(luaTestXml), conf); err != nil {
+		t.Fatal(err)
+	}
+	err, typMap := utils.CheckConfValid(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return typMap, &conf.Tables[0]
+}
+
+func TestLuaString(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", `""`},
+		{"abc", `"abc"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`a\b`, `"a\\b"`},
+		{"a\nb\r\tc", `"a\nb\r\tc"`},
+		{"\x00", `"\000"`},
+		//后面跟数字时不能被当成转义的一部分
+		{"\x012", `"\0012"`},
+		{"\x7f", `"\127"`},
+		{"中文]]", `"中文]]"`},
+	}
+	for _, tt := range tests {
+		if got := luaString(tt.in); got != tt.want {
+			t.Errorf("luaString(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+	if got := luaKey("end"); got != `["end"]` {
+		t.Errorf("luaKey(end) = %s", got)
+	}
+	if got := luaKey("a-b"); got != `["a-b"]` {
+		t.Errorf("luaKey(a-b) = %s", got)
+	}
+	if got := luaKey("_a1"); got != "_a1" {
+		t.Errorf("luaKey(_a1) = %s", got)
+	}
+}
+
+func TestEnumRef(t *testing.T) {
+	typMap, _ := luaTestTable(t)
+	enum := typMap["E"].Meta.(*utils.Enum)
+	tests := []struct {
+		val  any
+		want string
+	}{
+		{1.0, "E.E.A"},
+		{2.0, "2"}, //值名是lua关键字时写数字
+		{3.0, "3"}, //找不到时写数字
+		{"A", `"A"`},
+		{nil, "nil"},
+	}
+	for _, tt := range tests {
+		if got := enumRef(enum, tt.val); got != tt.want {
+			t.Errorf("enumRef(%v) = %s, want %s", tt.val, got, tt.want)
+		}
+	}
+}
+
+func TestLuaWriterMapKeys(t *testing.T) {
+	typMap, table := luaTestTable(t)
+	var buffer strings.Builder
+	w := &luaWriter{typMap: typMap, buffer: &buffer}
+	w.writeStruct(table, map[string]any{
+		"IntMap": map[string]any{"10": 1.0, "2": 1.0, "-1": 2.0},
+		"StrMap": map[string]any{"1": 1.0, "b": 2.0, "a b": 3.0},
+		"List":   []any{"x"},
+	}, 0)
+	want := `{
+    IntMap = {
+        [-1] = 2,
+        [2] = E.E.A,
+        [10] = E.E.A,
+    },
+    StrMap = {
+        ["1"] = 1,
+        ["a b"] = 3,
+        b = 2,
+    },
+    List = {
+        "x",
+    },
+}`
+	if got := buffer.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestReadonly(t *testing.T) {
+	typMap, table := luaTestTable(t)
+	tests := []struct {
+		readonly bool
+		module   string
+		want     string
+	}{
+		{false, "config", "return data\n"},
+		{true, "config", `return require("config.readonly")(data)` + "\n"},
+		{true, "", `return require("readonly")(data)` + "\n"},
+	}
+	for _, tt := range tests {
+		e := &exporter{module: tt.module, readonly: tt.readonly}
+		got := e.genTable(typMap, "p", table, map[string]any{})
+		if !strings.HasSuffix(got, tt.want) {
+			t.Errorf("readonly %v module %q: got\n%s", tt.readonly, tt.module, got)
+		}
+		if !strings.Contains(got, `local E = require(`+luaString(e.require("p.enums"))+`)`) {
+			t.Errorf("enums not required:\n%s", got)
+		}
+		conf := &utils.Conf{Package: "p"}
+		if enums := e.genEnums(conf); !strings.HasSuffix(enums, strings.Replace(tt.want, "data", "M", 1)) {
+			t.Errorf("enums readonly %v: got\n%s", tt.readonly, enums)
+		}
+	}
+
+	//只读元表拦截修改，并且支持pairs和#
+	for _, want := range []string{"__newindex", "config is readonly", "__pairs", "__len", "return readonly\n"} {
+		if !strings.Contains(readonlyLua, want) {
+			t.Errorf("readonly.lua does not contain %q", want)
+		}
+	}
+	e := &exporter{out: t.TempDir(), readonly: true}
+	e.write("readonly.lua", readonlyLua)
+	if e.err != nil {
+		t.Fatal(e.err)
+	}
+	if content, err := os.ReadFile(filepath.Join(e.out, "readonly.lua")); err != nil || string(content) != readonlyLua {
+		t.Errorf("readonly.lua: %v", err)
+	}
+}
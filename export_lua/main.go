@@ -0,0 +1,227 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mogebingxue/game_config_manager"
+	"github.com/mogebingxue/game_config_manager/datafile"
+	"github.com/mogebingxue/game_config_manager/utils"
+)
+
+// 把json数据(合并conf.yaml的overlays后)导出为客户端使用的lua模块
+// <out>/<包名>/enums.lua 包内的枚举和常量，<out>/<包名>/<表名>.lua 表数据 return { ... }，
+// <out>/index.lua 所有表的加载索引，-readonly 时数据用只读元表包装(需要lua 5.2以上才支持pairs)
+// 用法：go run ./export_lua [-out ./export/lua] [-module config] [-readonly]
+func main() {
+	confPath := flag.String("conf", "./conf.yaml", "配置文件")
+	out := flag.String("out", "./export/lua", "导出目录")
+	module := flag.String("module", "config", "导出目录对应的lua模块名前缀，用于require")
+	readonly := flag.Bool("readonly", false, "数据使用只读元表")
+	flag.Parse()
+	cfg, err := config.LoadConfig(*confPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	schema, err := utils.LoadSchema(cfg.MetadataPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "load metadata:", err)
+		os.Exit(2)
+	}
+	if errs := utils.ValidateDataDir(schema, cfg.DataPath, cfg.Overlays...); len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Println(err)
+		}
+		fmt.Printf("%d errors\n", len(errs))
+		os.Exit(1)
+	}
+	files, err := utils.LoadDataDir(cfg.DataPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "load data:", err)
+		os.Exit(2)
+	}
+	dataMap := make(map[string]map[string]any) //key：包名/表名
+	for _, file := range files {
+		//和ConfigManager一样先合并overlay再展开继承
+		merged, _, err := utils.MergeOverlays(file.Data, file.Package, file.Table, cfg.Overlays)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		resolved, _, err := datafile.ResolveTemplates(merged)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, file.Path, err)
+			os.Exit(1)
+		}
+		dataMap[utils.DataFileName(file.Package, file.Table)] = resolved
+	}
+
+	e := &exporter{out: *out, module: *module, readonly: *readonly}
+	if *readonly {
+		e.write("readonly.lua", readonlyLua)
+	}
+	var index []string
+	for _, pkg := range schema.Packages() {
+		conf, _ := schema.Package(pkg)
+		typMap := schema.TypeMap(pkg)
+		e.write(pkg+"/enums.lua", e.genEnums(conf))
+		for _, table := range schema.Tables(pkg) {
+			data, ok := dataMap[utils.DataFileName(pkg, table.Name)]
+			if !ok {
+				continue
+			}
+			e.write(pkg+"/"+table.Name+".lua", e.genTable(typMap, pkg, &table, data))
+			index = append(index, pkg+"."+table.Name)
+		}
+	}
+	e.write("index.lua", e.genIndex(index))
+	if e.err != nil {
+		fmt.Fprintln(os.Stderr, e.err)
+		os.Exit(2)
+	}
+}
+
+type exporter struct {
+	out      string
+	module   string
+	readonly bool
+	err      error
+}
+
+func (e *exporter) write(name, content string) {
+	if e.err != nil {
+		return
+	}
+	path := filepath.Join(e.out, name)
+	if e.err = os.MkdirAll(filepath.Dir(path), os.ModePerm); e.err != nil {
+		return
+	}
+	if e.err = os.WriteFile(path, []byte(content), 0644); e.err == nil {
+		fmt.Println("export", path)
+	}
+}
+
+func (e *exporter) require(name string) string {
+	if e.module == "" {
+		return name
+	}
+	return e.module + "." + name
+}
+
+// genReturn 模块的返回值，只读时用元表包装
+func (e *exporter) genReturn(buffer *strings.Builder, name string) {
+	if e.readonly {
+		buffer.WriteString(fmt.Sprintf("return require(%s)(%s)\n", luaString(e.require("readonly")), name))
+	} else {
+		buffer.WriteString(fmt.Sprintf("return %s\n", name))
+	}
+}
+
+// genEnums 包内的枚举和常量，数据中的枚举值引用这里的定义
+func (e *exporter) genEnums(conf *utils.Conf) string {
+	var buffer strings.Builder
+	buffer.WriteString(fmt.Sprintf("-- Code generated by export_lua. DO NOT EDIT.\n-- %s\n\nlocal M = {}\n", conf.Alias))
+	for _, c := range conf.Consts {
+		//解析元数据时已经检查过
+		value, _ := c.NormalizedValue()
+		if c.Typ == "string" {
+			value = luaString(c.Value)
+		}
+		buffer.WriteString(fmt.Sprintf("\n-- %s\nM.%s = %s\n", c.Alias, c.Name, value))
+	}
+	for _, enum := range conf.Enums {
+		buffer.WriteString(fmt.Sprintf("\n-- %s\nM.%s = {\n", enum.Alias, enum.Name))
+		for _, v := range enum.Vars {
+			buffer.WriteString(fmt.Sprintf("    %s = %s, -- %s\n", luaKey(v.Name), v.Default, v.Alias))
+		}
+		buffer.WriteString("}\n")
+	}
+	buffer.WriteString("\n")
+	e.genReturn(&buffer, "M")
+	return buffer.String()
+}
+
+func (e *exporter) genTable(typMap map[string]utils.Meta, pkg string, table *utils.Struct, data map[string]any) string {
+	var buffer strings.Builder
+	buffer.WriteString(fmt.Sprintf("-- Code generated by export_lua. DO NOT EDIT.\n-- %s\n\n", table.Alias))
+	buffer.WriteString(fmt.Sprintf("local E = require(%s)\n\n", luaString(e.require(pkg+".enums"))))
+	w := &luaWriter{typMap: typMap, buffer: &buffer}
+	buffer.WriteString("local data = ")
+	w.writeStruct(table, data, 0)
+	buffer.WriteString("\n\n")
+	e.genReturn(&buffer, "data")
+	return buffer.String()
+}
+
+// genIndex 加载索引，index.load("包名.表名") 加载表
+func (e *exporter) genIndex(tables []string) string {
+	var buffer strings.Builder
+	buffer.WriteString("-- Code generated by export_lua. DO NOT EDIT.\n\nlocal M = {}\n\n")
+	buffer.WriteString("-- key：包名.表名，value：模块名\nM.tables = {\n")
+	for _, name := range tables {
+		buffer.WriteString(fmt.Sprintf("    [%s] = %s,\n", luaString(name), luaString(e.require(name))))
+	}
+	buffer.WriteString("}\n\n")
+	buffer.WriteString("function M.load(name)\n")
+	buffer.WriteString("    local module = M.tables[name]\n")
+	buffer.WriteString("    if module == nil then\n")
+	buffer.WriteString("        error(\"config table not found: \" .. tostring(name), 2)\n")
+	buffer.WriteString("    end\n")
+	buffer.WriteString("    return require(module)\n")
+	buffer.WriteString("end\n\n")
+	buffer.WriteString("function M.load_all()\n")
+	buffer.WriteString("    local all = {}\n")
+	buffer.WriteString("    for name in pairs(M.tables) do\n")
+	buffer.WriteString("        all[name] = M.load(name)\n")
+	buffer.WriteString("    end\n")
+	buffer.WriteString("    return all\n")
+	buffer.WriteString("end\n\n")
+	buffer.WriteString("return M\n")
+	return buffer.String()
+}
+
+// readonlyLua 递归的只读代理，修改时报错
+const readonlyLua = `-- Code generated by export_lua. DO NOT EDIT.
+
+local function readonly(t)
+    if type(t) ~= "table" then
+        return t
+    end
+    local proxy = {}
+    local cache = {}
+    local function get(_, k)
+        local v = t[k]
+        if type(v) == "table" then
+            if cache[k] == nil then
+                cache[k] = readonly(v)
+            end
+            return cache[k]
+        end
+        return v
+    end
+    setmetatable(proxy, {
+        __index = get,
+        __newindex = function(_, k)
+            error("config is readonly: " .. tostring(k), 2)
+        end,
+        __len = function()
+            return #t
+        end,
+        __pairs = function()
+            return function(_, k)
+                local nk = next(t, k)
+                if nk ~= nil then
+                    return nk, get(nil, nk)
+                end
+            end, proxy, nil
+        end,
+    })
+    return proxy
+end
+
+return readonly
+`
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/mogebingxue/game_config_manager"
+	"github.com/mogebingxue/game_config_manager/utils"
+)
+
+// 把所有数据文件(包括overlays中的)改写为标准格式：字段按元数据顺序，map的key排序，枚举写成数字
+// -check 只检查不改写，有不是标准格式的文件时返回非0，可以用在CI中
+// 用法：go run ./fmt [-check]
+func main() {
+	confPath := flag.String("conf", "./conf.yaml", "配置文件")
+	check := flag.Bool("check", false, "只检查不改写")
+	flag.Parse()
+	cfg, err := config.LoadConfig(*confPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	schema, err := utils.LoadSchema(cfg.MetadataPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "load metadata:", err)
+		os.Exit(2)
+	}
+	//overlay的数据按相同的格式，null和$replace原样保留
+	var changed []string
+	for _, dir := range append([]string{cfg.DataPath}, cfg.Overlays...) {
+		dirChanged, err := utils.FormatDataDir(schema, dir, !*check)
+		for _, path := range dirChanged {
+			fmt.Println(path)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		changed = append(changed, dirChanged...)
+	}
+	if *check && len(changed) > 0 {
+		fmt.Printf("%d files not formatted\n", len(changed))
+		os.Exit(1)
+	}
+}
@@ -28,37 +28,37 @@ type TestTable struct {
 	TestStruct *TestStruct        `yaml:"testStruct"`               // 测试结构
 }
 
-var testTable *TestTable
-var reloadTestTable *TestTable
+func init() {
+	config.GetConfigManager().Register((*TestTable)(nil))
+}
 
 func (cfg *TestTable) GetFileName() string {
 	return "conf/TestTable.json"
 }
 
-func (cfg *TestTable) GetResult() interface{} {
-	return testTable
+func (cfg *TestTable) NewResult() interface{} {
+	return new(TestTable)
 }
 
-func (cfg *TestTable) GetReloadResult(alloc bool) interface{} {
-	if alloc || reloadTestTable == nil {
-		reloadTestTable = new(TestTable)
-	}
-	return reloadTestTable
+func GetTestTable() *TestTable {
+	return GetTestTableFrom(config.GetConfigManager().Current())
 }
 
-func (cfg *TestTable) OnReloadFinished() {
-	testTable = reloadTestTable
+// GetTestTableFrom 返回指定版本中的数据，从同一个版本中读取的多个表是一致的
+func GetTestTableFrom(gen *config.Generation) *TestTable {
+	cfg, _ := config.GetConfigManager().Get(gen, (*TestTable)(nil)).(*TestTable)
+	return cfg
 }
 
-func GetTestTable() *TestTable {
-	if testTable == nil {
-		testTable = &TestTable{}
-		config.GetConfigManager().LoadFile(testTable)
-	}
-	if config.GetConfigManager().IsDirty(testTable.GetFileName()) {
-		config.GetConfigManager().ReloadFile(testTable)
-	}
-	return testTable
+// OnTestTableReloaded 重新加载成功后在监听goroutine中调用，文件删除并保留旧数据时不调用，返回取消订阅的函数
+func OnTestTableReloaded(fn func(oldCfg, newCfg *TestTable)) func() {
+	return config.GetConfigManager().Subscribe("conf/TestTable.json", func(event config.ReloadEvent) {
+		if event.Err != nil || event.New == nil {
+			return
+		}
+		oldCfg, _ := event.Old.(*TestTable)
+		fn(oldCfg, event.New.(*TestTable))
+	})
 }
 
 // 测试枚举
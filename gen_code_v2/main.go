@@ -39,36 +39,40 @@ type TemplateData struct {
 
 // 结构体数据
 type StructData struct {
-	Name     string
-	Comments CommentData
-	Fields   []FieldData
+	Name        string
+	Comments    CommentData
+	Fields      []FieldData
+	Annotations map[string]string
 }
 
 // 枚举数据
 type EnumData struct {
-	Name     string
-	Comments CommentData
-	Values   []EnumValue
+	Name        string
+	Comments    CommentData
+	Values      []EnumValue
+	Annotations map[string]string
 }
 
 // 枚举值
 type EnumValue struct {
-	Name     string
-	Value    int32
-	Comments CommentData
+	Name        string
+	Value       int32
+	Comments    CommentData
+	Annotations map[string]string
 }
 
 // 字段数据
 // 字段数据
 type FieldData struct {
-	FieldName  string
-	FieldType  string
-	YamlTag    string
-	Comments   CommentData
-	IsRepeated bool
-	IsMap      bool
-	MapKey     string
-	MapValue   string
+	FieldName   string
+	FieldType   string
+	YamlTag     string
+	Comments    CommentData
+	IsRepeated  bool
+	IsMap       bool
+	MapKey      string
+	MapValue    string
+	Annotations map[string]string
 }
 
 func main() {
@@ -174,9 +178,9 @@ func prepareTemplateData(fileDescs []*desc.FileDescriptor) TemplateData {
 // 处理消息
 func processMessage(msg *desc.MessageDescriptor) StructData {
 	sd := StructData{
-		Name:     msg.GetName(),
-		Comments: getComments(msg.GetSourceInfo()),
+		Name: msg.GetName(),
 	}
+	sd.Comments, sd.Annotations = getAnnotations(getComments(msg.GetSourceInfo()))
 
 	// 处理字段
 	for _, field := range msg.GetFields() {
@@ -205,15 +209,17 @@ func processMessage(msg *desc.MessageDescriptor) StructData {
 			}
 		}
 
+		comments, annotations := getAnnotations(getComments(field.GetSourceInfo()))
 		sd.Fields = append(sd.Fields, FieldData{
-			FieldName:  fieldName,
-			FieldType:  fieldType,
-			YamlTag:    yamlTag,
-			Comments:   getComments(field.GetSourceInfo()),
-			IsRepeated: isRepeated,
-			IsMap:      isMap,
-			MapKey:     mapKey,
-			MapValue:   mapValue,
+			FieldName:   fieldName,
+			FieldType:   fieldType,
+			YamlTag:     yamlTag,
+			Comments:    comments,
+			IsRepeated:  isRepeated,
+			IsMap:       isMap,
+			MapKey:      mapKey,
+			MapValue:    mapValue,
+			Annotations: annotations,
 		})
 	}
 
@@ -223,15 +229,17 @@ func processMessage(msg *desc.MessageDescriptor) StructData {
 // 处理枚举
 func processEnum(enum *desc.EnumDescriptor) EnumData {
 	ed := EnumData{
-		Name:     enum.GetName(),
-		Comments: getComments(enum.GetSourceInfo()),
+		Name: enum.GetName(),
 	}
+	ed.Comments, ed.Annotations = getAnnotations(getComments(enum.GetSourceInfo()))
 
 	for _, value := range enum.GetValues() {
+		comments, annotations := getAnnotations(getComments(value.GetSourceInfo()))
 		ed.Values = append(ed.Values, EnumValue{
-			Name:     value.GetName(),
-			Value:    value.GetNumber(),
-			Comments: getComments(value.GetSourceInfo()),
+			Name:        value.GetName(),
+			Value:       value.GetNumber(),
+			Comments:    comments,
+			Annotations: annotations,
 		})
 	}
 
@@ -276,6 +284,23 @@ func getComments(info *descriptorpb.SourceCodeInfo_Location) CommentData {
 	}
 }
 
+// 从前置注释中提取注解，格式为单独一行的 @key=value，例如 @go:tag=bson:"level"
+// 注解行会从注释中移除
+func getAnnotations(cd CommentData) (CommentData, map[string]string) {
+	annotations := make(map[string]string)
+	var lines []string
+	for _, line := range strings.Split(cd.LeadingComments, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if key, value, ok := strings.Cut(strings.TrimPrefix(trimmed, "@"), "="); ok && strings.HasPrefix(trimmed, "@") {
+			annotations[strings.TrimSpace(key)] = strings.TrimSpace(value)
+			continue
+		}
+		lines = append(lines, line)
+	}
+	cd.LeadingComments = strings.TrimSpace(strings.Join(lines, "\n"))
+	return cd, annotations
+}
+
 // 映射proto类型到Go类型
 func mapProtoType(field *desc.FieldDescriptor) string {
 	if field.GetMessageType() != nil && field.GetMessageType().IsMapEntry() {
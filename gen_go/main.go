@@ -1,15 +1,13 @@
 package main
 
 import (
-	"encoding/xml"
 	"fmt"
 	"github.com/mogebingxue/game_config_manager"
 	"github.com/mogebingxue/game_config_manager/utils"
-	"io"
 	"log/slog"
 	"os"
 	"os/exec"
-	"path/filepath"
+	"strconv"
 	"strings"
 )
 
@@ -23,47 +21,23 @@ func main() {
 }
 
 func GenGoConf(srcPath, outPath string) {
-	filepath.Walk(srcPath, func(path string, info os.FileInfo, err error) error {
-		//只读取后缀为xml的
-		if !strings.HasSuffix(path, ".xml") {
-			return nil
-		}
-		// 忽略目录
-		if info == nil {
-			return err
-		}
-		if info.IsDir() {
-			return nil
-		}
-		// 打印文件路径
-		genConf(path, outPath)
-
-		return nil
-	})
-
-}
-
-func genConf(srcFilePath, outPath string) {
-	xmlFile, err := os.Open(srcFilePath)
-	if err != nil {
-		slog.Error("Error opening input file:", "err", err)
-		return
-	}
-	content, err := io.ReadAll(xmlFile)
-	if err != nil {
-		slog.Info("Error reading input file:", "err", err)
-		return
-	}
-	conf := &utils.Conf{}
-	err = xml.Unmarshal(content, conf)
+	schema, err := utils.LoadSchema(srcPath)
 	if err != nil {
 		slog.Info("Error:", "err", err)
 		os.Exit(1)
 	}
-	err, _ = utils.CheckConfValid(conf)
-	if err != nil {
-		slog.Info("Error:", "err", err)
-		os.Exit(1)
+	for _, pkg := range schema.Packages() {
+		conf, _ := schema.Package(pkg)
+		genConf(conf, schema.TypeMap(pkg), outPath)
+	}
+}
+
+// genConf 同一个包的所有xml文件合并后生成到一个目录
+func genConf(conf *utils.Conf, typMap map[string]utils.Meta, outPath string) {
+	if len(conf.Consts) > 0 {
+		fileName, writeContent := GenConst(conf.Package, conf.Alias, conf.Consts)
+		WriteToFile(fmt.Sprintf("%s%s/%s.go", outPath, conf.Package, fileName), writeContent)
+		GoFmt(fmt.Sprintf("%s%s/%s.go", outPath, conf.Package, fileName))
 	}
 	for _, v := range conf.Enums {
 		fileName, writeContent := GenEnum(conf.Package, conf.Alias, &v)
@@ -71,17 +45,17 @@ func genConf(srcFilePath, outPath string) {
 		GoFmt(fmt.Sprintf("%s%s/%s.go", outPath, conf.Package, fileName))
 	}
 	for _, v := range conf.Structs {
-		fileName, writeContent := GenStruct(conf.Package, conf.Alias, &v)
+		fileName, writeContent := GenStruct(conf.Package, conf.Alias, typMap, &v)
 		WriteToFile(fmt.Sprintf("%s%s/%s.go", outPath, conf.Package, fileName), writeContent)
 		GoFmt(fmt.Sprintf("%s%s/%s.go", outPath, conf.Package, fileName))
 	}
 	for _, v := range conf.Tables {
-		fileName, writeContent := GenTable(conf.Package, conf.Alias, &v)
+		fileName, writeContent := GenTable(conf.Package, conf.Alias, typMap, &v)
 		WriteToFile(fmt.Sprintf("%s%s/%s.go", outPath, conf.Package, fileName), writeContent)
 		GoFmt(fmt.Sprintf("%s%s/%s.go", outPath, conf.Package, fileName))
 	}
 
-	slog.Info("gen config", "file:", srcFilePath)
+	slog.Info("gen config", "package", conf.Package, "files", conf.Files)
 }
 
 func GoFmt(fileName string) {
@@ -114,10 +88,12 @@ func WriteToFile(fileName, content string) {
 	}
 }
 
-func GenStruct(packageName, packageAlias string, tStruct *utils.Struct) (string, string) {
+func GenStruct(packageName, packageAlias string, typMap map[string]utils.Meta, tStruct *utils.Struct) (string, string) {
 	var buffer strings.Builder
 	buffer.WriteString(GetPkgStr(packageName, packageAlias))
+	buffer.WriteString(fmt.Sprintf("import \"github.com/mogebingxue/game_config_manager\"\n\n"))
 	buffer.WriteString(GenStructWithoutPackage(tStruct))
+	buffer.WriteString(GenDecodeBinary(typMap, tStruct))
 	return tStruct.Name, buffer.String()
 }
 
@@ -126,29 +102,31 @@ func GenStructWithoutPackage(tStruct *utils.Struct) string {
 	buffer.WriteString(fmt.Sprintf("// %s\n", tStruct.Alias))
 	buffer.WriteString(fmt.Sprintf("type %s struct {\n", tStruct.Name))
 	for _, v := range tStruct.Vars {
+		var typ string
 		switch v.Typ {
-		case "int":
-			buffer.WriteString(fmt.Sprintf("\t%s int // %s\n", v.Name, v.Alias))
 		case "list":
-			switch v.ValueType {
-			default:
-				buffer.WriteString(fmt.Sprintf("\t%s []%s // %s\n", v.Name, v.ValueType, v.Alias))
-			}
+			typ = "[]" + v.ValueType
 		case "map":
-			switch v.ValueType {
-			default:
-				buffer.WriteString(fmt.Sprintf("\t%s map[string]%s // %s\n", v.Name, v.ValueType, v.Alias))
+			keyType := "string"
+			if v.KeyType == "int" {
+				keyType = "int"
 			}
+			typ = "map[" + keyType + "]" + v.ValueType
 		default:
-			buffer.WriteString(fmt.Sprintf("\t%s %s // %s\n", v.Name, v.Typ, v.Alias))
+			typ = v.Typ
 		}
-
+		//注解 go:tag 自定义结构体tag
+		tag := ""
+		if goTag, ok := v.Annotations.Lookup("go:tag"); ok {
+			tag = " `" + goTag + "`"
+		}
+		buffer.WriteString(fmt.Sprintf("\t%s %s%s // %s\n", v.Name, typ, tag, v.Alias))
 	}
 	buffer.WriteString(fmt.Sprintf("}\n"))
 	return buffer.String()
 }
 
-func GenTable(packageName, packageAlias string, tStruct *utils.Struct) (string, string) {
+func GenTable(packageName, packageAlias string, typMap map[string]utils.Meta, tStruct *utils.Struct) (string, string) {
 	fileName, structContent := tStruct.Name, GenStructWithoutPackage(tStruct)
 	var buffer strings.Builder
 
@@ -157,39 +135,100 @@ func GenTable(packageName, packageAlias string, tStruct *utils.Struct) (string,
 	buffer.WriteString(fmt.Sprintf("import \"github.com/mogebingxue/game_config_manager\"\n\n"))
 	//生成结构
 	buffer.WriteString(structContent)
-	//生成变量
-	buffer.WriteString(fmt.Sprintf("\nvar %s *%s\n", FirstToLower(fileName), fileName))
-	buffer.WriteString(fmt.Sprintf("var reload%s *%s\n", fileName, fileName))
+	//注册表，LoadAll时加载
+	buffer.WriteString(fmt.Sprintf("\nfunc init() {\n"))
+	buffer.WriteString(fmt.Sprintf("\tconfig.GetConfigManager().Register((*%s)(nil))\n", fileName))
+	buffer.WriteString(fmt.Sprintf("}\n"))
 	//生成基础接口
 	buffer.WriteString(fmt.Sprintf("\nfunc (cfg *%s) GetFileName() string {\n", fileName))
 	buffer.WriteString(fmt.Sprintf("\treturn \"%s/%s.json\"\n", packageName, fileName))
 	buffer.WriteString(fmt.Sprintf("}\n"))
-	buffer.WriteString(fmt.Sprintf("\nfunc (cfg *%s) GetResult() interface{} {\n", fileName))
-	buffer.WriteString(fmt.Sprintf("\treturn %s\n", FirstToLower(fileName)))
-	buffer.WriteString(fmt.Sprintf("}\n"))
-	buffer.WriteString(fmt.Sprintf("\nfunc (cfg *%s) GetReloadResult(alloc bool) interface{} {\n", fileName))
-	buffer.WriteString(fmt.Sprintf("\tif alloc || reload%s == nil {\n", fileName))
-	buffer.WriteString(fmt.Sprintf("\t\treload%s = new(%s)\n", fileName, fileName))
-	buffer.WriteString(fmt.Sprintf("\t}\n"))
-	buffer.WriteString(fmt.Sprintf("\treturn reload%s\n", fileName))
+	buffer.WriteString(fmt.Sprintf("\nfunc (cfg *%s) NewResult() interface{} {\n", fileName))
+	buffer.WriteString(fmt.Sprintf("\treturn new(%s)\n", fileName))
 	buffer.WriteString(fmt.Sprintf("}\n"))
-	buffer.WriteString(fmt.Sprintf("\nfunc (cfg *%s) OnReloadFinished() {\n", fileName))
-	buffer.WriteString(fmt.Sprintf("\t%s = reload%s\n", FirstToLower(fileName), fileName))
+	//生成二进制解码接口
+	buffer.WriteString(fmt.Sprintf("\nfunc (cfg *%s) GetSchemaHash() uint32 {\n", fileName))
+	buffer.WriteString(fmt.Sprintf("\treturn 0x%08x\n", utils.SchemaHash(typMap, tStruct)))
 	buffer.WriteString(fmt.Sprintf("}\n"))
-	//生成获取接口
+	buffer.WriteString(GenDecodeBinary(typMap, tStruct))
+	//生成获取接口，重新加载后返回新的数据，已经取得的数据不会被修改
 	buffer.WriteString(fmt.Sprintf("\nfunc Get%s() *%s {\n", fileName, fileName))
-	buffer.WriteString(fmt.Sprintf("\tif %s== nil {\n", FirstToLower(fileName)))
-	buffer.WriteString(fmt.Sprintf("\t\t%s = &%s{}\n", FirstToLower(fileName), fileName))
-	buffer.WriteString(fmt.Sprintf("\t\tconfig.GetConfigManager().LoadFile(%s)\n", FirstToLower(fileName)))
-	buffer.WriteString(fmt.Sprintf("\t}\n"))
-	buffer.WriteString(fmt.Sprintf("\tif config.GetConfigManager().IsDirty(%s.GetFileName()){\n", FirstToLower(fileName)))
-	buffer.WriteString(fmt.Sprintf("\t\tconfig.GetConfigManager().ReloadFile(%s)\n", FirstToLower(fileName)))
-	buffer.WriteString(fmt.Sprintf("\t}\n"))
-	buffer.WriteString(fmt.Sprintf("\treturn %s\n", FirstToLower(fileName)))
+	buffer.WriteString(fmt.Sprintf("\treturn Get%sFrom(config.GetConfigManager().Current())\n", fileName))
+	buffer.WriteString(fmt.Sprintf("}\n"))
+	buffer.WriteString(fmt.Sprintf("\n// Get%sFrom 返回指定版本中的数据，从同一个版本中读取的多个表是一致的\n", fileName))
+	buffer.WriteString(fmt.Sprintf("func Get%sFrom(gen *config.Generation) *%s {\n", fileName, fileName))
+	buffer.WriteString(fmt.Sprintf("\tcfg, _ := config.GetConfigManager().Get(gen, (*%s)(nil)).(*%s)\n", fileName, fileName))
+	buffer.WriteString(fmt.Sprintf("\treturn cfg\n"))
+	buffer.WriteString(fmt.Sprintf("}\n"))
+	//生成重新加载通知
+	buffer.WriteString(fmt.Sprintf("\n// On%sReloaded 重新加载成功后在监听goroutine中调用，文件删除并保留旧数据时不调用，返回取消订阅的函数\n", fileName))
+	buffer.WriteString(fmt.Sprintf("func On%sReloaded(fn func(oldCfg, newCfg *%s)) func() {\n", fileName, fileName))
+	buffer.WriteString(fmt.Sprintf("\treturn config.GetConfigManager().Subscribe(\"%s/%s.json\", func(event config.ReloadEvent) {\n", packageName, fileName))
+	buffer.WriteString(fmt.Sprintf("\t\tif event.Err != nil || event.New == nil {\n"))
+	buffer.WriteString(fmt.Sprintf("\t\t\treturn\n"))
+	buffer.WriteString(fmt.Sprintf("\t\t}\n"))
+	buffer.WriteString(fmt.Sprintf("\t\toldCfg, _ := event.Old.(*%s)\n", fileName))
+	buffer.WriteString(fmt.Sprintf("\t\tfn(oldCfg, event.New.(*%s))\n", fileName))
+	buffer.WriteString(fmt.Sprintf("\t})\n"))
 	buffer.WriteString(fmt.Sprintf("}\n"))
 	return fileName, buffer.String()
 }
 
+// GenDecodeBinary 按字段顺序生成二进制解码，和utils.EncodeBinary的编码顺序一致
+func GenDecodeBinary(typMap map[string]utils.Meta, tStruct *utils.Struct) string {
+	var buffer strings.Builder
+	buffer.WriteString(fmt.Sprintf("\nfunc (cfg *%s) DecodeBinary(r *config.BinaryReader) {\n", tStruct.Name))
+	for _, v := range tStruct.Vars {
+		target := "cfg." + v.Name
+		switch v.Typ {
+		case "list":
+			buffer.WriteString("\tif n := r.ReadLen(); n > 0 {\n")
+			buffer.WriteString(fmt.Sprintf("\t\t%s = make([]%s, n)\n", target, v.ValueType))
+			buffer.WriteString(fmt.Sprintf("\t\tfor i := range %s {\n", target))
+			buffer.WriteString(fmt.Sprintf("\t\t\t%s\n", genDecodeValue(typMap, v.ValueType, target+"[i]")))
+			buffer.WriteString("\t\t}\n")
+			buffer.WriteString("\t}\n")
+		case "map":
+			keyType, readKey := "string", "r.ReadString()"
+			if v.KeyType == "int" {
+				keyType, readKey = "int", "r.ReadInt()"
+			}
+			buffer.WriteString("\tif n := r.ReadLen(); n > 0 {\n")
+			buffer.WriteString(fmt.Sprintf("\t\t%s = make(map[%s]%s, n)\n", target, keyType, v.ValueType))
+			buffer.WriteString("\t\tfor i := 0; i < n; i++ {\n")
+			buffer.WriteString(fmt.Sprintf("\t\t\tk := %s\n", readKey))
+			if meta, ok := typMap[v.ValueType]; ok && meta.Typ == utils.STRUCT {
+				buffer.WriteString(fmt.Sprintf("\t\t\tvar v %s\n", v.ValueType))
+				buffer.WriteString("\t\t\tv.DecodeBinary(r)\n")
+			} else {
+				buffer.WriteString(fmt.Sprintf("\t\t\t%s\n", strings.Replace(genDecodeValue(typMap, v.ValueType, "v"), " = ", " := ", 1)))
+			}
+			buffer.WriteString(fmt.Sprintf("\t\t\t%s[k] = v\n", target))
+			buffer.WriteString("\t\t}\n")
+			buffer.WriteString("\t}\n")
+		default:
+			buffer.WriteString(fmt.Sprintf("\t%s\n", genDecodeValue(typMap, v.Typ, target)))
+		}
+	}
+	buffer.WriteString(fmt.Sprintf("}\n"))
+	return buffer.String()
+}
+
+func genDecodeValue(typMap map[string]utils.Meta, typ, target string) string {
+	switch typ {
+	case "int":
+		return target + " = r.ReadInt()"
+	case "string":
+		return target + " = r.ReadString()"
+	case "bool":
+		return target + " = r.ReadBool()"
+	}
+	if meta, ok := typMap[typ]; ok && meta.Typ == utils.ENUM {
+		return fmt.Sprintf("%s = %s(r.ReadInt())", target, typ)
+	}
+	return target + ".DecodeBinary(r)"
+}
+
 func FirstToLower(str string) string {
 	if len(str) == 0 {
 		return str
@@ -198,7 +237,7 @@ func FirstToLower(str string) string {
 }
 
 func GetPkgStr(packageName, packageAlias string) string {
-	return fmt.Sprintf("// Code generated by gen_cfg_go. DO NOT EDIT.\n\n//%s\n package %s\n\n ", packageAlias, packageName)
+	return fmt.Sprintf("// Code generated by gen_cfg_go. DO NOT EDIT.\n\n// %s\n package %s\n\n ", packageAlias, packageName)
 }
 
 func GenEnum(packageName, packageAlias string, enum *utils.Enum) (string, string) {
@@ -213,3 +252,20 @@ func GenEnum(packageName, packageAlias string, enum *utils.Enum) (string, string
 	buffer.WriteString(fmt.Sprintf(")\n"))
 	return enum.Name, buffer.String()
 }
+
+// GenConst 包内所有常量生成到一个文件
+func GenConst(packageName, packageAlias string, consts []utils.Const) (string, string) {
+	var buffer strings.Builder
+	buffer.WriteString(GetPkgStr(packageName, packageAlias))
+	buffer.WriteString(fmt.Sprintf("const (\n"))
+	for _, v := range consts {
+		//解析元数据时已经检查过
+		value, _ := v.NormalizedValue()
+		if v.Typ == "string" {
+			value = strconv.Quote(v.Value)
+		}
+		buffer.WriteString(fmt.Sprintf("\t%s %s = %s // %s\n", v.Name, v.Typ, value, v.Alias))
+	}
+	buffer.WriteString(fmt.Sprintf(")\n"))
+	return "consts", buffer.String()
+}
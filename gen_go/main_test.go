@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/xml"
+	"go/format"
+	"strings"
+	"testing"
+
+	"github.com/mogebingxue/game_config_manager/utils"
+)
+
+func TestGenTableIntMap(t *testing.T) {
+	conf := &utils.Conf{}
+	err := xml.Unmarshal([]byte(`<conf package="p">
+	<enum name="E"><var name="A" default="1"/></enum>
+	<struct name="S"><var name="X" type="int"/></struct>
+	<table name="T">
+		<var name="N" type="int" required="true"/>
+		<var name="IntMap" type="map" keyType="int" valueType="S"/>
+		<var name="IntEnumMap" type="map" keyType="int" valueType="E"/>
+		<var name="StrMap" type="map" valueType="int"/>
+	</table>
+</conf>`), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err, typMap := utils.CheckConfValid(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, content := GenTable(conf.Package, conf.Alias, typMap, &conf.Tables[0])
+	if _, err := format.Source([]byte(content)); err != nil {
+		t.Fatalf("generated code: %v\n%s", err, content)
+	}
+	for _, want := range []string{
+		"IntMap map[int]S",
+		"IntEnumMap map[int]E",
+		"StrMap map[string]int",
+		"cfg.IntMap = make(map[int]S, n)",
+		"cfg.IntEnumMap = make(map[int]E, n)",
+		"cfg.StrMap = make(map[string]int, n)",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("generated code does not contain %q\n%s", want, content)
+		}
+	}
+	//int的key用ReadInt解码，string的key用ReadString
+	if got := strings.Count(content, "k := r.ReadInt()"); got != 2 {
+		t.Errorf("ReadInt keys %d, want 2", got)
+	}
+	if got := strings.Count(content, "k := r.ReadString()"); got != 1 {
+		t.Errorf("ReadString keys %d, want 1", got)
+	}
+}
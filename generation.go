@@ -0,0 +1,263 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/mogebingxue/game_config_manager/datafile"
+)
+
+const defaultHistoryKeep = 10
+
+// ErrReloadRolledBack 事务重新加载时同一批中其他表失败，这个表没有发布
+var ErrReloadRolledBack = errors.New("reload rolled back")
+
+// ErrGenerationNotFound 回滚的版本不在保留的历史版本中
+var ErrGenerationNotFound = errors.New("generation not found")
+
+// HistoryConfig 历史版本，用于运行时回滚和启动时回退
+type HistoryConfig struct {
+	Keep         int    `yaml:"keep"`           //保留最近几个成功加载的版本，默认10
+	LastGoodPath string `yaml:"last_good_path"` //保存最后一次成功加载的数据的目录，启动时加载失败的表从这里加载，为空时不保存
+}
+
+func (h HistoryConfig) keep() int {
+	if h.Keep <= 0 {
+		return defaultHistoryKeep
+	}
+	return h.Keep
+}
+
+// Generation 一次发布的所有表的数据，发布后不再修改，同一批重新加载的表在同一个版本中发布
+type Generation struct {
+	Version uint64
+	Time    time.Time              //发布时间
+	tables  map[string]interface{} //key：文件名
+	infos   map[string]tableInfo   //key：文件名
+}
+
+// tableInfo 表数据的发布信息
+type tableInfo struct {
+	version uint64    //第一次发布这份数据的版本
+	time    time.Time //第一次发布这份数据的时间
+	hash    string    //加载的原始数据的sha256
+	source  []byte    //加载的原始数据，设置了last_good_path时保留，发布后保存
+}
+
+// sourceInfo 原始数据的校验值，设置了last_good_path时保留原始数据；没有原始数据(例如删除后发布的空数据)时为空
+func (m *ConfigManager) sourceInfo(source []byte) tableInfo {
+	if source == nil {
+		return tableInfo{}
+	}
+	sum := sha256.Sum256(source)
+	info := tableInfo{hash: hex.EncodeToString(sum[:])}
+	if m.history.LastGoodPath != "" {
+		info.source = source
+	}
+	return info
+}
+
+// Get 返回版本中的数据，表还没有加载时返回false
+func (g *Generation) Get(fileName string) (interface{}, bool) {
+	v, ok := g.tables[fileName]
+	return v, ok
+}
+
+// FileNames 版本中所有表的文件名，按文件名排序
+func (g *Generation) FileNames() []string {
+	fileNames := make([]string, 0, len(g.tables))
+	for fileName := range g.tables {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+	return fileNames
+}
+
+// Hash 表加载的原始数据(json、合并overlay后的json或bin)的sha256，数据相同的两个版本中相同
+func (g *Generation) Hash(fileName string) string {
+	return g.infos[fileName].hash
+}
+
+// Published 表的数据第一次发布的版本和时间，之后没有重新加载的版本中保持不变
+func (g *Generation) Published(fileName string) (version uint64, t time.Time) {
+	info := g.infos[fileName]
+	return info.version, info.time
+}
+
+// with 复制一份并替换results中的表，数据没有变化的表保留原来的发布信息，infos为新数据的校验值和原始数据
+func (g *Generation) with(results map[string]interface{}, infos map[string]tableInfo) *Generation {
+	gen := &Generation{
+		Version: g.Version + 1,
+		Time:    time.Now(),
+		tables:  make(map[string]interface{}, len(g.tables)+len(results)),
+		infos:   make(map[string]tableInfo, len(g.infos)+len(results)),
+	}
+	for k, v := range g.tables {
+		gen.tables[k] = v
+	}
+	for k, v := range g.infos {
+		gen.infos[k] = v
+	}
+	for k, v := range results {
+		if old, ok := g.tables[k]; ok && old == v {
+			continue
+		}
+		info := infos[k]
+		info.version, info.time = gen.Version, gen.Time
+		gen.tables[k] = v
+		gen.infos[k] = info
+	}
+	return gen
+}
+
+// IValidate 同一批表都加载完成后、发布之前调用，gen为将要发布的版本，可以用生成的GetXxxFrom(gen)检查引用的其他表
+type IValidate interface {
+	Validate(gen *Generation) error
+}
+
+// SetHistoryConfig 设置历史版本，在StartService之前调用
+func (m *ConfigManager) SetHistoryConfig(history HistoryConfig) {
+	m.history = history
+}
+
+// Current 当前发布的版本，读取时不加锁；需要同时读取多个一致的表时先取得版本再用GetXxxFrom读取
+func (m *ConfigManager) Current() *Generation {
+	return m.current.Load()
+}
+
+// Get 返回版本中的表，生成的GetXxx使用；表还没有加载时加载并发布后从当前版本中返回，严格模式下加载失败时panic
+func (m *ConfigManager) Get(gen *Generation, receiver IConfig) interface{} {
+	fileName := receiver.GetFileName()
+	if v, ok := gen.tables[fileName]; ok {
+		return v
+	}
+	if err := m.LoadFile(receiver); err != nil && m.strict {
+		panic(err)
+	}
+	v, _ := m.Current().Get(fileName)
+	return v
+}
+
+// Generations 保留的成功加载的版本，从旧到新
+func (m *ConfigManager) Generations() []*Generation {
+	m.publishMu.Lock()
+	defer m.publishMu.Unlock()
+	return append([]*Generation(nil), m.generations...)
+}
+
+// Rollback 把指定版本中的所有表作为新的版本发布，之后加载的表保持不变，订阅者收到数据变化的表的事件
+// 文件再次修改时仍然会重新加载；不能在订阅回调中调用
+func (m *ConfigManager) Rollback(version uint64) error {
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+	var target *Generation
+	for _, gen := range m.Generations() {
+		if gen.Version == version {
+			target = gen
+		}
+	}
+	if target == nil {
+		return fmt.Errorf("%w: %d", ErrGenerationNotFound, version)
+	}
+	old, gen := m.publish(target.tables, target.infos, true)
+	m.metrics.observeRollback()
+	var events []ReloadEvent
+	for _, fileName := range target.FileNames() {
+		oldResult, _ := old.Get(fileName)
+		if newResult := target.tables[fileName]; oldResult != newResult {
+			m.audit.record("rollback", fileName, old, gen, 0, nil)
+			events = append(events, ReloadEvent{FileName: fileName, Old: oldResult, New: newResult})
+		}
+	}
+	slog.Info("config rollback", "version", version, "tables", len(events))
+	m.notify(events)
+	return nil
+}
+
+// publish 把results合并到当前版本后发布，返回发布前和发布后的版本；good为true时加入历史版本，
+// 释放锁之后把新发布的原始数据保存到last_good_path。同一个表的发布由reloadMu或表的锁串行执行，保存的顺序和发布一致
+func (m *ConfigManager) publish(results map[string]interface{}, infos map[string]tableInfo, good bool) (old, gen *Generation) {
+	m.publishMu.Lock()
+	old = m.current.Load()
+	gen = old.with(results, infos)
+	m.current.Store(gen)
+	if good {
+		m.generations = append(m.generations, gen)
+		if over := len(m.generations) - m.history.keep(); over > 0 {
+			m.generations = append([]*Generation(nil), m.generations[over:]...)
+		}
+	}
+	m.publishMu.Unlock()
+
+	if !good {
+		return old, gen
+	}
+	for fileName := range results {
+		info := gen.infos[fileName]
+		if info.version != gen.Version || info.source == nil {
+			continue
+		}
+		if err := m.saveLastGood(fileName, info.source); err != nil {
+			slog.Warn("config save last good failed:", "fileName", fileName, "err", err)
+		}
+	}
+	return old, gen
+}
+
+// saveLastGood 先写临时文件再改名，进程中断时不会留下不完整的文件
+func (m *ConfigManager) saveLastGood(fileName string, data []byte) error {
+	path := filepath.Join(m.history.LastGoodPath, fileName)
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// loadLastGood 从last_good_path加载最后一次成功加载的原始数据，返回数据和原始数据
+func (m *ConfigManager) loadLastGood(receiver IConfig) (interface{}, []byte, error) {
+	if m.history.LastGoodPath == "" {
+		return nil, nil, errors.New("last_good_path not set")
+	}
+	data, err := os.ReadFile(filepath.Join(m.history.LastGoodPath, receiver.GetFileName()))
+	if err != nil {
+		return nil, nil, err
+	}
+	result := receiver.NewResult()
+	//从bin加载的表保存的是bin文件
+	if binReceiver, ok := result.(IBinaryConfig); ok && bytes.HasPrefix(data, []byte(datafile.BinaryMagic)) {
+		err = DecodeBinary(data, binReceiver)
+	} else {
+		err = decodeJSON(data, result)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if mod, ok := result.(IAfterLoad); ok {
+		if err := mod.AfterLoad(); err != nil {
+			return nil, nil, fmt.Errorf("after load: %w", err)
+		}
+	}
+	return result, data, nil
+}
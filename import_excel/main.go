@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mogebingxue/game_config_manager"
+	"github.com/mogebingxue/game_config_manager/utils"
+	"github.com/xuri/excelize/v2"
+)
+
+// 把xlsx或csv表格导入为json数据文件
+// 每个sheet对应一个表，sheet名(csv为文件名)是表名或表的别名
+// 第一行是字段路径，结构体用点分隔，例如 TestStruct.TestSubStruct.TestInt；第二行可以是别名，导入时跳过
+// 用法：go run ./import_excel [-pkg testpkg] [-merge] a.xlsx b.csv
+func main() {
+	confPath := flag.String("conf", "./conf.yaml", "配置文件")
+	pkg := flag.String("pkg", "", "包名，为空时按表名在所有包中查找")
+	merge := flag.Bool("merge", false, "在已有数据上修改，表格中没有的字段保留原值")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	cfg, err := config.LoadConfig(*confPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	schema, err := utils.LoadSchema(cfg.MetadataPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "load metadata:", err)
+		os.Exit(2)
+	}
+	failed := false
+	for _, path := range flag.Args() {
+		im := &importer{schema: schema, pkg: *pkg, merge: *merge, dataPath: cfg.DataPath}
+		if im.pkg == "" {
+			im.pkg = guessPackage(schema, path)
+		}
+		sheets, err := readSheets(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, path, err)
+			failed = true
+			continue
+		}
+		for _, sheet := range sheets {
+			if err := im.importSheet(sheet); err != nil {
+				fmt.Fprintf(os.Stderr, "%s[%s]: %v\n", path, sheet.Name, err)
+				failed = true
+			}
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// guessPackage export_excel导出的文件名(xlsx)或者所在目录名(csv)是包名
+func guessPackage(schema *utils.Schema, path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if strings.ToLower(filepath.Ext(path)) == ".csv" {
+		name = filepath.Base(filepath.Dir(path))
+	}
+	if _, ok := schema.Package(name); ok {
+		return name
+	}
+	return ""
+}
+
+type sheet struct {
+	Name string
+	Rows [][]string
+}
+
+// readSheets 读取xlsx的所有sheet或者csv文件
+func readSheets(path string) ([]sheet, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		reader := csv.NewReader(file)
+		reader.FieldsPerRecord = -1
+		rows, err := reader.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		//excel导出的csv带BOM
+		if len(rows) > 0 && len(rows[0]) > 0 {
+			rows[0][0] = strings.TrimPrefix(rows[0][0], "\ufeff")
+		}
+		return []sheet{{Name: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)), Rows: rows}}, nil
+	case ".xlsx":
+		f, err := excelize.OpenFile(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		var sheets []sheet
+		for _, name := range f.GetSheetList() {
+			rows, err := f.GetRows(name)
+			if err != nil {
+				return nil, err
+			}
+			sheets = append(sheets, sheet{Name: name, Rows: rows})
+		}
+		return sheets, nil
+	}
+	return nil, errors.New("only support .xlsx and .csv")
+}
+
+type importer struct {
+	schema   *utils.Schema
+	pkg      string
+	merge    bool
+	dataPath string
+}
+
+// findTable 按表名或别名查找表
+func (im *importer) findTable(name string) (string, *utils.Struct, error) {
+	pkgs := im.schema.Packages()
+	if im.pkg != "" {
+		pkgs = []string{im.pkg}
+	}
+	var foundPkg string
+	var found *utils.Struct
+	for _, pkg := range pkgs {
+		for _, table := range im.schema.Tables(pkg) {
+			if table.Name != name && table.Alias != name {
+				continue
+			}
+			if found != nil {
+				return "", nil, fmt.Errorf("table %s found in %s and %s, use -pkg", name, foundPkg, pkg)
+			}
+			foundPkg, found = pkg, &table
+		}
+	}
+	if found == nil {
+		return "", nil, fmt.Errorf("table %s not found", name)
+	}
+	return foundPkg, found, nil
+}
+
+func (im *importer) importSheet(s sheet) error {
+	pkg, table, err := im.findTable(s.Name)
+	if err != nil {
+		return err
+	}
+	typMap := im.schema.TypeMap(pkg)
+	if len(s.Rows) == 0 {
+		return errors.New("header not found")
+	}
+	header, rows := s.Rows[0], s.Rows[1:]
+	if len(rows) > 0 && isAliasRow(typMap, table, header, rows[0]) {
+		rows = rows[1:]
+	}
+	var dataRows [][]string
+	for _, row := range rows {
+		if !isEmptyRow(row) {
+			dataRows = append(dataRows, row)
+		}
+	}
+	if len(dataRows) != 1 {
+		return fmt.Errorf("expect 1 data row, got %d", len(dataRows))
+	}
+
+	fileName := filepath.Join(im.dataPath, utils.DataFileName(pkg, table.Name))
+	var base map[string]any
+	if im.merge {
+		if content, err := os.ReadFile(fileName); err == nil {
+			if err := json.Unmarshal(content, &base); err != nil {
+				return fmt.Errorf("%s: %w", fileName, err)
+			}
+		}
+	}
+	data, err := utils.ParseSheetRow(typMap, table, header, dataRows[0], base)
+	if err != nil {
+		return err
+	}
+	if errs := utils.ValidateData(typMap, table, fileName, data); len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	content := utils.FormatData(typMap, table, data)
+	if err := os.MkdirAll(filepath.Dir(fileName), os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.WriteFile(fileName, content, 0644); err != nil {
+		return err
+	}
+	fmt.Println("import", s.Name, "->", fileName)
+	return nil
+}
+
+// isAliasRow 第二行每一列都是字段的别名时认为是别名行
+func isAliasRow(typMap map[string]utils.Meta, table *utils.Struct, header, row []string) bool {
+	matched := false
+	for i, path := range header {
+		if strings.TrimSpace(path) == "" || i >= len(row) || row[i] == "" {
+			continue
+		}
+		column, err := utils.ResolveSheetColumn(typMap, table, strings.TrimSpace(path))
+		if err != nil || column.Alias != strings.TrimSpace(row[i]) {
+			return false
+		}
+		matched = true
+	}
+	return matched
+}
+
+func isEmptyRow(row []string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mogebingxue/game_config_manager/utils"
+	"github.com/xuri/excelize/v2"
+)
+
+const importTestXml = `<conf package="p">
+	<enum name="E"><var name="A" default="1" alias="甲"/><var name="B" default="2" alias="乙"/></enum>
+	<struct name="S"><var name="X" type="int" alias="横"/></struct>
+	<table name="T" alias="测试表">
+		<var name="N" type="int" alias="数量" min="0" required="true"/>
+		<var name="E" type="E" alias="枚举"/>
+		<var name="S" type="S" alias="结构"/>
+	</table>
+	<table name="Dup" alias="重名表">
+		<var name="N" type="int"/>
+	</table>
+</conf>`
+
+func newTestImporter(t *testing.T) (*importer, string) {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"metadata/p.xml": importTestXml,
+		"metadata/q.xml": `<conf package="q"><table name="Dup"><var name="N" type="int"/></table></conf>`,
+	}
+	for name, content := range files {
+		writeTestFile(t, filepath.Join(dir, name), content)
+	}
+	schema, err := utils.LoadSchema(filepath.Join(dir, "metadata"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &importer{schema: schema, dataPath: filepath.Join(dir, "data")}, dir
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestData(t *testing.T, path string) map[string]any {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make(map[string]any)
+	if err := json.Unmarshal(content, &data); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestImportCsvAlias(t *testing.T) {
+	im, dir := newTestImporter(t)
+	//csv文件名是表的别名，第二行是别名行，枚举可以填别名，excel导出的csv带BOM
+	path := filepath.Join(dir, "p", "测试表.csv")
+	writeTestFile(t, path, "\ufeffN,E,S.X\n数量,枚举,结构.横\n3,乙,5\n,,\n")
+	im.pkg = guessPackage(im.schema, path)
+	if im.pkg != "p" {
+		t.Fatalf("guess package %q", im.pkg)
+	}
+	sheets, err := readSheets(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sheets) != 1 || sheets[0].Name != "测试表" {
+		t.Fatalf("sheets %v", sheets)
+	}
+	if err := im.importSheet(sheets[0]); err != nil {
+		t.Fatal(err)
+	}
+	got := readTestData(t, filepath.Join(im.dataPath, "p", "T.json"))
+	want := map[string]any{"N": 3.0, "E": 2.0, "S": map[string]any{"X": 5.0}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestImportXlsxAlias(t *testing.T) {
+	im, dir := newTestImporter(t)
+	path := filepath.Join(dir, "p.xlsx")
+	f := excelize.NewFile()
+	//sheet名可以是表名或别名，没有别名行也可以
+	if err := f.SetSheetName("Sheet1", "测试表"); err != nil {
+		t.Fatal(err)
+	}
+	for i, row := range [][]any{{"N", "E"}, {"数量", "枚举"}, {1, "甲"}} {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := f.SetSheetRow("测试表", cell, &row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := f.NewSheet("Dup"); err != nil {
+		t.Fatal(err)
+	}
+	for i, row := range [][]any{{"N"}, {7}} {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := f.SetSheetRow("Dup", cell, &row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+	im.pkg = guessPackage(im.schema, path)
+	sheets, err := readSheets(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range sheets {
+		if err := im.importSheet(s); err != nil {
+			t.Fatalf("%s: %v", s.Name, err)
+		}
+	}
+	if got, want := readTestData(t, filepath.Join(im.dataPath, "p", "T.json")), map[string]any{"N": 1.0, "E": 1.0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("T got %v, want %v", got, want)
+	}
+	if got, want := readTestData(t, filepath.Join(im.dataPath, "p", "Dup.json")), map[string]any{"N": 7.0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Dup got %v, want %v", got, want)
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		pkg   string
+		sheet sheet
+		want  string
+	}{
+		{"unknown table", "", sheet{Name: "未知表", Rows: [][]string{{"N"}, {"1"}}}, "table 未知表 not found"},
+		{"unknown table in package", "q", sheet{Name: "测试表", Rows: [][]string{{"N"}, {"1"}}}, "table 测试表 not found"},
+		{"ambiguous table", "", sheet{Name: "Dup", Rows: [][]string{{"N"}, {"1"}}}, "found in p and q, use -pkg"},
+		{"no header", "", sheet{Name: "T"}, "header not found"},
+		{"no data row", "", sheet{Name: "T", Rows: [][]string{{"N"}, {"数量"}}}, "expect 1 data row, got 0"},
+		{"two data rows", "", sheet{Name: "T", Rows: [][]string{{"N"}, {"1"}, {"2"}}}, "expect 1 data row, got 2"},
+		{"unknown column", "", sheet{Name: "T", Rows: [][]string{{"M"}, {"1"}}}, "M"},
+		{"unknown enum alias", "", sheet{Name: "T", Rows: [][]string{{"N", "E"}, {"1", "丙"}}}, "丙"},
+		{"missing required", "", sheet{Name: "T", Rows: [][]string{{"E"}, {"甲"}}}, "missing required field N"},
+		{"out of range", "", sheet{Name: "测试表", Rows: [][]string{{"N"}, {"-1"}}}, "#/N"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			im, _ := newTestImporter(t)
+			im.pkg = tt.pkg
+			err := im.importSheet(tt.sheet)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+			//失败时不写数据文件
+			if _, err := os.Stat(im.dataPath); !os.IsNotExist(err) {
+				t.Errorf("data written: %v", err)
+			}
+		})
+	}
+
+	if _, err := readSheets("a.txt"); err == nil {
+		t.Error("txt: want error")
+	}
+}
@@ -3,140 +3,601 @@ package config
 import (
 	"encoding/json"
 	"errors"
+	"expvar"
+	"fmt"
+	"io/fs"
 	"log/slog"
-	"os"
-	"path/filepath"
-	"strings"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/mogebingxue/game_config_manager/datafile"
 )
 
+// ErrTableNotFound 文件名没有注册
+var ErrTableNotFound = errors.New("table not found")
+
 var instance *ConfigManager
-var luaCfgManagerOnce sync.Once
+var cfgManagerOnce sync.Once
 
 func GetConfigManager() *ConfigManager {
-	luaCfgManagerOnce.Do(func() {
-		instance = &ConfigManager{
-			scanDetail: make(map[string]int64),
-			fileStat:   make(map[string]interface{}),
-		}
+	cfgManagerOnce.Do(func() {
+		instance = newConfigManager()
+		expvar.Publish("game_config", expvar.Func(instance.expvarMetrics))
 	})
 	return instance
 }
 
+func newConfigManager() *ConfigManager {
+	m := &ConfigManager{
+		tables:   make(map[string]*loadedTable),
+		loadErrs: make(map[string]loadError),
+		requests: make(chan func()),
+		dirty:    make(map[string]struct{}),
+	}
+	m.current.Store(&Generation{tables: map[string]interface{}{}})
+	return m
+}
+
+// IConfig 生成的表实现，数据加载完成后在新的版本中发布，读取时不加锁
 type IConfig interface {
 	GetFileName() string    // 指定 对应配置文件名
-	GetResult() interface{} // 指定 数据存储集合
+	NewResult() interface{} // 新建数据，加载完成前不会被读取
 }
 type IAfterLoad interface {
 	AfterLoad() error
 }
-type IReloadConfig interface {
-	GetReloadResult(alloc bool) interface{}
-	OnReloadFinished()
-}
 
 type ConfigManager struct {
-	basePath   string
-	scanDetail map[string]int64       //key：文件名；value：文件修改时间
-	fileStat   map[string]interface{} //key：修改过的文件名
+	base     Source         //基础数据
+	overlays []Source       //overlay数据，按顺序合并到基础数据上
+	sources  []*sourceState //基础数据和overlay数据的扫描状态
+	watch    WatchConfig    //监听数据来源的方式
+
+	strict      bool
+	history     HistoryConfig
+	current     atomic.Pointer[Generation] //当前发布的版本
+	publishMu   sync.Mutex                 //保护generations，发布的顺序
+	generations []*Generation              //成功加载的历史版本，从旧到新
+	reloadMu    sync.Mutex                 //重新加载和回滚串行执行，保证通知的顺序
+
+	tablesMu sync.Mutex
+	tables   map[string]*loadedTable //key：文件名，获取过的表，文件修改时重新加载
+
+	errMu    sync.Mutex
+	loadErrs map[string]loadError //key：文件名，最后一次加载失败的错误，加载成功后清除
+
+	metrics metrics
+	audit   auditLog
+
+	subMu       sync.Mutex
+	subscribers []*subscriber //按订阅顺序通知
+	nextSubID   int
+
+	watching atomic.Bool //监听goroutine已经启动
+	requests chan func() //在监听goroutine中执行的请求，例如管理接口的重新加载和回滚
+
+	//只在监听goroutine中使用
+	dirty map[string]struct{} //key：修改过的文件名
+}
+
+// Register 注册表，生成的代码在init中调用，LoadAll加载所有注册的表
+func (m *ConfigManager) Register(receiver IConfig) {
+	m.register(receiver)
+}
+
+func (m *ConfigManager) register(receiver IConfig) *loadedTable {
+	fileName := receiver.GetFileName()
+	m.tablesMu.Lock()
+	defer m.tablesMu.Unlock()
+	table, ok := m.tables[fileName]
+	if !ok {
+		table = &loadedTable{receiver: receiver}
+		m.tables[fileName] = table
+	}
+	return table
+}
+
+// SetStrict 严格模式：加载失败的表不回退到last_good_path也不发布空数据，LoadAll返回错误时不应该继续启动，
+// 没有预先加载的表在GetXxx中加载失败时panic
+func (m *ConfigManager) SetStrict(strict bool) {
+	m.strict = strict
+}
+
+// LoadAll 并行加载所有注册的表，在StartService之后、开始服务之前调用，返回所有失败的表的错误
+// 并行加载时AfterLoad中不要互相获取对方的表，跨表的检查放到Validate中
+func (m *ConfigManager) LoadAll() error {
+	tables := m.registered()
+	errs := make([]error, len(tables))
+	var wg sync.WaitGroup
+	for i, table := range tables {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = m.loadTable(table)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// LoadFile 加载并发布数据，已经加载过时返回第一次加载的错误，之后由监听goroutine重新加载
+func (m *ConfigManager) LoadFile(receiver IConfig) error {
+	return m.loadTable(m.register(receiver))
 }
 
-func (m *ConfigManager) LoadFile(receiver IConfig) {
-	file, err := m.loadDataFromFile(receiver.GetFileName(), receiver)
+// loadTable 加载或校验失败时回退到最后一次成功加载的数据，没有时发布空数据，不发布AfterLoad或校验失败的数据；
+// 错误记录在loadErrs中，严格模式下不发布
+func (m *ConfigManager) loadTable(table *loadedTable) error {
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	//其他goroutine已经加载
+	if table.loaded {
+		return table.err
+	}
+	receiver := table.receiver
+	fileName := receiver.GetFileName()
+	result, info, elapsed, err := m.load(receiver)
+	if err == nil {
+		err = m.validate(m.Current().with(map[string]interface{}{fileName: result}, nil), result)
+	}
+	good := err == nil
+	m.setLoadError(fileName, err)
 	if err != nil {
-		slog.Error("config load failed:", "fileName", receiver.GetFileName(), "err", err)
+		slog.Error("config load failed:", "fileName", fileName, "err", err)
+		err = fmt.Errorf("%s: %w", fileName, err)
+		if m.strict {
+			m.audit.record("load", fileName, m.Current(), nil, elapsed, err)
+			return err
+		}
+		//回退到最后一次成功加载的数据
+		if lastGood, source, lastGoodErr := m.loadLastGood(receiver); lastGoodErr == nil {
+			slog.Warn("config load last good", "fileName", fileName)
+			result, info, good = lastGood, m.sourceInfo(source), true
+		} else {
+			slog.Warn("config publish empty data", "fileName", fileName)
+			result, info = receiver.NewResult(), tableInfo{}
+		}
+	}
+	old, gen := m.publish(map[string]interface{}{fileName: result}, map[string]tableInfo{fileName: info}, good)
+	m.audit.record("load", fileName, old, gen, elapsed, err)
+	table.loaded, table.err = true, err
+	return err
+}
+
+// loadedTable 注册或获取过的表，每个表单独加锁，AfterLoad中可以获取其他表
+type loadedTable struct {
+	mu       sync.Mutex //第一次加载完成前重新加载需要等待
+	receiver IConfig
+	loaded   bool
+	err      error //第一次加载的错误
+}
+
+func (t *loadedTable) isLoaded() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.loaded
+}
+
+// loadError 加载或重新加载失败的错误
+type loadError struct {
+	err  error
+	time time.Time
+}
+
+// setLoadError 记录加载或重新加载的结果，失败时计入指标，err为空时清除
+func (m *ConfigManager) setLoadError(fileName string, err error) {
+	if err != nil {
+		m.metrics.observeFailure(fileName)
+	}
+	m.errMu.Lock()
+	defer m.errMu.Unlock()
+	if err == nil {
+		delete(m.loadErrs, fileName)
 		return
 	}
-	err = json.Unmarshal(file, receiver.GetResult())
+	m.loadErrs[fileName] = loadError{err: err, time: time.Now()}
+}
+
+func (m *ConfigManager) loadError(fileName string) loadError {
+	m.errMu.Lock()
+	defer m.errMu.Unlock()
+	return m.loadErrs[fileName]
+}
+
+// registered 所有注册或获取过的表，按文件名排序
+func (m *ConfigManager) registered() []*loadedTable {
+	m.tablesMu.Lock()
+	tables := make([]*loadedTable, 0, len(m.tables))
+	for _, table := range m.tables {
+		tables = append(tables, table)
+	}
+	m.tablesMu.Unlock()
+	sort.Slice(tables, func(i, j int) bool {
+		return tables[i].receiver.GetFileName() < tables[j].receiver.GetFileName()
+	})
+	return tables
+}
+
+// lookup fileNames为空时返回所有注册的表，有没有注册的文件时返回ErrTableNotFound
+func (m *ConfigManager) lookup(fileNames []string) ([]*loadedTable, error) {
+	if len(fileNames) == 0 {
+		return m.registered(), nil
+	}
+	m.tablesMu.Lock()
+	defer m.tablesMu.Unlock()
+	tables := make([]*loadedTable, 0, len(fileNames))
+	for _, fileName := range fileNames {
+		table, ok := m.tables[fileName]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrTableNotFound, fileName)
+		}
+		tables = append(tables, table)
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		return tables[i].receiver.GetFileName() < tables[j].receiver.GetFileName()
+	})
+	return tables, nil
+}
+
+// load 加载到新的数据中，AfterLoad失败时同时返回数据和错误；返回原始数据的校验值和加载的耗时，耗时记录到指标中
+func (m *ConfigManager) load(receiver IConfig) (interface{}, tableInfo, time.Duration, error) {
+	start := time.Now()
+	result := receiver.NewResult()
+	source, n, err := m.loadDataFromFile(receiver.GetFileName(), result)
 	if err != nil {
-		slog.Error("config load failed:", "fileName", receiver.GetFileName(), "err", err)
+		result = nil
+	} else if mod, ok := result.(IAfterLoad); ok {
+		if err = mod.AfterLoad(); err != nil {
+			err = fmt.Errorf("after load: %w", err)
+		}
+	}
+	elapsed := time.Since(start)
+	m.metrics.observeLoad(receiver.GetFileName(), elapsed, n)
+	return result, m.sourceInfo(source), elapsed, err
+}
+
+func (m *ConfigManager) validate(gen *Generation, result interface{}) error {
+	if v, ok := result.(IValidate); ok {
+		if err := v.Validate(gen); err != nil {
+			return fmt.Errorf("validate: %w", err)
+		}
+	}
+	return nil
+}
+
+// reloadDirty 在监听goroutine中重新加载修改过的表，没有加载过的表在第一次获取时加载
+func (m *ConfigManager) reloadDirty() {
+	if len(m.dirty) == 0 {
 		return
 	}
-	if mod, ok := receiver.GetResult().(IAfterLoad); ok {
-		if err := mod.AfterLoad(); err != nil {
-			slog.Error("config after load failed:", "fileName", receiver.GetFileName(), "err", err)
-			return
+	fileNames := make([]string, 0, len(m.dirty))
+	for fileName := range m.dirty {
+		m.tablesMu.Lock()
+		_, ok := m.tables[fileName]
+		m.tablesMu.Unlock()
+		if ok {
+			fileNames = append(fileNames, fileName)
 		}
 	}
+	m.dirty = make(map[string]struct{})
+	if len(fileNames) > 0 {
+		m.Reload(fileNames...)
+	}
 }
 
-func (m *ConfigManager) loadDataFromFile(fileName string, receiver interface{}) ([]byte, error) {
-	data, err := os.ReadFile(m.basePath + fileName)
+// Reload 重新加载已经加载过的表，fileNames为空时重新加载所有已经加载过的表，返回所有失败的表的错误；不能在订阅回调中调用
+// 同一批的表都加载完成后一起校验，成功的表在同一个版本中发布，失败的表保留旧数据；
+// 事务模式下有一个表失败时整批都不发布。文件被删除时不加载，按IClearOnDelete发布空数据或保留旧数据。
+// 发布后按文件名顺序通知订阅者，通知时不持有锁
+func (m *ConfigManager) Reload(fileNames ...string) error {
+	tables, err := m.lookup(fileNames)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+	var receivers, loading []IConfig
+	deleted := make(map[string]bool)
+	for _, table := range tables {
+		if !table.isLoaded() {
+			continue
+		}
+		receivers = append(receivers, table.receiver)
+		if m.exists(table.receiver.GetFileName()) {
+			loading = append(loading, table.receiver)
+		} else {
+			deleted[table.receiver.GetFileName()] = true
+		}
+	}
+	if len(receivers) == 0 {
+		return nil
+	}
+
+	results, infos, elapsed, errs := m.loadBatch(loading)
+	//文件删除时按IClearOnDelete发布空数据或保留旧数据，不算失败
+	for _, receiver := range receivers {
+		if !deleted[receiver.GetFileName()] {
+			continue
+		}
+		if result := receiver.NewResult(); clearOnDelete(result) {
+			results[receiver.GetFileName()] = result
+			infos[receiver.GetFileName()] = m.sourceInfo(emptySource)
+		}
+	}
+	if len(errs) > 0 && m.watch.Transactional {
+		for fileName := range results {
+			if _, failed := errs[fileName]; !failed {
+				errs[fileName] = ErrReloadRolledBack
+			}
+		}
+	}
+	for fileName := range errs {
+		delete(results, fileName)
+	}
+
+	old, gen := m.Current(), (*Generation)(nil)
+	if len(results) > 0 {
+		old, gen = m.publish(results, infos, true)
 	}
-	err = json.Unmarshal(data, receiver)
+	events := make([]ReloadEvent, 0, len(receivers))
+	var joined []error
+	for _, receiver := range receivers {
+		fileName := receiver.GetFileName()
+		oldResult, _ := old.Get(fileName)
+		if err, failed := errs[fileName]; failed {
+			slog.Error("config reload failed:", "fileName", fileName, "err", err)
+			m.setLoadError(fileName, err)
+			m.audit.record("reload", fileName, old, nil, elapsed[fileName], err)
+			events = append(events, ReloadEvent{FileName: fileName, Old: oldResult, Deleted: deleted[fileName], Err: err})
+			joined = append(joined, fmt.Errorf("%s: %w", fileName, err))
+			continue
+		}
+		if deleted[fileName] {
+			result, cleared := results[fileName]
+			slog.Warn("config deleted", "fileName", fileName, "cleared", cleared)
+			m.setLoadError(fileName, nil)
+			if cleared {
+				m.audit.record("delete", fileName, old, gen, 0, nil)
+			} else {
+				m.audit.record("delete", fileName, old, nil, 0, nil)
+			}
+			events = append(events, ReloadEvent{FileName: fileName, Old: oldResult, New: result, Deleted: true})
+			continue
+		}
+		slog.Info("config reloaded", "fileName", fileName)
+		m.setLoadError(fileName, nil)
+		m.audit.record("reload", fileName, old, gen, elapsed[fileName], nil)
+		events = append(events, ReloadEvent{FileName: fileName, Old: oldResult, New: results[fileName]})
+	}
+	m.notify(events)
+	return errors.Join(joined...)
+}
+
+// DryRun 加载并校验表但不发布，fileNames为空时检查所有注册的表，返回所有失败的表的错误
+func (m *ConfigManager) DryRun(fileNames ...string) error {
+	tables, err := m.lookup(fileNames)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	receivers := make([]IConfig, len(tables))
+	for i, table := range tables {
+		receivers[i] = table.receiver
+	}
+	_, _, _, errs := m.loadBatch(receivers)
+	var joined []error
+	for _, receiver := range receivers {
+		if err, failed := errs[receiver.GetFileName()]; failed {
+			joined = append(joined, fmt.Errorf("%s: %w", receiver.GetFileName(), err))
+		}
 	}
-	return data, nil
+	return errors.Join(joined...)
 }
 
-func (m *ConfigManager) ReloadFile(receiver IConfig) error {
-	if IReload, ok := receiver.(IReloadConfig); ok {
-		_, err := m.loadDataFromFile(receiver.GetFileName(), IReload.GetReloadResult(true))
+// loadBatch 加载同一批的表，都加载完成后用将要发布的版本校验，返回加载成功的数据和原始数据的校验值、每个表加载的耗时和失败的错误
+// 校验失败的表同时在results和errs中
+func (m *ConfigManager) loadBatch(receivers []IConfig) (map[string]interface{}, map[string]tableInfo, map[string]time.Duration, map[string]error) {
+	results := make(map[string]interface{}, len(receivers))
+	infos := make(map[string]tableInfo, len(receivers))
+	elapsed := make(map[string]time.Duration, len(receivers))
+	errs := make(map[string]error)
+	for _, receiver := range receivers {
+		result, info, d, err := m.load(receiver)
+		elapsed[receiver.GetFileName()] = d
 		if err != nil {
-			return err
+			errs[receiver.GetFileName()] = err
+			continue
 		}
-		if mod, ok := IReload.GetReloadResult(false).(IAfterLoad); ok {
-			if err := mod.AfterLoad(); err != nil {
-				return err
-			}
+		results[receiver.GetFileName()] = result
+		infos[receiver.GetFileName()] = info
+	}
+	pending := m.Current().with(results, nil)
+	for fileName, result := range results {
+		if err := m.validate(pending, result); err != nil {
+			errs[fileName] = err
 		}
-		IReload.OnReloadFinished()
-		delete(m.fileStat, receiver.GetFileName())
-	} else {
-		return errors.New("not IReloadConfig")
 	}
-	return nil
+	return results, infos, elapsed, errs
 }
 
-func (m *ConfigManager) updateScanDetail(init bool) {
-	m.fileStat = make(map[string]interface{})
-	filepath.Walk(m.basePath, func(path string, info os.FileInfo, err error) error {
-		if info == nil {
-			return err
+// loadDataFromFile 接收者支持二进制并且bin文件是从当前的json数据(基础数据和overlay)导出的时读取bin，
+// 否则有overlay时合并后解析json，没有时解析基础数据的json；返回解析的原始数据(bin、合并后的json或基础数据的json)和读取的字节数
+func (m *ConfigManager) loadDataFromFile(fileName string, receiver interface{}) ([]byte, int, error) {
+	if m.base == nil {
+		return nil, 0, errors.New("config service not started")
+	}
+	//基础数据不存在时只使用overlay或bin
+	base, baseErr := m.base.ReadFile(fileName)
+	if baseErr != nil && !isNotExist(baseErr) {
+		return nil, 0, baseErr
+	}
+	n := len(base)
+	overlays, err := m.readOverlays(fileName)
+	for _, overlay := range overlays {
+		n += len(overlay.data)
+	}
+	if err != nil {
+		return nil, n, err
+	}
+	if binReceiver, ok := receiver.(IBinaryConfig); ok {
+		if data, err := m.base.ReadFile(BinaryFileName(fileName)); err == nil {
+			n += len(data)
+			//只有bin文件时直接使用
+			if baseErr == nil || len(overlays) > 0 {
+				err = CheckBinarySource(data, datafile.BinarySourceHash(base, overlayBytes(overlays)...))
+			}
+			if err == nil {
+				err = DecodeBinary(data, binReceiver)
+			}
+			//文件头不一致时还没有解码，可以回退到json
+			if !errors.Is(err, ErrBinaryStale) && !errors.Is(err, ErrBinarySchemaMismatch) {
+				return data, n, err
+			}
+			slog.Warn("config binary outdated, load json:", "fileName", fileName, "err", err)
 		}
-		if info.IsDir() {
-			return nil
+	}
+	if len(overlays) > 0 {
+		merged, err := loadOverlayData(fileName, base, baseErr == nil, overlays, receiver)
+		return merged, n, err
+	}
+	if baseErr != nil {
+		return nil, n, baseErr
+	}
+	return base, n, decodeJSON(base, receiver)
+}
+
+// decodeJSON 解析json数据，有继承时先展开
+func decodeJSON(data []byte, receiver interface{}) error {
+	if !datafile.HasTemplates(data) {
+		return json.Unmarshal(data, receiver)
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	return decodeResolved(raw, receiver)
+}
+
+// decodeResolved 展开继承后解析到接收者
+func decodeResolved(raw map[string]any, receiver interface{}) error {
+	resolved, _, err := datafile.ResolveTemplates(raw)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(resolved)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, receiver)
+}
+
+// overlayFiles 返回有这个文件的overlay
+func (m *ConfigManager) overlayFiles(fileName string) []Source {
+	var sources []Source
+	for _, overlay := range m.overlays {
+		if _, err := overlay.Stat(fileName); err == nil {
+			sources = append(sources, overlay)
 		}
-		if !strings.HasSuffix(info.Name(), ".json") {
-			return nil
+	}
+	return sources
+}
+
+// overlayData overlay中的文件内容
+type overlayData struct {
+	source Source
+	data   []byte
+}
+
+// readOverlays 按顺序读取有这个文件的overlay
+func (m *ConfigManager) readOverlays(fileName string) ([]overlayData, error) {
+	var overlays []overlayData
+	for _, overlay := range m.overlays {
+		data, err := overlay.ReadFile(fileName)
+		if isNotExist(err) {
+			continue
 		}
-		relPath, err := filepath.Rel(m.basePath, path)
 		if err != nil {
-			return nil
-		}
-		relPath = filepath.ToSlash(relPath)
-		timestamp := info.ModTime().Unix()
-		if init {
-			m.scanDetail[relPath] = timestamp
-		} else {
-			if oldTimestamp, exist := m.scanDetail[relPath]; exist && oldTimestamp != timestamp {
-				m.scanDetail[relPath] = timestamp
-				m.fileStat[relPath] = struct{}{}
-			}
+			return overlays, err
 		}
-		return nil
-	})
+		overlays = append(overlays, overlayData{source: overlay, data: data})
+	}
+	return overlays, nil
 }
 
-func (m *ConfigManager) StartService(path string) {
-	m.basePath = path
-	instance.updateScanDetail(true)
-	go func() {
-		ticker := time.NewTicker(time.Second * 30)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ticker.C:
-				m.updateScanDetail(false)
-			}
+func overlayBytes(overlays []overlayData) [][]byte {
+	data := make([][]byte, len(overlays))
+	for i, overlay := range overlays {
+		data[i] = overlay.data
+	}
+	return data
+}
+
+// loadOverlayData 按顺序把overlay合并到基础数据上，hasBase为false时只使用overlay，返回合并后的json
+func loadOverlayData(fileName string, base []byte, hasBase bool, overlays []overlayData, receiver interface{}) ([]byte, error) {
+	merged := map[string]any{}
+	if hasBase {
+		if err := json.Unmarshal(base, &merged); err != nil {
+			return nil, err
+		}
+	}
+	for _, overlay := range overlays {
+		var patch map[string]any
+		if err := json.Unmarshal(overlay.data, &patch); err != nil {
+			return nil, fmt.Errorf("%v/%s: %w", overlay.source, fileName, err)
 		}
-	}()
+		merged = datafile.MergeOverlay(merged, patch).(map[string]any)
+	}
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	//先合并overlay再展开继承，overlay修改模板时继承的行也会改变
+	return data, decodeResolved(merged, receiver)
+}
+
+// exists 基础数据或overlay中还有这个表的json或bin文件，还没有开始服务时由加载返回错误
+func (m *ConfigManager) exists(fileName string) bool {
+	if m.base == nil {
+		return true
+	}
+	if _, err := m.base.Stat(fileName); err == nil {
+		return true
+	}
+	if _, err := m.base.Stat(BinaryFileName(fileName)); err == nil {
+		return true
+	}
+	return len(m.overlayFiles(fileName)) > 0
 }
-func (m *ConfigManager) IsDirty(fileName string) bool {
-	_, exist := m.fileStat[fileName]
-	return exist
+
+// emptySource 删除后发布的空数据对应的原始数据
+var emptySource = []byte("{}")
+
+func clearOnDelete(result interface{}) bool {
+	c, ok := result.(IClearOnDelete)
+	return ok && c.ClearOnDelete()
+}
+
+func isNotExist(err error) bool {
+	return errors.Is(err, fs.ErrNotExist)
+}
+
+// StartService 开始监听数据目录，overlays为按顺序合并到基础数据上的overlay目录
+func (m *ConfigManager) StartService(path string, overlays ...string) {
+	sources := make([]Source, len(overlays))
+	for i, overlay := range overlays {
+		sources[i] = NewDirSource(overlay)
+	}
+	m.StartSource(NewDirSource(path), sources...)
+}
+
+// StartSource 开始监听数据来源，overlays为按顺序合并到基础数据上的overlay数据
+func (m *ConfigManager) StartSource(base Source, overlays ...Source) {
+	m.base = base
+	m.overlays = overlays
+	for _, source := range append([]Source{base}, overlays...) {
+		m.sources = append(m.sources, &sourceState{source: source, files: make(map[string]SourceFile)})
+	}
+	m.updateScanDetail(true)
+	m.startWatch()
 }
@@ -0,0 +1,552 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+// testConfig 测试用的表，文件名为自身，数据为testData
+type testConfig string
+
+func (c testConfig) GetFileName() string {
+	return string(c)
+}
+
+func (c testConfig) NewResult() interface{} {
+	return new(testData)
+}
+
+var errNegative = errors.New("negative")
+
+// testData N为负数时AfterLoad失败
+type testData struct {
+	N int
+}
+
+func (d *testData) AfterLoad() error {
+	if d.N < 0 {
+		return errNegative
+	}
+	return nil
+}
+
+func testFile(n int) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(fmt.Sprintf(`{"N":%d}`, n))}
+}
+
+// newTestManager 使用fsys作为基础数据，不启动监听，注册fileNames中的表
+func newTestManager(fsys fstest.MapFS, fileNames ...string) *ConfigManager {
+	m := newConfigManager()
+	m.base = NewFSSource(fsys)
+	m.sources = []*sourceState{{source: m.base, files: make(map[string]SourceFile)}}
+	for _, fileName := range fileNames {
+		m.Register(testConfig(fileName))
+	}
+	return m
+}
+
+// testValue 版本中表的N，没有这个表时返回-1
+func testValue(gen *Generation, fileName string) int {
+	v, ok := gen.Get(fileName)
+	if !ok {
+		return -1
+	}
+	return v.(*testData).N
+}
+
+func TestReloadConcurrentGet(t *testing.T) {
+	fsys := fstest.MapFS{"a.json": testFile(0), "b.json": testFile(0)}
+	m := newTestManager(fsys, "a.json", "b.json")
+	if err := m.LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+	const reloads = 200
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var last uint64
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				gen := m.Current()
+				if gen.Version < last {
+					t.Errorf("version %d after %d", gen.Version, last)
+					return
+				}
+				last = gen.Version
+				//两个表总是在同一个版本中重新加载
+				a := m.Get(gen, testConfig("a.json")).(*testData)
+				b := m.Get(gen, testConfig("b.json")).(*testData)
+				if a.N != b.N {
+					t.Errorf("version %d: a %d, b %d", gen.Version, a.N, b.N)
+					return
+				}
+				m.Generations()
+			}
+		}()
+	}
+	for i := 1; i <= reloads; i++ {
+		fsys["a.json"], fsys["b.json"] = testFile(i), testFile(i)
+		if err := m.Reload(); err != nil {
+			t.Error(err)
+			break
+		}
+	}
+	close(stop)
+	wg.Wait()
+	if got := testValue(m.Current(), "a.json"); got != reloads {
+		t.Errorf("a %d, want %d", got, reloads)
+	}
+}
+
+func TestReload(t *testing.T) {
+	tests := []struct {
+		name          string
+		transactional bool
+		a, b          *fstest.MapFile
+		wantA, wantB  int
+		wantErrs      map[string]error //key：文件名
+	}{
+		{name: "ok", a: testFile(2), b: testFile(2), wantA: 2, wantB: 2},
+		{
+			name: "after load failed", a: testFile(2), b: testFile(-1), wantA: 2, wantB: 1,
+			wantErrs: map[string]error{"b.json": errNegative},
+		},
+		{
+			name: "invalid json", a: &fstest.MapFile{Data: []byte(`{"N":`)}, b: testFile(2), wantA: 1, wantB: 2,
+			wantErrs: map[string]error{"a.json": nil},
+		},
+		{name: "transactional ok", transactional: true, a: testFile(2), b: testFile(2), wantA: 2, wantB: 2},
+		{
+			name: "transactional rolled back", transactional: true, a: testFile(2), b: testFile(-1), wantA: 1, wantB: 1,
+			wantErrs: map[string]error{"a.json": ErrReloadRolledBack, "b.json": errNegative},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{"a.json": testFile(1), "b.json": testFile(1)}
+			m := newTestManager(fsys, "a.json", "b.json")
+			m.SetWatchConfig(WatchConfig{Transactional: tt.transactional})
+			if err := m.LoadAll(); err != nil {
+				t.Fatal(err)
+			}
+			events := make(map[string]ReloadEvent)
+			m.Subscribe("", func(event ReloadEvent) {
+				events[event.FileName] = event
+			})
+			before := m.Current()
+			fsys["a.json"], fsys["b.json"] = tt.a, tt.b
+			err := m.Reload("a.json", "b.json")
+			if (err != nil) != (len(tt.wantErrs) > 0) {
+				t.Fatalf("err %v, want errors for %v", err, tt.wantErrs)
+			}
+			gen := m.Current()
+			if got := testValue(gen, "a.json"); got != tt.wantA {
+				t.Errorf("a %d, want %d", got, tt.wantA)
+			}
+			if got := testValue(gen, "b.json"); got != tt.wantB {
+				t.Errorf("b %d, want %d", got, tt.wantB)
+			}
+			//全部失败时不发布新的版本
+			if published := gen != before; published != (tt.wantA == 2 || tt.wantB == 2) {
+				t.Errorf("published %v", published)
+			}
+			for _, fileName := range []string{"a.json", "b.json"} {
+				want, failed := tt.wantErrs[fileName]
+				event := events[fileName]
+				if (event.Err != nil) != failed || want != nil && !errors.Is(event.Err, want) {
+					t.Errorf("%s event err %v, want %v", fileName, event.Err, want)
+				}
+				if loadErr := m.loadError(fileName).err; (loadErr != nil) != failed {
+					t.Errorf("%s load error %v", fileName, loadErr)
+				}
+				if failed && event.New != nil {
+					t.Errorf("%s failed event has new data", fileName)
+				}
+			}
+		})
+	}
+}
+
+// TestReloadPartialFailure 非事务模式下成功的表发布，失败的表保留旧数据
+func TestReloadPartialFailure(t *testing.T) {
+	fsys := fstest.MapFS{"a.json": testFile(1), "b.json": testFile(1), "c.json": testFile(1)}
+	m := newTestManager(fsys, "a.json", "b.json", "c.json")
+	if err := m.LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+	before := m.Current()
+	oldB, _ := before.Get("b.json")
+	oldC, _ := before.Get("c.json")
+	events := make(map[string]ReloadEvent)
+	m.Subscribe("", func(event ReloadEvent) {
+		events[event.FileName] = event
+	})
+
+	fsys["a.json"] = testFile(2)
+	fsys["b.json"] = testFile(-1)
+	fsys["c.json"] = &fstest.MapFile{Data: []byte(`{"N":`)}
+	err := m.Reload("a.json", "b.json", "c.json")
+	if !errors.Is(err, errNegative) {
+		t.Fatalf("err %v, want %v", err, errNegative)
+	}
+	for _, fileName := range []string{"b.json", "c.json"} {
+		if !strings.Contains(err.Error(), fileName) {
+			t.Errorf("err %v, want %s", err, fileName)
+		}
+	}
+	if strings.Contains(err.Error(), "a.json") {
+		t.Errorf("err %v contains the published table", err)
+	}
+
+	gen := m.Current()
+	if gen.Version != before.Version+1 {
+		t.Errorf("version %d, want %d", gen.Version, before.Version+1)
+	}
+	if got := testValue(gen, "a.json"); got != 2 {
+		t.Errorf("a %d, want 2", got)
+	}
+	if gen.Hash("a.json") == before.Hash("a.json") {
+		t.Error("a hash not changed")
+	}
+	//失败的表仍然使用同一份旧数据和发布信息
+	for fileName, old := range map[string]interface{}{"b.json": oldB, "c.json": oldC} {
+		if v, _ := gen.Get(fileName); v != old {
+			t.Errorf("%s data %v, want old data", fileName, v)
+		}
+		if gen.Hash(fileName) != before.Hash(fileName) {
+			t.Errorf("%s hash changed", fileName)
+		}
+		oldVersion, _ := before.Published(fileName)
+		if version, _ := gen.Published(fileName); version != oldVersion {
+			t.Errorf("%s published in %d, want %d", fileName, version, oldVersion)
+		}
+		event := events[fileName]
+		if event.Err == nil || event.New != nil || event.Old != old {
+			t.Errorf("%s event %+v", fileName, event)
+		}
+		if m.loadError(fileName).err == nil {
+			t.Errorf("%s load error not recorded", fileName)
+		}
+	}
+	if event := events["a.json"]; event.Err != nil || event.New.(*testData).N != 2 || event.Old.(*testData).N != 1 {
+		t.Errorf("a event %+v", event)
+	}
+	if m.loadError("a.json").err != nil {
+		t.Errorf("a load error %v", m.loadError("a.json").err)
+	}
+
+	//修复后重新加载清除错误
+	fsys["b.json"] = testFile(3)
+	fsys["c.json"] = testFile(3)
+	if err := m.Reload("b.json", "c.json"); err != nil {
+		t.Fatal(err)
+	}
+	for _, fileName := range []string{"b.json", "c.json"} {
+		if got := testValue(m.Current(), fileName); got != 3 {
+			t.Errorf("%s %d, want 3", fileName, got)
+		}
+		if err := m.loadError(fileName).err; err != nil {
+			t.Errorf("%s load error %v after fix", fileName, err)
+		}
+	}
+}
+
+func TestRollback(t *testing.T) {
+	fsys := fstest.MapFS{"a.json": testFile(1)}
+	m := newTestManager(fsys, "a.json")
+	m.SetHistoryConfig(HistoryConfig{Keep: 3})
+	if err := m.LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+	first := m.Current()
+	for i := 2; i <= 3; i++ {
+		fsys["a.json"] = testFile(i)
+		if err := m.Reload(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var events []ReloadEvent
+	m.Subscribe("a.json", func(event ReloadEvent) {
+		events = append(events, event)
+	})
+
+	if err := m.Rollback(first.Version); err != nil {
+		t.Fatal(err)
+	}
+	gen := m.Current()
+	firstData, _ := first.Get("a.json")
+	if data, _ := gen.Get("a.json"); data != firstData {
+		t.Errorf("rollback data %v, want %v", data, firstData)
+	}
+	if gen.Version != first.Version+3 {
+		t.Errorf("rollback version %d, want new version %d", gen.Version, first.Version+3)
+	}
+	if version, _ := gen.Published("a.json"); version != gen.Version {
+		t.Errorf("published version %d, want %d", version, gen.Version)
+	}
+	if len(events) != 1 || events[0].Old.(*testData).N != 3 || events[0].New != firstData {
+		t.Errorf("events %+v", events)
+	}
+
+	//和当前数据相同时不通知
+	events = nil
+	if err := m.Rollback(gen.Version); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Errorf("events %+v", events)
+	}
+
+	//只保留最近3个版本
+	if err := m.Rollback(first.Version); !errors.Is(err, ErrGenerationNotFound) {
+		t.Errorf("got %v, want %v", err, ErrGenerationNotFound)
+	}
+	if n := len(m.Generations()); n != 3 {
+		t.Errorf("%d generations, want 3", n)
+	}
+	if got := testValue(m.Current(), "a.json"); got != 1 {
+		t.Errorf("a %d after failed rollback, want 1", got)
+	}
+}
+
+func TestLoadLastGood(t *testing.T) {
+	lastGood := t.TempDir()
+	good := newTestManager(fstest.MapFS{"p/a.json": testFile(5)}, "p/a.json")
+	good.SetHistoryConfig(HistoryConfig{LastGoodPath: lastGood})
+	if err := good.LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(lastGood, "p/a.json")); err != nil {
+		t.Fatalf("last good not saved: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		file          *fstest.MapFile
+		lastGoodPath  string
+		strict        bool
+		wantErr       error
+		want          int //-1表示没有发布
+		wantGenerated bool
+	}{
+		{name: "fallback", file: testFile(-1), lastGoodPath: lastGood, wantErr: errNegative, want: 5, wantGenerated: true},
+		{name: "fallback invalid json", file: &fstest.MapFile{Data: []byte(`{`)}, lastGoodPath: lastGood, want: 5, wantGenerated: true},
+		//没有最后一次成功加载的数据时发布空数据，AfterLoad失败的数据不发布
+		{name: "no last good", file: testFile(-2), lastGoodPath: t.TempDir(), wantErr: errNegative, want: 0},
+		{name: "no last good invalid json", file: &fstest.MapFile{Data: []byte(`{`)}, lastGoodPath: t.TempDir(), want: 0},
+		{name: "strict", file: testFile(-1), lastGoodPath: lastGood, strict: true, wantErr: errNegative, want: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(fstest.MapFS{"p/a.json": tt.file}, "p/a.json")
+			m.SetHistoryConfig(HistoryConfig{LastGoodPath: tt.lastGoodPath})
+			m.SetStrict(tt.strict)
+			err := m.LoadAll()
+			if err == nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+			if got := testValue(m.Current(), "p/a.json"); got != tt.want {
+				t.Errorf("a %d, want %d", got, tt.want)
+			}
+			//回退的数据可以回滚，空数据不能
+			if generated := len(m.Generations()) > 0; generated != tt.wantGenerated {
+				t.Errorf("generations %v, want %v", generated, tt.wantGenerated)
+			}
+			if m.loadError("p/a.json").err == nil {
+				t.Error("load error not recorded")
+			}
+		})
+	}
+}
+
+// TestSaveLastGood 保存加载的原始数据，回滚时保存回滚到的数据
+func TestSaveLastGood(t *testing.T) {
+	lastGood := t.TempDir()
+	base := `{"$templates":{"t":{"N":7}},"$base":"t"}`
+	fsys := fstest.MapFS{
+		"a.json": &fstest.MapFile{Data: []byte(base)},
+		"b.json": testFile(1),
+	}
+	overlay := fstest.MapFS{"b.json": testFile(2)}
+	m := newTestManager(fsys, "a.json", "b.json")
+	m.overlays = []Source{NewFSSource(overlay)}
+	m.SetHistoryConfig(HistoryConfig{LastGoodPath: lastGood})
+	if err := m.LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+	if got := testValue(m.Current(), "a.json"); got != 7 {
+		t.Fatalf("a %d, want 7", got)
+	}
+	readLastGood := func(fileName string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(lastGood, fileName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	//保存原始数据，不是解析后的数据
+	if got := readLastGood("a.json"); got != base {
+		t.Errorf("a last good %s, want %s", got, base)
+	}
+	if got := readLastGood("b.json"); got != `{"N":2}` {
+		t.Errorf("b last good %s, want merged overlay", got)
+	}
+	first := m.Current()
+
+	fsys["a.json"] = testFile(3)
+	if err := m.Reload("a.json"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readLastGood("a.json"); got != `{"N":3}` {
+		t.Errorf("a last good %s after reload", got)
+	}
+	if err := m.Rollback(first.Version); err != nil {
+		t.Fatal(err)
+	}
+	if got := readLastGood("a.json"); got != base {
+		t.Errorf("a last good %s after rollback, want %s", got, base)
+	}
+	if m.Current().Hash("a.json") != first.Hash("a.json") {
+		t.Error("rollback hash changed")
+	}
+	if entries, _ := os.ReadDir(lastGood); len(entries) != 2 {
+		t.Errorf("last good files %v, want no temp files", entries)
+	}
+
+	//从保存的原始数据加载，有继承时先展开
+	fsys["a.json"] = testFile(-1)
+	restart := newTestManager(fsys, "a.json")
+	restart.SetHistoryConfig(HistoryConfig{LastGoodPath: lastGood})
+	if err := restart.LoadAll(); !errors.Is(err, errNegative) {
+		t.Fatalf("err %v, want %v", err, errNegative)
+	}
+	if got := testValue(restart.Current(), "a.json"); got != 7 {
+		t.Errorf("a %d, want last good 7", got)
+	}
+	if restart.Current().Hash("a.json") != first.Hash("a.json") {
+		t.Error("last good hash differs from the saved source")
+	}
+}
+
+var errInvalid = errors.New("invalid")
+
+// testValidateConfig 校验失败的表
+type testValidateConfig string
+
+func (c testValidateConfig) GetFileName() string {
+	return string(c)
+}
+
+func (c testValidateConfig) NewResult() interface{} {
+	return new(testValidateData)
+}
+
+type testValidateData struct {
+	N int
+}
+
+func (d *testValidateData) Validate(gen *Generation) error {
+	if d.N < 0 {
+		return errInvalid
+	}
+	return nil
+}
+
+func TestLoadAllErrors(t *testing.T) {
+	fsys := fstest.MapFS{
+		"ok.json":       testFile(1),
+		"negative.json": testFile(-1),
+		"invalid.json":  testFile(-1),
+		"broken.json":   &fstest.MapFile{Data: []byte(`{"N":`)},
+	}
+	m := newTestManager(fsys, "ok.json", "negative.json", "broken.json")
+	m.Register(testValidateConfig("invalid.json"))
+	err := m.LoadAll()
+	if !errors.Is(err, errNegative) || !errors.Is(err, errInvalid) {
+		t.Fatalf("err %v, want %v and %v", err, errNegative, errInvalid)
+	}
+	//每个失败的表一个错误，按文件名排序
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok || len(joined.Unwrap()) != 3 {
+		t.Fatalf("err %v, want 3 joined errors", err)
+	}
+	for i, fileName := range []string{"broken.json", "invalid.json", "negative.json"} {
+		if msg := joined.Unwrap()[i].Error(); !strings.HasPrefix(msg, fileName+": ") {
+			t.Errorf("error %d %q, want %s", i, msg, fileName)
+		}
+		if m.loadError(fileName).err == nil {
+			t.Errorf("%s load error not recorded", fileName)
+		}
+	}
+
+	//失败的表发布空数据，不发布AfterLoad或校验失败的数据
+	gen := m.Current()
+	if got := testValue(gen, "ok.json"); got != 1 {
+		t.Errorf("ok %d, want 1", got)
+	}
+	for _, fileName := range []string{"negative.json", "broken.json"} {
+		if got := testValue(gen, fileName); got != 0 {
+			t.Errorf("%s %d, want empty data", fileName, got)
+		}
+	}
+	if v, _ := gen.Get("invalid.json"); v.(*testValidateData).N != 0 {
+		t.Errorf("invalid %+v, want empty data", v)
+	}
+	//空数据不能回滚
+	if gens := m.Generations(); len(gens) != 1 {
+		t.Errorf("generations %d, want 1", len(gens))
+	}
+	//已经加载过的表返回第一次加载的错误
+	if err := m.LoadFile(testConfig("negative.json")); !errors.Is(err, errNegative) {
+		t.Errorf("load again %v, want %v", err, errNegative)
+	}
+}
+
+func TestGetStrict(t *testing.T) {
+	fsys := fstest.MapFS{"ok.json": testFile(1), "bad.json": testFile(-1)}
+	get := func(m *ConfigManager, fileName string) (v interface{}, recovered interface{}) {
+		defer func() {
+			recovered = recover()
+		}()
+		return m.Get(m.Current(), testConfig(fileName)), nil
+	}
+
+	m := newTestManager(fsys)
+	m.SetStrict(true)
+	if v, r := get(m, "ok.json"); r != nil || v.(*testData).N != 1 {
+		t.Errorf("ok %v, panic %v", v, r)
+	}
+	_, r := get(m, "bad.json")
+	if err, ok := r.(error); !ok || !errors.Is(err, errNegative) {
+		t.Fatalf("panic %v, want %v", r, errNegative)
+	}
+	if _, ok := m.Current().Get("bad.json"); ok {
+		t.Error("strict failure published")
+	}
+	//没有发布时再次获取重新加载
+	fsys["bad.json"] = testFile(2)
+	if v, r := get(m, "bad.json"); r != nil || v.(*testData).N != 2 {
+		t.Errorf("fixed %v, panic %v", v, r)
+	}
+
+	//非严格模式下返回空数据
+	fsys["bad.json"] = testFile(-1)
+	m = newTestManager(fsys)
+	if v, r := get(m, "bad.json"); r != nil || v.(*testData).N != 0 {
+		t.Errorf("not strict %v, panic %v", v, r)
+	}
+}
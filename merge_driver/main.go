@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mogebingxue/game_config_manager"
+	"github.com/mogebingxue/game_config_manager/utils"
+)
+
+// git合并驱动，按元数据三方合并数据文件，结果写成标准格式，只有双方修改了同一个字段时冲突
+// 冲突的位置写入 {"$conflict": {...}}，返回非0，由git标记为冲突
+// 配置：.gitattributes 中加入 <数据目录>/**/*.json merge=game_config，然后
+// git config merge.game_config.driver "go run ./merge_driver %O %A %B %P"
+func main() {
+	confPath := flag.String("conf", "./conf.yaml", "配置文件")
+	flag.Parse()
+	if flag.NArg() != 4 {
+		fmt.Fprintln(os.Stderr, "usage: merge_driver <base> <ours> <theirs> <path>")
+		os.Exit(2)
+	}
+	basePath, oursPath, theirsPath, path := flag.Arg(0), flag.Arg(1), flag.Arg(2), flag.Arg(3)
+	cfg, err := config.LoadConfig(*confPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	schema, err := utils.LoadSchema(cfg.MetadataPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "load metadata:", err)
+		os.Exit(2)
+	}
+	base, err := readData(basePath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	ours, err := readData(oursPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	theirs, err := readData(theirsPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	//和数据目录的结构一致：<包名>/<表名>.json，元数据中没有的表按没有类型信息合并
+	pkg := filepath.Base(filepath.Dir(path))
+	tableName := strings.TrimSuffix(filepath.Base(path), ".json")
+	typMap := schema.TypeMap(pkg)
+	table := &utils.Struct{Name: tableName}
+	if meta, ok := schema.LookupType(pkg, tableName); ok && meta.Typ == utils.TABLE {
+		table = meta.Meta.(*utils.Struct)
+	}
+	merged, conflicts := utils.MergeData(typMap, table, base, ours, theirs)
+	if err := os.WriteFile(oursPath, utils.FormatData(typMap, table, merged), 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	for _, c := range conflicts {
+		fmt.Fprintf(os.Stderr, "%s#%s\n", path, c)
+	}
+	if len(conflicts) > 0 {
+		os.Exit(1)
+	}
+}
+
+// readData 双方都新增文件时base为空
+func readData(path string) (map[string]any, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data := make(map[string]any)
+	if len(strings.TrimSpace(string(content))) == 0 {
+		return data, nil
+	}
+	if err := json.Unmarshal(content, &data); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// loadBuckets 加载耗时直方图的上界，单位秒
+var loadBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}
+
+// metrics 加载次数、耗时、字节数和失败次数，按表统计
+type metrics struct {
+	mu        sync.Mutex
+	tables    map[string]*tableMetrics //key：文件名
+	rollbacks uint64
+}
+
+// tableMetrics 一个表的指标，包括DryRun的加载
+type tableMetrics struct {
+	Loads       uint64   `json:"loads"`        //加载次数，包括失败的
+	Failures    uint64   `json:"failures"`     //加载、校验或回滚导致没有发布的次数
+	Bytes       uint64   `json:"bytes"`        //累计读取的字节数
+	LastBytes   int      `json:"last_bytes"`   //最后一次读取的字节数
+	DurationSum float64  `json:"duration_sum"` //累计耗时，单位秒
+	LastLoad    float64  `json:"last_load"`    //最后一次的耗时，单位秒
+	Buckets     []uint64 `json:"buckets"`      //耗时不超过loadBuckets中对应上界的次数
+}
+
+func (s *metrics) table(fileName string) *tableMetrics {
+	if s.tables == nil {
+		s.tables = make(map[string]*tableMetrics)
+	}
+	t, ok := s.tables[fileName]
+	if !ok {
+		t = &tableMetrics{Buckets: make([]uint64, len(loadBuckets))}
+		s.tables[fileName] = t
+	}
+	return t
+}
+
+func (s *metrics) observeLoad(fileName string, elapsed time.Duration, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.table(fileName)
+	seconds := elapsed.Seconds()
+	t.Loads++
+	t.Bytes += uint64(n)
+	t.LastBytes = n
+	t.DurationSum += seconds
+	t.LastLoad = seconds
+	for i, le := range loadBuckets {
+		if seconds <= le {
+			t.Buckets[i]++
+		}
+	}
+}
+
+func (s *metrics) observeFailure(fileName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.table(fileName).Failures++
+}
+
+func (s *metrics) observeRollback() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rollbacks++
+}
+
+// snapshot 复制一份，输出时不持有锁
+func (s *metrics) snapshot() (map[string]tableMetrics, uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tables := make(map[string]tableMetrics, len(s.tables))
+	for fileName, t := range s.tables {
+		c := *t
+		c.Buckets = append([]uint64(nil), t.Buckets...)
+		tables[fileName] = c
+	}
+	return tables, s.rollbacks
+}
+
+// expvarMetrics GetConfigManager时发布到expvar的game_config，通过 /debug/vars 查看
+func (m *ConfigManager) expvarMetrics() interface{} {
+	tables, rollbacks := m.metrics.snapshot()
+	return map[string]interface{}{
+		"generation":    m.Current().Version,
+		"rollbacks":     rollbacks,
+		"load_buckets":  loadBuckets,
+		"tables":        tables,
+		"history_count": len(m.Generations()),
+	}
+}
+
+// MetricsHandler Prometheus文本格式的指标，例如 http.Handle("/metrics", m.MetricsHandler())
+func (m *ConfigManager) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		m.writeMetrics(w)
+	})
+}
+
+func (m *ConfigManager) writeMetrics(w io.Writer) {
+	tables, rollbacks := m.metrics.snapshot()
+	fileNames := make([]string, 0, len(tables))
+	for fileName := range tables {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+
+	fmt.Fprintf(w, "# HELP game_config_generation Current published generation.\n# TYPE game_config_generation gauge\n")
+	fmt.Fprintf(w, "game_config_generation %d\n", m.Current().Version)
+	fmt.Fprintf(w, "# HELP game_config_rollbacks_total Rollbacks to a previous generation.\n# TYPE game_config_rollbacks_total counter\n")
+	fmt.Fprintf(w, "game_config_rollbacks_total %d\n", rollbacks)
+
+	counters := []struct {
+		name, help string
+		value      func(t tableMetrics) uint64
+	}{
+		{"game_config_loads_total", "Table loads including dry runs.", func(t tableMetrics) uint64 { return t.Loads }},
+		{"game_config_load_failures_total", "Table loads or reloads that were not published.", func(t tableMetrics) uint64 { return t.Failures }},
+		{"game_config_load_bytes_total", "Bytes read while loading tables.", func(t tableMetrics) uint64 { return t.Bytes }},
+	}
+	for _, c := range counters {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+		for _, fileName := range fileNames {
+			fmt.Fprintf(w, "%s{file=%s} %d\n", c.name, strconv.Quote(fileName), c.value(tables[fileName]))
+		}
+	}
+
+	fmt.Fprintf(w, "# HELP game_config_load_duration_seconds Time to read and decode a table.\n# TYPE game_config_load_duration_seconds histogram\n")
+	for _, fileName := range fileNames {
+		t := tables[fileName]
+		label := strconv.Quote(fileName)
+		for i, le := range loadBuckets {
+			fmt.Fprintf(w, "game_config_load_duration_seconds_bucket{file=%s,le=\"%s\"} %d\n", label, formatFloat(le), t.Buckets[i])
+		}
+		fmt.Fprintf(w, "game_config_load_duration_seconds_bucket{file=%s,le=\"+Inf\"} %d\n", label, t.Loads)
+		fmt.Fprintf(w, "game_config_load_duration_seconds_sum{file=%s} %s\n", label, formatFloat(t.DurationSum))
+		fmt.Fprintf(w, "game_config_load_duration_seconds_count{file=%s} %d\n", label, t.Loads)
+	}
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	fsys := fstest.MapFS{"a.json": testFile(1), "b.json": testFile(-1)}
+	m := newTestManager(fsys, "a.json", "b.json")
+	m.LoadAll()
+	//并行加载，只有a的版本在历史版本中
+	first := m.Generations()[0].Version
+	fsys["a.json"] = testFile(22)
+	if err := m.Reload("a.json"); err != nil {
+		t.Fatal(err)
+	}
+	m.DryRun("b.json")
+	if err := m.Rollback(first); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	m.MetricsHandler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("status %d content type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	values := make(map[string]string) //key：指标名和标签
+	types := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n") {
+		if name, ok := strings.CutPrefix(line, "# TYPE "); ok {
+			name, typ, _ := strings.Cut(name, " ")
+			types[name] = typ
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		if i < 0 {
+			t.Fatalf("invalid line %q", line)
+		}
+		values[line[:i]] = line[i+1:]
+	}
+
+	gen := m.Current().Version
+	want := map[string]string{
+		"game_config_generation":                                            strconv.FormatUint(gen, 10),
+		"game_config_rollbacks_total":                                       "1",
+		`game_config_loads_total{file="a.json"}`:                            "2",
+		`game_config_loads_total{file="b.json"}`:                            "2",
+		`game_config_load_failures_total{file="a.json"}`:                    "0",
+		`game_config_load_failures_total{file="b.json"}`:                    "1",
+		`game_config_load_bytes_total{file="a.json"}`:                       strconv.Itoa(len(testFile(1).Data) + len(testFile(22).Data)),
+		`game_config_load_bytes_total{file="b.json"}`:                       strconv.Itoa(2 * len(testFile(-1).Data)),
+		`game_config_load_duration_seconds_bucket{file="a.json",le="+Inf"}`: "2",
+		`game_config_load_duration_seconds_count{file="a.json"}`:            "2",
+	}
+	for key, value := range want {
+		if got, ok := values[key]; !ok || got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+	wantTypes := map[string]string{
+		"game_config_generation":            "gauge",
+		"game_config_rollbacks_total":       "counter",
+		"game_config_loads_total":           "counter",
+		"game_config_load_failures_total":   "counter",
+		"game_config_load_bytes_total":      "counter",
+		"game_config_load_duration_seconds": "histogram",
+	}
+	for name, typ := range wantTypes {
+		if types[name] != typ {
+			t.Errorf("%s type %q, want %q", name, types[name], typ)
+		}
+	}
+	//直方图的桶累计，不超过总次数
+	last := uint64(0)
+	for _, le := range loadBuckets {
+		key := fmt.Sprintf(`game_config_load_duration_seconds_bucket{file="a.json",le="%s"}`, formatFloat(le))
+		n, err := strconv.ParseUint(values[key], 10, 64)
+		if err != nil || n < last || n > 2 {
+			t.Errorf("%s = %q", key, values[key])
+		}
+		last = n
+	}
+	if _, err := strconv.ParseFloat(values[`game_config_load_duration_seconds_sum{file="b.json"}`], 64); err != nil {
+		t.Errorf("duration sum: %v", err)
+	}
+}
@@ -0,0 +1,261 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// ErrWatchNotSupported 数据来源不支持变化通知，由ConfigManager轮询
+var ErrWatchNotSupported = errors.New("watch not supported")
+
+// SourceFile 数据来源中的文件
+type SourceFile struct {
+	Name    string    `json:"name"` //相对路径，用/分隔，例如 testpkg/TestTable.json
+	ModTime time.Time `json:"mod_time"`
+	Size    int64     `json:"size"`
+	Hash    string    `json:"hash,omitempty"` //内容的校验值，数据来源不提供时为空
+}
+
+// Source 数据来源，文件名都是用/分隔的相对路径，文件不存在时返回fs.ErrNotExist
+type Source interface {
+	ReadFile(name string) ([]byte, error)
+	Stat(name string) (SourceFile, error)
+	// List 所有文件，不包括目录
+	List() ([]SourceFile, error)
+	// Version 内容变化时改变的版本标识，轮询时版本不变就不再列出文件；返回空表示不支持，每次轮询都列出文件
+	Version() (string, error)
+	// Watch 文件变化时调用changed，names为空表示需要重新列出所有文件；不支持时返回ErrWatchNotSupported
+	Watch(changed func(names []string)) (stop func(), err error)
+}
+
+// SourceConfig 数据来源，类型为空时使用data_path目录
+type SourceConfig struct {
+	Type string `yaml:"type"` //dir、embed、zip、http
+	Path string `yaml:"path"` //dir、zip的路径，embed中的子目录
+	URL  string `yaml:"url"`  //http的地址，提供 <url>/index.json 和 <url>/<文件名>，可以用SourceHandler提供
+}
+
+// NewSource 按conf.yaml的source创建数据来源，embedded为编译进程序的数据(go:embed)，type为embed时使用
+func (c *Config) NewSource(embedded fs.FS) (Source, error) {
+	switch c.Source.Type {
+	case "", "dir":
+		dir := c.Source.Path
+		if dir == "" {
+			dir = c.DataPath
+		}
+		return NewDirSource(dir), nil
+	case "embed":
+		if embedded == nil {
+			return nil, errors.New("source embed: no embedded fs")
+		}
+		if c.Source.Path == "" || c.Source.Path == "." {
+			return NewFSSource(embedded), nil
+		}
+		sub, err := fs.Sub(embedded, strings.Trim(c.Source.Path, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("source embed: %w", err)
+		}
+		return NewFSSource(sub), nil
+	case "zip":
+		return NewZipSource(c.Source.Path), nil
+	case "http":
+		return NewHTTPSource(c.Source.URL), nil
+	}
+	return nil, fmt.Errorf("unknown source type %q", c.Source.Type)
+}
+
+// isDataFile 需要监听的文件
+func isDataFile(name string) bool {
+	return strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".bin")
+}
+
+func sortFiles(files []SourceFile) []SourceFile {
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+	return files
+}
+
+// DirSource 本地目录，使用文件系统事件监听
+type DirSource struct {
+	dir string
+}
+
+func NewDirSource(dir string) *DirSource {
+	return &DirSource{dir: dir}
+}
+
+func (s *DirSource) String() string {
+	return s.dir
+}
+
+// path 文件名不能跳出目录
+func (s *DirSource) path(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(name)), nil
+}
+
+func (s *DirSource) ReadFile(name string) ([]byte, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+func (s *DirSource) Stat(name string) (SourceFile, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return SourceFile{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return SourceFile{}, err
+	}
+	if info.IsDir() {
+		return SourceFile{}, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return SourceFile{Name: name, ModTime: info.ModTime(), Size: info.Size()}, nil
+}
+
+func (s *DirSource) List() ([]SourceFile, error) {
+	var files []SourceFile
+	err := filepath.Walk(s.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, SourceFile{Name: filepath.ToSlash(rel), ModTime: info.ModTime(), Size: info.Size()})
+		return nil
+	})
+	return sortFiles(files), err
+}
+
+func (s *DirSource) Version() (string, error) {
+	return "", nil
+}
+
+// Watch fsnotify不递归，目录和所有子目录都要加入监听，新建的目录也加入监听
+func (s *DirSource) Watch(changed func(names []string)) (func(), error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := addWatchDir(watcher, s.dir); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Has(fsnotify.Create) {
+					if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+						if err := addWatchDir(watcher, event.Name); err != nil {
+							slog.Warn("config watch dir failed:", "dir", event.Name, "err", err)
+						}
+					}
+				}
+				if rel, err := filepath.Rel(s.dir, event.Name); err == nil {
+					changed([]string{filepath.ToSlash(rel)})
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				slog.Warn("config watch error:", "dir", s.dir, "err", err)
+				//事件丢失时重新列出所有文件
+				if errors.Is(err, fsnotify.ErrEventOverflow) {
+					changed(nil)
+				}
+			}
+		}
+	}()
+	return func() { watcher.Close() }, nil
+}
+
+func addWatchDir(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return watcher.Add(path)
+		}
+		return nil
+	})
+}
+
+// FSSource fs.FS中的数据，一般是go:embed编译进程序的数据，内容不会变化
+type FSSource struct {
+	fsys fs.FS
+}
+
+func NewFSSource(fsys fs.FS) *FSSource {
+	return &FSSource{fsys: fsys}
+}
+
+func (s *FSSource) String() string {
+	return "embed"
+}
+
+func (s *FSSource) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(s.fsys, name)
+}
+
+func (s *FSSource) Stat(name string) (SourceFile, error) {
+	info, err := fs.Stat(s.fsys, name)
+	if err != nil {
+		return SourceFile{}, err
+	}
+	if info.IsDir() {
+		return SourceFile{}, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return SourceFile{Name: name, ModTime: info.ModTime(), Size: info.Size()}, nil
+}
+
+func (s *FSSource) List() ([]SourceFile, error) {
+	var files []SourceFile
+	err := fs.WalkDir(s.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, SourceFile{Name: path.Clean(name), ModTime: info.ModTime(), Size: info.Size()})
+		return nil
+	})
+	return sortFiles(files), err
+}
+
+func (s *FSSource) Version() (string, error) {
+	return "", nil
+}
+
+// Watch 编译进程序的数据不会变化，其他fs.FS由ConfigManager轮询
+func (s *FSSource) Watch(changed func(names []string)) (func(), error) {
+	return nil, ErrWatchNotSupported
+}
@@ -0,0 +1,176 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SourceIndexName http数据来源的文件列表
+const SourceIndexName = "index.json"
+
+// SourceIndex <url>/index.json 的内容
+type SourceIndex struct {
+	Version string       `json:"version"`
+	Files   []SourceFile `json:"files"`
+}
+
+// HTTPSource 远程数据，从 <url>/index.json 取得文件列表和版本，从 <url>/<文件名> 取得文件，由ConfigManager轮询
+type HTTPSource struct {
+	url    string
+	Client *http.Client
+
+	mu    sync.Mutex
+	index *SourceIndex //最后一次取得的文件列表
+}
+
+func NewHTTPSource(url string) *HTTPSource {
+	return &HTTPSource{url: strings.TrimSuffix(url, "/"), Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (s *HTTPSource) String() string {
+	return s.url
+}
+
+func (s *HTTPSource) get(name string) ([]byte, error) {
+	u, err := url.JoinPath(s.url, name)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.Client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, &fs.PathError{Op: "get", Path: name, Err: fs.ErrNotExist}
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("get %s: %s", u, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// fetchIndex 取得最新的文件列表
+func (s *HTTPSource) fetchIndex() (*SourceIndex, error) {
+	data, err := s.get(SourceIndexName)
+	if err != nil {
+		return nil, err
+	}
+	index := new(SourceIndex)
+	if err := json.Unmarshal(data, index); err != nil {
+		return nil, fmt.Errorf("%s: %w", SourceIndexName, err)
+	}
+	s.mu.Lock()
+	s.index = index
+	s.mu.Unlock()
+	return index, nil
+}
+
+// cachedIndex 最后一次取得的文件列表，还没有时取得
+func (s *HTTPSource) cachedIndex() (*SourceIndex, error) {
+	s.mu.Lock()
+	index := s.index
+	s.mu.Unlock()
+	if index != nil {
+		return index, nil
+	}
+	return s.fetchIndex()
+}
+
+func (s *HTTPSource) ReadFile(name string) ([]byte, error) {
+	return s.get(name)
+}
+
+func (s *HTTPSource) Stat(name string) (SourceFile, error) {
+	index, err := s.cachedIndex()
+	if err != nil {
+		return SourceFile{}, err
+	}
+	for _, f := range index.Files {
+		if f.Name == name {
+			return f, nil
+		}
+	}
+	return SourceFile{}, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+// List 使用Version时取得的文件列表，轮询时每次只请求一次index.json
+func (s *HTTPSource) List() ([]SourceFile, error) {
+	index, err := s.cachedIndex()
+	if err != nil {
+		return nil, err
+	}
+	return append([]SourceFile(nil), index.Files...), nil
+}
+
+func (s *HTTPSource) Version() (string, error) {
+	index, err := s.fetchIndex()
+	if err != nil {
+		return "", err
+	}
+	return index.Version, nil
+}
+
+func (s *HTTPSource) Watch(changed func(names []string)) (func(), error) {
+	return nil, ErrWatchNotSupported
+}
+
+// SourceHandler 把数据来源作为http数据来源提供，例如 http.Handle("/config/", http.StripPrefix("/config", SourceHandler(src)))
+func SourceHandler(src Source) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/")
+		if name == SourceIndexName {
+			index, err := sourceIndex(src)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(index)
+			return
+		}
+		if !fs.ValidPath(name) {
+			http.Error(w, "invalid path", http.StatusBadRequest)
+			return
+		}
+		data, err := src.ReadFile(name)
+		switch {
+		case err == nil:
+			w.Write(data)
+		case isNotExist(err):
+			http.NotFound(w, r)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// sourceIndex 数据来源没有版本时用文件列表的sha256作为版本
+func sourceIndex(src Source) (*SourceIndex, error) {
+	version, err := src.Version()
+	if err != nil {
+		return nil, err
+	}
+	files, err := src.List()
+	if err != nil {
+		return nil, err
+	}
+	if version == "" {
+		data, err := json.Marshal(files)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		version = hex.EncodeToString(sum[:])
+	}
+	return &SourceIndex{Version: version, Files: files}, nil
+}
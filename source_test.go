@@ -0,0 +1,245 @@
+package config
+
+import (
+	"archive/zip"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// sourceTestFiles 每种数据来源中的文件
+var sourceTestFiles = map[string]string{
+	"p/a.json": `{"N":1}`,
+	"p/b.bin":  "bin",
+	"q/c.json": `{}`,
+}
+
+func sourceTestMapFS(prefix string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for name, content := range sourceTestFiles {
+		fsys[prefix+name] = &fstest.MapFile{Data: []byte(content)}
+	}
+	return fsys
+}
+
+func TestSources(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range sourceTestFiles {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), os.ModePerm)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	zipPath := filepath.Join(t.TempDir(), "data.zip")
+	writeTestZip(t, zipPath, sourceTestFiles)
+	embedded, err := (&Config{Source: SourceConfig{Type: "embed", Path: "data/"}}).NewSource(sourceTestMapFS("data/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(SourceHandler(NewFSSource(sourceTestMapFS(""))))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		src      Source
+		watch    bool //支持变化通知
+		wantHash bool //List提供校验值
+	}{
+		{name: "dir", src: NewDirSource(dir), watch: true},
+		{name: "embed", src: embedded},
+		{name: "fs", src: NewFSSource(sourceTestMapFS(""))},
+		{name: "zip", src: NewZipSource(zipPath), wantHash: true},
+		{name: "http", src: NewHTTPSource(server.URL + "/")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := tt.src.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, f := range files {
+				names = append(names, f.Name)
+				if f.Size != int64(len(sourceTestFiles[f.Name])) {
+					t.Errorf("%s size %d", f.Name, f.Size)
+				}
+				if (f.Hash != "") != tt.wantHash {
+					t.Errorf("%s hash %q", f.Name, f.Hash)
+				}
+			}
+			if want := []string{"p/a.json", "p/b.bin", "q/c.json"}; !reflect.DeepEqual(names, want) {
+				t.Errorf("list %v, want %v", names, want)
+			}
+			for name, content := range sourceTestFiles {
+				data, err := tt.src.ReadFile(name)
+				if err != nil || string(data) != content {
+					t.Errorf("read %s: %q %v", name, data, err)
+				}
+			}
+			file, err := tt.src.Stat("p/a.json")
+			if err != nil || file.Name != "p/a.json" || file.Size != int64(len(sourceTestFiles["p/a.json"])) {
+				t.Errorf("stat %+v %v", file, err)
+			}
+			//目录和不存在的文件都返回fs.ErrNotExist
+			for _, name := range []string{"p", "missing.json", "p/missing.json"} {
+				if _, err := tt.src.Stat(name); !errors.Is(err, fs.ErrNotExist) {
+					t.Errorf("stat %s: %v", name, err)
+				}
+			}
+			if _, err := tt.src.ReadFile("p/missing.json"); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("read missing: %v", err)
+			}
+			stop, err := tt.src.Watch(func(names []string) {})
+			if tt.watch {
+				if err != nil {
+					t.Fatalf("watch: %v", err)
+				}
+				stop()
+			} else if !errors.Is(err, ErrWatchNotSupported) {
+				t.Errorf("watch: %v, want %v", err, ErrWatchNotSupported)
+			}
+		})
+	}
+}
+
+// lockedFS 测试goroutine修改MapFS时和http服务的goroutine互斥
+type lockedFS struct {
+	mu   sync.Mutex
+	fsys fstest.MapFS
+}
+
+func (l *lockedFS) Open(name string) (fs.File, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.fsys.Open(name)
+}
+
+func (l *lockedFS) set(name, content string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if content == "" {
+		delete(l.fsys, name)
+		return
+	}
+	l.fsys[name] = &fstest.MapFile{Data: []byte(content), ModTime: time.Now()}
+}
+
+func TestHTTPSource(t *testing.T) {
+	fsys := &lockedFS{fsys: sourceTestMapFS("")}
+	server := httptest.NewServer(SourceHandler(NewFSSource(fsys)))
+	defer server.Close()
+	src := NewHTTPSource(server.URL)
+
+	version, err := src.Version()
+	if err != nil || version == "" {
+		t.Fatalf("version %q %v", version, err)
+	}
+	if again, _ := src.Version(); again != version {
+		t.Errorf("version changed without changes: %s, %s", version, again)
+	}
+
+	tests := []struct {
+		name    string
+		change  func()
+		changed bool
+	}{
+		{name: "unchanged", change: func() {}},
+		{name: "file added", change: func() { fsys.set("q/d.json", `{}`) }, changed: true},
+		{name: "file modified", change: func() { fsys.set("p/a.json", `{"N":22}`) }, changed: true},
+		{name: "file deleted", change: func() { fsys.set("q/d.json", "") }, changed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.change()
+			next, err := src.Version()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (next != version) != tt.changed {
+				t.Errorf("version %s -> %s, changed %v", version, next, tt.changed)
+			}
+			version = next
+		})
+	}
+
+	//List和Stat使用Version时取得的文件列表
+	fsys.set("q/e.json", `{}`)
+	if _, err := src.Stat("q/e.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("stat before version: %v", err)
+	}
+	src.Version()
+	if file, err := src.Stat("q/e.json"); err != nil || file.Size != 2 {
+		t.Errorf("stat after version: %+v %v", file, err)
+	}
+	if data, err := src.ReadFile("p/a.json"); err != nil || string(data) != `{"N":22}` {
+		t.Errorf("read %q %v", data, err)
+	}
+
+	resp, err := http.Get(server.URL + "/../p/a.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		t.Errorf("invalid path served")
+	}
+}
+
+func TestZipSourceReplaced(t *testing.T) {
+	zipPath := filepath.Join(t.TempDir(), "data.zip")
+	writeTestZip(t, zipPath, map[string]string{"p/a.json": `{"N":1}`})
+	src := NewZipSource(zipPath)
+	version, err := src.Version()
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, _ := src.Stat("p/a.json")
+	writeTestZip(t, zipPath, map[string]string{"p/a.json": `{"N":2}`, "p/b.json": `{}`})
+	if next, _ := src.Version(); next == version {
+		t.Errorf("version not changed: %s", next)
+	}
+	after, err := src.Stat("p/a.json")
+	if err != nil || after.Hash == before.Hash {
+		t.Errorf("hash %s -> %s %v", before.Hash, after.Hash, err)
+	}
+	if data, _ := src.ReadFile("p/a.json"); string(data) != `{"N":2}` {
+		t.Errorf("read %q", data)
+	}
+	if files, _ := src.List(); len(files) != 2 {
+		t.Errorf("list %v", files)
+	}
+}
+
+func writeTestZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	//写到临时文件再改名，大小不同保证版本变化
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(f)
+	for name, content := range files {
+		fw, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ZipSource zip包中的数据，zip文件被替换时重新打开，由ConfigManager轮询
+type ZipSource struct {
+	path string
+
+	mu      sync.Mutex
+	reader  *zip.ReadCloser
+	files   map[string]*zip.File //key：文件名
+	version string
+}
+
+func NewZipSource(path string) *ZipSource {
+	return &ZipSource{path: path}
+}
+
+func (s *ZipSource) String() string {
+	return s.path
+}
+
+// open zip文件的修改时间或大小变化时重新打开，调用时持有锁
+func (s *ZipSource) open() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	version := fmt.Sprintf("%d-%d", info.ModTime().UnixNano(), info.Size())
+	if s.reader != nil && s.version == version {
+		return nil
+	}
+	reader, err := zip.OpenReader(s.path)
+	if err != nil {
+		return err
+	}
+	if s.reader != nil {
+		s.reader.Close()
+	}
+	s.reader, s.version = reader, version
+	s.files = make(map[string]*zip.File, len(reader.File))
+	for _, f := range reader.File {
+		if !f.FileInfo().IsDir() {
+			s.files[strings.TrimPrefix(f.Name, "./")] = f
+		}
+	}
+	return nil
+}
+
+func (s *ZipSource) ReadFile(name string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	f, ok := s.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+func (s *ZipSource) Stat(name string) (SourceFile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.open(); err != nil {
+		return SourceFile{}, err
+	}
+	f, ok := s.files[name]
+	if !ok {
+		return SourceFile{}, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return zipFile(name, f), nil
+}
+
+func (s *ZipSource) List() ([]SourceFile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	files := make([]SourceFile, 0, len(s.files))
+	for name, f := range s.files {
+		files = append(files, zipFile(name, f))
+	}
+	return sortFiles(files), nil
+}
+
+// Version zip文件的修改时间和大小
+func (s *ZipSource) Version() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.open(); err != nil {
+		return "", err
+	}
+	return s.version, nil
+}
+
+func (s *ZipSource) Watch(changed func(names []string)) (func(), error) {
+	return nil, ErrWatchNotSupported
+}
+
+// zipFile zip中记录的修改时间可能为空，用crc32作为校验值
+func zipFile(name string, f *zip.File) SourceFile {
+	return SourceFile{Name: name, ModTime: f.Modified, Size: int64(f.UncompressedSize64), Hash: fmt.Sprintf("%08x", f.CRC32)}
+}
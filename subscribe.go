@@ -0,0 +1,82 @@
+package config
+
+import (
+	"log/slog"
+	"path"
+)
+
+// ReloadEvent 表重新加载的结果，Err为空时New为新发布的数据，失败时New为空并且Old仍然在使用
+// 文件被删除时Deleted为true，数据实现IClearOnDelete并返回true时New为发布的空数据，否则New为空并且Old仍然在使用
+type ReloadEvent struct {
+	FileName string      //和GetFileName一致
+	Old      interface{} //重新加载前的数据
+	New      interface{} //重新加载后的数据
+	Deleted  bool        //基础数据和overlay中的文件都被删除
+	Err      error
+}
+
+// IClearOnDelete 数据文件被删除时是否发布空数据，没有实现时保留旧数据
+type IClearOnDelete interface {
+	ClearOnDelete() bool
+}
+
+type subscriber struct {
+	id      int
+	pattern string
+	fn      func(ReloadEvent)
+}
+
+// Subscribe 订阅重新加载事件，pattern为文件名或path.Match的模式(例如 testpkg/*.json)，为空时订阅所有表，返回取消订阅的函数
+// 事件在监听goroutine中按重新加载的顺序通知，同一个事件按订阅的顺序通知；回调应尽快返回，耗时的工作放到其他goroutine中
+func (m *ConfigManager) Subscribe(pattern string, fn func(ReloadEvent)) (unsubscribe func()) {
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+	m.nextSubID++
+	id := m.nextSubID
+	m.subscribers = append(m.subscribers, &subscriber{id: id, pattern: pattern, fn: fn})
+	return func() {
+		m.subMu.Lock()
+		defer m.subMu.Unlock()
+		for i, sub := range m.subscribers {
+			if sub.id == id {
+				//复制一份，通知中使用的旧列表不受影响
+				m.subscribers = append(m.subscribers[:i:i], m.subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (m *ConfigManager) notify(events []ReloadEvent) {
+	if len(events) == 0 {
+		return
+	}
+	m.subMu.Lock()
+	subscribers := m.subscribers
+	m.subMu.Unlock()
+	for _, event := range events {
+		for _, sub := range subscribers {
+			if sub.match(event.FileName) {
+				sub.call(event)
+			}
+		}
+	}
+}
+
+func (s *subscriber) match(fileName string) bool {
+	if s.pattern == "" || s.pattern == fileName {
+		return true
+	}
+	ok, _ := path.Match(s.pattern, fileName)
+	return ok
+}
+
+// call 回调panic时只记录日志，不影响其他订阅者和监听goroutine
+func (s *subscriber) call(event ReloadEvent) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("config reload subscriber panic:", "fileName", event.FileName, "err", r)
+		}
+	}()
+	s.fn(event)
+}
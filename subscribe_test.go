@@ -0,0 +1,111 @@
+package config
+
+import (
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSubscribeMatch(t *testing.T) {
+	fsys := fstest.MapFS{
+		"testpkg/a.json":     testFile(0),
+		"testpkg/sub/b.json": testFile(0),
+		"other/c.json":       testFile(0),
+	}
+	m := newTestManager(fsys, "testpkg/a.json", "testpkg/sub/b.json", "other/c.json")
+	if err := m.LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{pattern: "", want: []string{"other/c.json", "testpkg/a.json", "testpkg/sub/b.json"}},
+		{pattern: "testpkg/a.json", want: []string{"testpkg/a.json"}},
+		//*不匹配/
+		{pattern: "testpkg/*.json", want: []string{"testpkg/a.json"}},
+		{pattern: "*/*/*.json", want: []string{"testpkg/sub/b.json"}},
+		{pattern: "testpkg/a.bin"},
+		{pattern: "["},
+	}
+	got := make([][]string, len(tests))
+	for i, tt := range tests {
+		m.Subscribe(tt.pattern, func(event ReloadEvent) {
+			got[i] = append(got[i], event.FileName)
+		})
+	}
+	if err := m.Reload("testpkg/a.json", "testpkg/sub/b.json", "other/c.json"); err != nil {
+		t.Fatal(err)
+	}
+	for i, tt := range tests {
+		slices.Sort(got[i])
+		if !slices.Equal(got[i], tt.want) {
+			t.Errorf("pattern %q: got %v, want %v", tt.pattern, got[i], tt.want)
+		}
+	}
+}
+
+func TestSubscribeUnsubscribe(t *testing.T) {
+	m := newTestManager(fstest.MapFS{"a.json": testFile(0)}, "a.json")
+	if err := m.LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+	var calls []string
+	var unsubscribeB func()
+	m.Subscribe("", func(event ReloadEvent) {
+		calls = append(calls, "a")
+		//通知中取消订阅，这次通知仍然使用旧列表
+		unsubscribeB()
+	})
+	unsubscribeB = m.Subscribe("", func(event ReloadEvent) {
+		calls = append(calls, "b")
+	})
+	unsubscribeC := m.Subscribe("", func(event ReloadEvent) {
+		calls = append(calls, "c")
+	})
+	if err := m.Reload("a.json"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b", "c"}; !slices.Equal(calls, want) {
+		t.Errorf("first reload calls %v, want %v", calls, want)
+	}
+
+	calls = nil
+	unsubscribeC()
+	//重复取消订阅不影响其他订阅者
+	unsubscribeC()
+	if err := m.Reload("a.json"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a"}; !slices.Equal(calls, want) {
+		t.Errorf("second reload calls %v, want %v", calls, want)
+	}
+}
+
+func TestSubscribePanic(t *testing.T) {
+	fsys := fstest.MapFS{"a.json": testFile(0), "b.json": testFile(0)}
+	m := newTestManager(fsys, "a.json", "b.json")
+	if err := m.LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+	var calls []string
+	m.Subscribe("a.json", func(event ReloadEvent) {
+		panic("subscriber failed")
+	})
+	m.Subscribe("", func(event ReloadEvent) {
+		calls = append(calls, event.FileName)
+	})
+	fsys["a.json"] = testFile(1)
+	fsys["b.json"] = testFile(2)
+	if err := m.Reload("a.json", "b.json"); err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(calls)
+	if want := []string{"a.json", "b.json"}; !slices.Equal(calls, want) {
+		t.Errorf("calls %v, want %v", calls, want)
+	}
+	gen := m.Current()
+	if testValue(gen, "a.json") != 1 || testValue(gen, "b.json") != 2 {
+		t.Errorf("published a=%d b=%d", testValue(gen, "a.json"), testValue(gen, "b.json"))
+	}
+}
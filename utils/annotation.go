@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"encoding/xml"
+	"errors"
+	"strings"
+)
+
+// Annotations 元数据上未被解析的自定义属性，供生成器和编辑器读取
+// key为 前缀:名字(例如 editor:widget、go:tag)，不带前缀时为名字(例如 index)
+type Annotations []xml.Attr
+
+// Lookup 按key查找属性
+func (a Annotations) Lookup(key string) (string, bool) {
+	for _, attr := range a {
+		if annotationKey(attr.Name) == key {
+			return attr.Value, true
+		}
+	}
+	return "", false
+}
+
+// Get 按key查找属性，不存在时返回空字符串
+func (a Annotations) Get(key string) string {
+	val, _ := a.Lookup(key)
+	return val
+}
+
+// Map 转换为map，方便模板使用
+func (a Annotations) Map() map[string]string {
+	m := make(map[string]string, len(a))
+	for _, attr := range a {
+		m[annotationKey(attr.Name)] = attr.Value
+	}
+	return m
+}
+
+// knownAttrs 元数据中已经解析的属性，只有大小写不同的注解(例如valuetype)按拼写错误报错
+var knownAttrs = []string{"name", "type", "valueType", "keyType", "alias", "required", "min", "max", "default"}
+
+// checkAnnotations 检查和已知属性只有大小写不同的注解
+func checkAnnotations(owner string, a Annotations) error {
+	for _, attr := range a {
+		if attr.Name.Space != "" {
+			continue
+		}
+		for _, known := range knownAttrs {
+			if attr.Name.Local != known && strings.EqualFold(attr.Name.Local, known) {
+				return errors.New("unknown attribute " + owner + " " + attr.Name.Local + ", did you mean " + known)
+			}
+		}
+	}
+	return nil
+}
+
+func annotationKey(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// UnmarshalXML 解析后把注解的命名空间换回xml中写的前缀，
+// encoding/xml会把声明过xmlns的前缀替换为命名空间uri，没有声明时保留前缀
+func (conf *Conf) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type rawConf Conf
+	if err := d.DecodeElement((*rawConf)(conf), &start); err != nil {
+		return err
+	}
+	prefixes := declarePrefixes(nil, start.Attr)
+	for i := range conf.Enums {
+		enum := &conf.Enums[i]
+		scope := resolvePrefixes(prefixes, enum.Annotations)
+		for j := range enum.Vars {
+			resolvePrefixes(scope, enum.Vars[j].Annotations)
+		}
+	}
+	for _, structs := range [][]Struct{conf.Structs, conf.Tables} {
+		for i := range structs {
+			scope := resolvePrefixes(prefixes, structs[i].Annotations)
+			for j := range structs[i].Vars {
+				resolvePrefixes(scope, structs[i].Vars[j].Annotations)
+			}
+		}
+	}
+	return nil
+}
+
+// declarePrefixes 在外层的 uri->前缀 上加入attrs中的xmlns声明
+func declarePrefixes(outer map[string]string, attrs []xml.Attr) map[string]string {
+	var prefixes map[string]string
+	for _, attr := range attrs {
+		if attr.Name.Space != "xmlns" {
+			continue
+		}
+		if prefixes == nil {
+			prefixes = make(map[string]string, len(outer)+1)
+			for uri, prefix := range outer {
+				prefixes[uri] = prefix
+			}
+		}
+		prefixes[attr.Value] = attr.Name.Local
+	}
+	if prefixes == nil {
+		return outer
+	}
+	return prefixes
+}
+
+// resolvePrefixes 把注解的命名空间uri换回前缀，返回加入该元素的xmlns声明后的作用域
+func resolvePrefixes(outer map[string]string, a Annotations) map[string]string {
+	prefixes := declarePrefixes(outer, a)
+	for i, attr := range a {
+		if prefix, ok := prefixes[attr.Name.Space]; ok && attr.Name.Space != "xmlns" {
+			a[i].Name.Space = prefix
+		}
+	}
+	return prefixes
+}
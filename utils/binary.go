@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"github.com/mogebingxue/game_config_manager/datafile"
+)
+
+// SchemaHash 表的二进制编码布局的hash，字段顺序、类型变化时改变，别名和枚举值变化不影响
+func SchemaHash(typMap map[string]Meta, table *Struct) uint32 {
+	var sb strings.Builder
+	writeSignature(&sb, typMap, table, 0)
+	h := fnv.New32a()
+	h.Write([]byte(sb.String()))
+	return h.Sum32()
+}
+
+func writeSignature(sb *strings.Builder, typMap map[string]Meta, st *Struct, level int) {
+	sb.WriteString("{")
+	for _, v := range st.Vars {
+		sb.WriteString(v.Name + ":")
+		writeTypeSignature(sb, typMap, v.Typ, level)
+		if v.Typ == "list" || v.Typ == "map" {
+			sb.WriteString("<" + v.KeyType + ",")
+			writeTypeSignature(sb, typMap, v.ValueType, level)
+			sb.WriteString(">")
+		}
+		sb.WriteString(";")
+	}
+	sb.WriteString("}")
+}
+
+func writeTypeSignature(sb *strings.Builder, typMap map[string]Meta, typ string, level int) {
+	meta, ok := typMap[typ]
+	switch {
+	case ok && meta.Typ == ENUM:
+		sb.WriteString("enum")
+	case ok && meta.Typ == STRUCT && level < 10:
+		writeSignature(sb, typMap, meta.Meta.(*Struct), level+1)
+	default:
+		sb.WriteString(typ)
+	}
+}
+
+// EncodeBinary 按表定义把数据编码为二进制，没有填写的字段写零值
+// sourceHash为config.BinarySourceHash计算的json数据的hash，加载时和json数据不一致就不使用二进制文件
+func EncodeBinary(typMap map[string]Meta, table *Struct, data map[string]any, sourceHash [sha256.Size]byte) ([]byte, error) {
+	w := datafile.NewBinaryWriter(SchemaHash(typMap, table), sourceHash)
+	if err := encodeStruct(w, typMap, table, data, 0); err != nil {
+		return nil, err
+	}
+	return w.Bytes(), nil
+}
+
+func encodeStruct(w *datafile.BinaryWriter, typMap map[string]Meta, st *Struct, data map[string]any, level int) error {
+	if level > 10 {
+		return fmt.Errorf("%s nested too deep", st.Name)
+	}
+	for _, v := range st.Vars {
+		if err := encodeValue(w, typMap, v, data[v.Name], level); err != nil {
+			return fmt.Errorf("%s.%w", st.Name, err)
+		}
+	}
+	return nil
+}
+
+func encodeValue(w *datafile.BinaryWriter, typMap map[string]Meta, v StructVar, val any, level int) error {
+	switch v.Typ {
+	case "int":
+		num, _ := toInt(val)
+		w.WriteInt(num)
+	case "string":
+		str, _ := val.(string)
+		w.WriteString(str)
+	case "bool":
+		b, _ := val.(bool)
+		w.WriteBool(b)
+	case "list":
+		list, _ := val.([]any)
+		elem := StructVar{Name: v.Name, Typ: v.ValueType}
+		w.WriteLen(len(list))
+		for _, item := range list {
+			if err := encodeValue(w, typMap, elem, item, level); err != nil {
+				return err
+			}
+		}
+	case "map":
+		m, _ := val.(map[string]any)
+		elem := StructVar{Name: v.Name, Typ: v.ValueType}
+		w.WriteLen(len(m))
+		for _, k := range SortedKeys(m) {
+			if v.KeyType == "int" {
+				num, err := strconv.Atoi(k)
+				if err != nil {
+					return fmt.Errorf("%s: map key %q is not int", v.Name, k)
+				}
+				w.WriteInt(num)
+			} else {
+				w.WriteString(k)
+			}
+			if err := encodeValue(w, typMap, elem, m[k], level); err != nil {
+				return err
+			}
+		}
+	default:
+		meta, ok := typMap[v.Typ]
+		if !ok {
+			return fmt.Errorf("%s: type not found %s", v.Name, v.Typ)
+		}
+		switch meta.Typ {
+		case ENUM:
+			num, _ := toInt(val)
+			w.WriteInt(num)
+		case STRUCT:
+			m, _ := val.(map[string]any)
+			return encodeStruct(w, typMap, meta.Meta.(*Struct), m, level+1)
+		default:
+			return fmt.Errorf("%s: type %s not support", v.Name, v.Typ)
+		}
+	}
+	return nil
+}
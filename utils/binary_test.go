@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/mogebingxue/game_config_manager"
+	"github.com/mogebingxue/game_config_manager/datafile"
+	"github.com/mogebingxue/game_config_manager/example/conf_go/testpkg"
+)
+
+func TestBinaryRoundTrip(t *testing.T) {
+	schema, err := LoadSchema("../example/metadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, _ := schema.LookupType("testpkg", "TestTable")
+	typMap, table := schema.TypeMap("testpkg"), meta.Meta.(*Struct)
+	if got, want := SchemaHash(typMap, table), (*testpkg.TestTable)(nil).GetSchemaHash(); got != want {
+		t.Fatalf("schema hash %08x, generated code %08x", got, want)
+	}
+	example, err := os.ReadFile("../example/data/testpkg/TestTable.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"example", string(example)},
+		{"empty", `{}`},
+		{"zero values", `{"TestInt":0,"TestString":"","TestBool":false,"TestList":[],"TestMap":{}}`},
+		{"negative", `{"TestInt":-7,"TestList":[2,2,1],"TestStruct":{"TestSubStruct":{"TestInt":-1,"TestString":"中文"}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var raw map[string]any
+			if err := json.Unmarshal([]byte(tt.data), &raw); err != nil {
+				t.Fatal(err)
+			}
+			resolved, _, err := datafile.ResolveTemplates(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sourceHash := datafile.BinarySourceHash([]byte(tt.data))
+			data, err := EncodeBinary(typMap, table, resolved, sourceHash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := config.CheckBinarySource(data, sourceHash); err != nil {
+				t.Fatalf("CheckBinarySource: %v", err)
+			}
+			got := new(testpkg.TestTable)
+			if err := config.DecodeBinary(data, got); err != nil {
+				t.Fatal(err)
+			}
+			//和ConfigManager解析json的结果一致，空的list、map解码为nil
+			resolvedJson, _ := json.Marshal(resolved)
+			want := new(testpkg.TestTable)
+			if err := json.Unmarshal(resolvedJson, want); err != nil {
+				t.Fatal(err)
+			}
+			if len(want.TestList) == 0 {
+				want.TestList = nil
+			}
+			if len(want.TestMap) == 0 {
+				want.TestMap = nil
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v\nwant %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestBinaryDecodeErrors(t *testing.T) {
+	schema, err := LoadSchema("../example/metadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, _ := schema.LookupType("testpkg", "TestTable")
+	data, err := EncodeBinary(schema.TypeMap("testpkg"), meta.Meta.(*Struct), map[string]any{"TestString": "Hello"}, datafile.BinarySourceHash(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mismatch := append([]byte(nil), data...)
+	mismatch[4]++ //元数据hash的第一个字节
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"truncated", data[:len(data)-2], config.ErrBinaryCorrupted},
+		{"header only", data[:datafile.BinaryHeaderSize-1], config.ErrBinaryCorrupted},
+		{"schema mismatch", mismatch, config.ErrBinarySchemaMismatch},
+		{"trailing bytes", append(append([]byte(nil), data...), 0), config.ErrBinaryCorrupted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := config.DecodeBinary(tt.data, new(testpkg.TestTable)); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,320 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+)
+
+type CHANGE_LEVEL int32
+
+const (
+	COMPATIBLE CHANGE_LEVEL = 1
+	BREAKING   CHANGE_LEVEL = 2
+)
+
+func (l CHANGE_LEVEL) String() string {
+	if l == BREAKING {
+		return "breaking"
+	}
+	return "compatible"
+}
+
+type CHANGE_KIND int32
+
+const (
+	ADDED         CHANGE_KIND = 1
+	REMOVED       CHANGE_KIND = 2
+	TYPE_CHANGED  CHANGE_KIND = 3 //字段类型或者类型种类变化
+	RENUMBERED    CHANGE_KIND = 4 //枚举值的数值变化
+	ALIAS_CHANGED CHANGE_KIND = 5
+	VALUE_CHANGED CHANGE_KIND = 6 //常量值变化
+)
+
+// SchemaChange 两个版本元数据之间的一处变化
+// Type为空表示包的变化，Member为空表示类型的变化，否则是字段或枚举值的变化
+type SchemaChange struct {
+	Level   CHANGE_LEVEL
+	Kind    CHANGE_KIND
+	Package string
+	Type    string
+	Member  string
+	Old     string //旧的类型或数值
+	New     string //新的类型或数值
+}
+
+func (c SchemaChange) Path() string {
+	path := c.Package
+	if c.Type != "" {
+		path += "." + c.Type
+	}
+	if c.Member != "" {
+		path += "." + c.Member
+	}
+	return path
+}
+
+func (c SchemaChange) String() string {
+	switch c.Kind {
+	case ADDED:
+		return fmt.Sprintf("[%s] %s added", c.Level, c.Path())
+	case REMOVED:
+		return fmt.Sprintf("[%s] %s removed", c.Level, c.Path())
+	case TYPE_CHANGED:
+		return fmt.Sprintf("[%s] %s type changed: %s -> %s", c.Level, c.Path(), c.Old, c.New)
+	case RENUMBERED:
+		return fmt.Sprintf("[%s] %s renumbered: %s -> %s", c.Level, c.Path(), c.Old, c.New)
+	case ALIAS_CHANGED:
+		return fmt.Sprintf("[%s] %s alias changed: %s -> %s", c.Level, c.Path(), c.Old, c.New)
+	case VALUE_CHANGED:
+		return fmt.Sprintf("[%s] %s value changed: %s -> %s", c.Level, c.Path(), c.Old, c.New)
+	}
+	return fmt.Sprintf("[%s] %s", c.Level, c.Path())
+}
+
+// DiffSchema 比较两个版本的元数据，按包、类型的定义顺序返回所有变化
+func DiffSchema(oldSchema, newSchema *Schema) []SchemaChange {
+	var changes []SchemaChange
+	for _, pkg := range oldSchema.Packages() {
+		if _, ok := newSchema.Package(pkg); !ok {
+			changes = append(changes, SchemaChange{Level: BREAKING, Kind: REMOVED, Package: pkg})
+			continue
+		}
+		changes = append(changes, diffPackage(pkg, oldSchema, newSchema)...)
+	}
+	for _, pkg := range newSchema.Packages() {
+		if _, ok := oldSchema.Package(pkg); !ok {
+			changes = append(changes, SchemaChange{Level: COMPATIBLE, Kind: ADDED, Package: pkg})
+		}
+	}
+	return changes
+}
+
+func diffPackage(pkg string, oldSchema, newSchema *Schema) []SchemaChange {
+	var changes []SchemaChange
+	oldConf, _ := oldSchema.Package(pkg)
+	newConf, _ := newSchema.Package(pkg)
+	if oldConf.Alias != newConf.Alias {
+		changes = append(changes, SchemaChange{Level: COMPATIBLE, Kind: ALIAS_CHANGED, Package: pkg, Old: oldConf.Alias, New: newConf.Alias})
+	}
+	oldTypMap, newTypMap := oldSchema.TypeMap(pkg), newSchema.TypeMap(pkg)
+	for _, name := range typeNames(oldConf) {
+		oldMeta := oldTypMap[name]
+		newMeta, ok := newTypMap[name]
+		if !ok {
+			changes = append(changes, SchemaChange{Level: BREAKING, Kind: REMOVED, Package: pkg, Type: name})
+			continue
+		}
+		if oldMeta.Typ != newMeta.Typ {
+			changes = append(changes, SchemaChange{Level: BREAKING, Kind: TYPE_CHANGED, Package: pkg, Type: name,
+				Old: metaTypeName(oldMeta.Typ), New: metaTypeName(newMeta.Typ)})
+			continue
+		}
+		if oldMeta.Typ == ENUM {
+			changes = append(changes, diffEnum(pkg, oldMeta.Meta.(*Enum), newMeta.Meta.(*Enum))...)
+		} else {
+			changes = append(changes, diffStruct(pkg, oldMeta.Meta.(*Struct), newMeta.Meta.(*Struct))...)
+		}
+	}
+	for _, name := range typeNames(newConf) {
+		if _, ok := oldTypMap[name]; !ok {
+			changes = append(changes, SchemaChange{Level: COMPATIBLE, Kind: ADDED, Package: pkg, Type: name})
+		}
+	}
+	//常量只影响代码，删除和改类型不兼容，改值兼容
+	for _, c := range oldConf.Consts {
+		newMeta, ok := newTypMap[c.Name]
+		if !ok {
+			changes = append(changes, SchemaChange{Level: BREAKING, Kind: REMOVED, Package: pkg, Type: c.Name})
+			continue
+		}
+		if newMeta.Typ != CONST {
+			changes = append(changes, SchemaChange{Level: BREAKING, Kind: TYPE_CHANGED, Package: pkg, Type: c.Name, Old: "const", New: metaTypeName(newMeta.Typ)})
+			continue
+		}
+		newConst := newMeta.Meta.(*Const)
+		if newConst.Typ != c.Typ {
+			changes = append(changes, SchemaChange{Level: BREAKING, Kind: TYPE_CHANGED, Package: pkg, Type: c.Name, Old: c.Typ, New: newConst.Typ})
+		} else if newConst.Value != c.Value {
+			changes = append(changes, SchemaChange{Level: COMPATIBLE, Kind: VALUE_CHANGED, Package: pkg, Type: c.Name, Old: c.Value, New: newConst.Value})
+		}
+	}
+	for _, c := range newConf.Consts {
+		if _, ok := oldTypMap[c.Name]; !ok {
+			changes = append(changes, SchemaChange{Level: COMPATIBLE, Kind: ADDED, Package: pkg, Type: c.Name})
+		}
+	}
+	return changes
+}
+
+func diffEnum(pkg string, oldEnum, newEnum *Enum) []SchemaChange {
+	var changes []SchemaChange
+	if oldEnum.Alias != newEnum.Alias {
+		changes = append(changes, SchemaChange{Level: COMPATIBLE, Kind: ALIAS_CHANGED, Package: pkg, Type: oldEnum.Name, Old: oldEnum.Alias, New: newEnum.Alias})
+	}
+	newVars := make(map[string]EnumVar)
+	for _, v := range newEnum.Vars {
+		newVars[v.Name] = v
+	}
+	for _, v := range oldEnum.Vars {
+		newVar, ok := newVars[v.Name]
+		if !ok {
+			changes = append(changes, SchemaChange{Level: BREAKING, Kind: REMOVED, Package: pkg, Type: oldEnum.Name, Member: v.Name, Old: v.Default})
+			continue
+		}
+		if newVar.Default != v.Default {
+			changes = append(changes, SchemaChange{Level: BREAKING, Kind: RENUMBERED, Package: pkg, Type: oldEnum.Name, Member: v.Name, Old: v.Default, New: newVar.Default})
+		}
+		if newVar.Alias != v.Alias {
+			changes = append(changes, SchemaChange{Level: COMPATIBLE, Kind: ALIAS_CHANGED, Package: pkg, Type: oldEnum.Name, Member: v.Name, Old: v.Alias, New: newVar.Alias})
+		}
+		delete(newVars, v.Name)
+	}
+	for _, v := range newEnum.Vars {
+		if _, ok := newVars[v.Name]; ok {
+			changes = append(changes, SchemaChange{Level: COMPATIBLE, Kind: ADDED, Package: pkg, Type: newEnum.Name, Member: v.Name, New: v.Default})
+		}
+	}
+	return changes
+}
+
+func diffStruct(pkg string, oldStruct, newStruct *Struct) []SchemaChange {
+	var changes []SchemaChange
+	if oldStruct.Alias != newStruct.Alias {
+		changes = append(changes, SchemaChange{Level: COMPATIBLE, Kind: ALIAS_CHANGED, Package: pkg, Type: oldStruct.Name, Old: oldStruct.Alias, New: newStruct.Alias})
+	}
+	newVars := make(map[string]StructVar)
+	for _, v := range newStruct.Vars {
+		newVars[v.Name] = v
+	}
+	for _, v := range oldStruct.Vars {
+		newVar, ok := newVars[v.Name]
+		if !ok {
+			changes = append(changes, SchemaChange{Level: BREAKING, Kind: REMOVED, Package: pkg, Type: oldStruct.Name, Member: v.Name, Old: varTypeName(v)})
+			continue
+		}
+		if varTypeName(newVar) != varTypeName(v) {
+			changes = append(changes, SchemaChange{Level: BREAKING, Kind: TYPE_CHANGED, Package: pkg, Type: oldStruct.Name, Member: v.Name, Old: varTypeName(v), New: varTypeName(newVar)})
+		}
+		if newVar.Alias != v.Alias {
+			changes = append(changes, SchemaChange{Level: COMPATIBLE, Kind: ALIAS_CHANGED, Package: pkg, Type: oldStruct.Name, Member: v.Name, Old: v.Alias, New: newVar.Alias})
+		}
+		delete(newVars, v.Name)
+	}
+	for _, v := range newStruct.Vars {
+		if _, ok := newVars[v.Name]; ok {
+			changes = append(changes, SchemaChange{Level: COMPATIBLE, Kind: ADDED, Package: pkg, Type: newStruct.Name, Member: v.Name, New: varTypeName(v)})
+		}
+	}
+	return changes
+}
+
+// AffectedValue 受不兼容变化影响的一个数据值
+type AffectedValue struct {
+	File    string
+	Pointer string
+	Change  SchemaChange
+}
+
+func (a AffectedValue) String() string {
+	return fmt.Sprintf("%s#%s: %s", a.File, a.Pointer, a.Change)
+}
+
+// FindAffectedData 用旧版本元数据遍历数据，找出受不兼容变化影响的值
+func FindAffectedData(oldSchema *Schema, changes []SchemaChange, files []DataFile) []AffectedValue {
+	var affected []AffectedValue
+	for _, file := range files {
+		oldMeta, ok := oldSchema.LookupType(file.Package, file.Table)
+		if !ok || oldMeta.Typ != TABLE {
+			continue
+		}
+		var pkgChanges []SchemaChange
+		for _, c := range changes {
+			if c.Level == BREAKING && c.Package == file.Package {
+				pkgChanges = append(pkgChanges, c)
+			}
+		}
+		if len(pkgChanges) == 0 {
+			continue
+		}
+		typMap := oldSchema.TypeMap(file.Package)
+		WalkTable(typMap, oldMeta.Meta.(*Struct), file.Data, func(ptr string, typ string, val any) {
+			for _, c := range pkgChanges {
+				if p, ok := affects(c, typMap, ptr, typ, val); ok {
+					affected = append(affected, AffectedValue{File: file.Path, Pointer: p, Change: c})
+				}
+			}
+		})
+	}
+	return affected
+}
+
+// affects 判断值是否受变化影响，返回受影响值的路径
+func affects(c SchemaChange, typMap map[string]Meta, ptr, typ string, val any) (string, bool) {
+	if c.Type == "" {
+		//整个包被删除，只报告表的根节点
+		return ptr, ptr == ""
+	}
+	if c.Type != typ {
+		return "", false
+	}
+	if c.Member == "" {
+		return ptr, true
+	}
+	switch typMap[typ].Typ {
+	case ENUM:
+		num, ok := val.(float64)
+		if !ok {
+			return "", false
+		}
+		old, err := strconv.Atoi(c.Old)
+		return ptr, err == nil && int(num) == old
+	default:
+		m, ok := val.(map[string]any)
+		if !ok {
+			return "", false
+		}
+		_, ok = m[c.Member]
+		return ptr + "/" + EscapePointer(c.Member), ok
+	}
+}
+
+func typeNames(conf *Conf) []string {
+	var names []string
+	for _, v := range conf.Enums {
+		names = append(names, v.Name)
+	}
+	for _, v := range conf.Structs {
+		names = append(names, v.Name)
+	}
+	for _, v := range conf.Tables {
+		names = append(names, v.Name)
+	}
+	return names
+}
+
+func varTypeName(v StructVar) string {
+	switch v.Typ {
+	case "list":
+		return "list<" + v.ValueType + ">"
+	case "map":
+		if v.KeyType == "int" {
+			return "map<int," + v.ValueType + ">"
+		}
+		return "map<" + v.ValueType + ">"
+	}
+	return v.Typ
+}
+
+func metaTypeName(typ META_TYPE) string {
+	switch typ {
+	case ENUM:
+		return "enum"
+	case STRUCT:
+		return "struct"
+	case TABLE:
+		return "table"
+	case CONST:
+		return "const"
+	}
+	return "unknown"
+}
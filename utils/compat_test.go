@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+const compatTestXml = `<conf package="p" alias="包">
+	<const name="MAX" type="int" value="10"/>
+	<enum name="E">
+		<var name="A" default="1"/>
+		<var name="B" default="2"/>
+	</enum>
+	<struct name="S">
+		<var name="X" type="int"/>
+	</struct>
+	<table name="T">
+		<var name="N" type="int"/>
+		<var name="E" type="E"/>
+		<var name="L" type="list" valueType="E"/>
+		<var name="M" type="map" valueType="S"/>
+	</table>
+</conf>`
+
+func TestDiffSchema(t *testing.T) {
+	oldSchema, err := LoadSchema(writeTestFiles(t, map[string]string{"p.xml": compatTestXml}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		replace [2]string //在旧元数据上替换
+		want    []string
+	}{
+		{"same", [2]string{}, nil},
+		{"add field", [2]string{`<var name="N" type="int"/>`, `<var name="N" type="int"/><var name="New" type="string"/>`},
+			[]string{"[compatible] p.T.New added"}},
+		{"add enum value", [2]string{`<var name="B" default="2"/>`, `<var name="B" default="2"/><var name="C" default="3"/>`},
+			[]string{"[compatible] p.E.C added"}},
+		{"alias", [2]string{`alias="包"`, `alias="新包"`},
+			[]string{"[compatible] p alias changed: 包 -> 新包"}},
+		{"const value", [2]string{`value="10"`, `value="20"`},
+			[]string{"[compatible] p.MAX value changed: 10 -> 20"}},
+		{"remove field", [2]string{`<var name="N" type="int"/>`, ``},
+			[]string{"[breaking] p.T.N removed"}},
+		{"change field type", [2]string{`<var name="N" type="int"/>`, `<var name="N" type="string"/>`},
+			[]string{"[breaking] p.T.N type changed: int -> string"}},
+		{"change map key", [2]string{`valueType="S"/>`, `valueType="S" keyType="int"/>`},
+			[]string{"[breaking] p.T.M type changed: map<S> -> map<int,S>"}},
+		{"renumber enum", [2]string{`<var name="B" default="2"/>`, `<var name="B" default="3"/>`},
+			[]string{"[breaking] p.E.B renumbered: 2 -> 3"}},
+		{"remove enum value", [2]string{`<var name="B" default="2"/>`, ``},
+			[]string{"[breaking] p.E.B removed"}},
+		{"struct to enum", [2]string{"<struct name=\"S\">\n\t\t<var name=\"X\" type=\"int\"/>\n\t</struct>", `<enum name="S"/>`},
+			[]string{"[breaking] p.S type changed: struct -> enum"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := compatTestXml
+			if tt.replace[0] != "" {
+				content = replaceOnce(t, content, tt.replace[0], tt.replace[1])
+			}
+			newSchema, err := LoadSchema(writeTestFiles(t, map[string]string{"p.xml": content}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, c := range DiffSchema(oldSchema, newSchema) {
+				got = append(got, c.String())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("changes %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	//删除和新增包
+	otherSchema, err := LoadSchema(writeTestFiles(t, map[string]string{"q.xml": `<conf package="q"/>`}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range DiffSchema(oldSchema, otherSchema) {
+		got = append(got, c.String())
+	}
+	if want := []string{"[breaking] p removed", "[compatible] q added"}; !slices.Equal(got, want) {
+		t.Errorf("changes %q, want %q", got, want)
+	}
+}
+
+func TestFindAffectedData(t *testing.T) {
+	oldSchema, err := LoadSchema(writeTestFiles(t, map[string]string{"p.xml": compatTestXml}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := []DataFile{{Package: "p", Table: "T", Path: "p/T.json", Data: map[string]any{
+		"N": 1.0,
+		"E": 2.0,
+		"L": []any{1.0, 2.0, 2.0},
+		"M": map[string]any{"a": map[string]any{"X": 1.0}, "b": map[string]any{}},
+	}}}
+	tests := []struct {
+		name    string
+		replace [2]string
+		want    []string
+	}{
+		{"compatible", [2]string{`<var name="N" type="int"/>`, `<var name="N" type="int"/><var name="New" type="string"/>`}, nil},
+		{"remove field", [2]string{`<var name="N" type="int"/>`, ``}, []string{"p/T.json#/N"}},
+		{"remove enum value", [2]string{`<var name="B" default="2"/>`, ``}, []string{"p/T.json#/E", "p/T.json#/L/1", "p/T.json#/L/2"}},
+		{"renumber unused enum value", [2]string{`<var name="A" default="1"/>`, `<var name="A" default="5"/>`}, []string{"p/T.json#/L/0"}},
+		{"remove struct field", [2]string{`<var name="X" type="int"/>`, ``}, []string{"p/T.json#/M/a/X"}},
+		{"remove map field", [2]string{`<var name="M" type="map" valueType="S"/>`, ``}, []string{"p/T.json#/M"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newSchema, err := LoadSchema(writeTestFiles(t, map[string]string{"p.xml": replaceOnce(t, compatTestXml, tt.replace[0], tt.replace[1])}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, a := range FindAffectedData(oldSchema, DiffSchema(oldSchema, newSchema), files) {
+				got = append(got, a.File+"#"+a.Pointer)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("affected %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func replaceOnce(t *testing.T, s, old, new string) string {
+	t.Helper()
+	i := strings.Index(s, old)
+	if i < 0 {
+		t.Fatalf("%q not found", old)
+	}
+	return s[:i] + new + s[i+len(old):]
+}
@@ -0,0 +1,170 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mogebingxue/game_config_manager/datafile"
+)
+
+// DataFile 一个表的数据文件
+type DataFile struct {
+	Package string
+	Table   string
+	Path    string //文件路径
+	Data    map[string]any
+}
+
+// DataFileName 表数据相对于数据目录的文件名，和生成代码的GetFileName一致
+func DataFileName(pkg, table string) string {
+	return pkg + "/" + table + ".json"
+}
+
+// ListDataFiles 列出数据目录下所有json文件，按路径排序
+func ListDataFiles(dir string) ([]DataFile, error) {
+	var files []DataFile
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if info == nil {
+			return err
+		}
+		//只读取后缀为json的
+		if info.IsDir() || !strings.HasSuffix(path, ".json") {
+			return nil
+		}
+		files = append(files, DataFile{
+			Package: filepath.Base(filepath.Dir(path)),
+			Table:   strings.TrimSuffix(filepath.Base(path), ".json"),
+			Path:    path,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// LoadDataDir 读取数据目录下所有json文件
+func LoadDataDir(dir string) ([]DataFile, error) {
+	files, err := ListDataFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	for i := range files {
+		content, err := os.ReadFile(files[i].Path)
+		if err != nil {
+			return nil, err
+		}
+		files[i].Data = make(map[string]any)
+		if err := json.Unmarshal(content, &files[i].Data); err != nil {
+			return nil, &DataError{Path: files[i].Path, Err: err}
+		}
+	}
+	return files, nil
+}
+
+// MergeOverlays 和ConfigManager一样按顺序把overlay目录中同一个表的数据合并到data上，没有这个表的overlay跳过，
+// 返回合并结果和读取的overlay文件内容(用于计算二进制文件的hash)，不修改data
+func MergeOverlays(data map[string]any, pkg, table string, overlays []string) (map[string]any, [][]byte, error) {
+	var contents [][]byte
+	for _, dir := range overlays {
+		path := filepath.Join(dir, DataFileName(pkg, table))
+		content, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		var patch map[string]any
+		if err := json.Unmarshal(content, &patch); err != nil {
+			return nil, nil, &DataError{Path: path, Err: err}
+		}
+		contents = append(contents, content)
+		data = datafile.MergeOverlay(data, patch).(map[string]any)
+	}
+	return data, contents, nil
+}
+
+// DataError 数据文件错误，Pointer为json pointer路径
+type DataError struct {
+	Path    string
+	Pointer string
+	Err     error
+}
+
+func (e *DataError) Error() string {
+	if e.Pointer == "" {
+		return e.Path + ": " + e.Err.Error()
+	}
+	return e.Path + "#" + e.Pointer + ": " + e.Err.Error()
+}
+
+func (e *DataError) Unwrap() error {
+	return e.Err
+}
+
+// DataVisitor 遍历数据的回调，typ为值声明的类型，list和map的元素为valueType
+type DataVisitor func(ptr string, typ string, val any)
+
+// WalkTable 按表定义遍历数据
+func WalkTable(typMap map[string]Meta, table *Struct, data map[string]any, visit DataVisitor) {
+	WalkData(typMap, table.Name, "", "", data, visit)
+}
+
+// WalkData 按类型遍历数据，类型不符的值只访问自身不再深入
+func WalkData(typMap map[string]Meta, typ, valueType, ptr string, val any, visit DataVisitor) {
+	visit(ptr, typ, val)
+	switch typ {
+	case "int", "string", "bool":
+	case "list":
+		list, ok := val.([]any)
+		if !ok {
+			return
+		}
+		for i, v := range list {
+			WalkData(typMap, valueType, "", ptr+"/"+strconv.Itoa(i), v, visit)
+		}
+	case "map":
+		m, ok := val.(map[string]any)
+		if !ok {
+			return
+		}
+		for _, k := range SortedKeys(m) {
+			WalkData(typMap, valueType, "", ptr+"/"+EscapePointer(k), m[k], visit)
+		}
+	default:
+		meta, ok := typMap[typ]
+		if !ok || (meta.Typ != STRUCT && meta.Typ != TABLE) {
+			return
+		}
+		m, ok := val.(map[string]any)
+		if !ok {
+			return
+		}
+		for _, v := range meta.Meta.(*Struct).Vars {
+			if sub, ok := m[v.Name]; ok {
+				WalkData(typMap, v.Typ, v.ValueType, ptr+"/"+EscapePointer(v.Name), sub, visit)
+			}
+		}
+	}
+}
+
+// EscapePointer 转义json pointer中的一段
+func EscapePointer(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
+
+// SortedKeys 返回排序后的key
+func SortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,269 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mogebingxue/game_config_manager/datafile"
+)
+
+func (k CHANGE_KIND) String() string {
+	switch k {
+	case ADDED:
+		return "added"
+	case REMOVED:
+		return "removed"
+	case TYPE_CHANGED:
+		return "type_changed"
+	case RENUMBERED:
+		return "renumbered"
+	case ALIAS_CHANGED:
+		return "alias_changed"
+	case VALUE_CHANGED:
+		return "value_changed"
+	}
+	return strconv.Itoa(int(k))
+}
+
+func (k CHANGE_KIND) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// DataChange 两个版本数据之间的一处变化，Path为别名路径，Old、New为展示用的值
+// 整行(map的元素)增加或删除时只记录一处变化
+type DataChange struct {
+	Kind       CHANGE_KIND `json:"kind"`
+	Package    string      `json:"package"`
+	Table      string      `json:"table"`
+	TableAlias string      `json:"table_alias"`
+	Pointer    string      `json:"pointer"`
+	Path       []string    `json:"path"`
+	Old        string      `json:"old,omitempty"`
+	New        string      `json:"new,omitempty"`
+}
+
+// Field 别名路径，例如 测试表 / 测试整型
+func (c DataChange) Field() string {
+	return strings.Join(append([]string{c.TableAlias}, c.Path...), " / ")
+}
+
+func (c DataChange) String() string {
+	switch c.Kind {
+	case ADDED:
+		if c.New == "" {
+			return c.Field() + ": added"
+		}
+		return fmt.Sprintf("%s: + %s", c.Field(), c.New)
+	case REMOVED:
+		if c.Old == "" {
+			return c.Field() + ": removed"
+		}
+		return fmt.Sprintf("%s: - %s", c.Field(), c.Old)
+	}
+	return fmt.Sprintf("%s: %s → %s", c.Field(), c.Old, c.New)
+}
+
+// DiffData 按元数据比较两个版本的数据，比较展开继承后的值，按文件名和字段顺序返回所有变化
+func DiffData(schema *Schema, oldFiles, newFiles []DataFile) ([]DataChange, error) {
+	oldMap, err := resolvedDataMap(oldFiles)
+	if err != nil {
+		return nil, err
+	}
+	newMap, err := resolvedDataMap(newFiles)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(oldMap)+len(newMap))
+	for name := range oldMap {
+		names = append(names, name)
+	}
+	for name := range newMap {
+		if _, ok := oldMap[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var changes []DataChange
+	for _, name := range names {
+		oldFile, hasOld := oldMap[name]
+		newFile, hasNew := newMap[name]
+		file := newFile
+		if !hasNew {
+			file = oldFile
+		}
+		table := &Struct{Name: file.Table, Alias: file.Table}
+		typMap := schema.TypeMap(file.Package)
+		if meta, ok := schema.LookupType(file.Package, file.Table); ok && meta.Typ == TABLE {
+			table = meta.Meta.(*Struct)
+		}
+		d := &dataDiffer{typMap: typMap, base: DataChange{Package: file.Package, Table: file.Table, TableAlias: aliasOr(table.Alias, table.Name)}}
+		//整个表增加或删除时只显示表名，元数据中没有的表按未定义字段比较
+		switch {
+		case !hasOld:
+			d.add(ADDED, "", nil, "", "")
+		case !hasNew:
+			d.add(REMOVED, "", nil, "", "")
+		default:
+			d.diffStruct(table, "", nil, oldFile.Data, newFile.Data)
+		}
+		changes = append(changes, d.changes...)
+	}
+	return changes, nil
+}
+
+func resolvedDataMap(files []DataFile) (map[string]DataFile, error) {
+	m := make(map[string]DataFile, len(files))
+	for _, file := range files {
+		resolved, _, err := datafile.ResolveTemplates(file.Data)
+		if err != nil {
+			return nil, &DataError{Path: file.Path, Err: err}
+		}
+		file.Data = resolved
+		m[DataFileName(file.Package, file.Table)] = file
+	}
+	return m, nil
+}
+
+type dataDiffer struct {
+	typMap  map[string]Meta
+	base    DataChange
+	changes []DataChange
+}
+
+func (d *dataDiffer) add(kind CHANGE_KIND, ptr string, path []string, oldText, newText string) {
+	c := d.base
+	c.Kind, c.Pointer, c.Path, c.Old, c.New = kind, ptr, path, oldText, newText
+	d.changes = append(d.changes, c)
+}
+
+func (d *dataDiffer) diffValue(v StructVar, ptr string, path []string, oldVal, newVal any, hasOld, hasNew bool) {
+	switch {
+	case !hasOld && !hasNew:
+		return
+	case !hasOld:
+		d.add(ADDED, ptr, path, "", d.display(v, newVal))
+		return
+	case !hasNew:
+		d.add(REMOVED, ptr, path, d.display(v, oldVal), "")
+		return
+	case reflect.DeepEqual(oldVal, newVal):
+		return
+	}
+	oldMap, oldIsMap := oldVal.(map[string]any)
+	newMap, newIsMap := newVal.(map[string]any)
+	oldList, oldIsList := oldVal.([]any)
+	newList, newIsList := newVal.([]any)
+	switch v.Typ {
+	case "map":
+		if !oldIsMap || !newIsMap {
+			break
+		}
+		elem := StructVar{Name: v.Name, Typ: v.ValueType}
+		for _, k := range sortedMapKeys(v.KeyType, mergeKeys(oldMap, newMap)) {
+			oldSub, hasOldSub := oldMap[k]
+			newSub, hasNewSub := newMap[k]
+			d.diffValue(elem, ptr+"/"+EscapePointer(k), appendPath(path, k), oldSub, newSub, hasOldSub, hasNewSub)
+		}
+		return
+	case "list":
+		//结构体列表长度不变时逐个比较，否则整体比较
+		meta, ok := d.typMap[v.ValueType]
+		if !oldIsList || !newIsList || len(oldList) != len(newList) || !ok || meta.Typ != STRUCT {
+			break
+		}
+		elem := StructVar{Name: v.Name, Typ: v.ValueType}
+		for i := range newList {
+			d.diffValue(elem, ptr+"/"+strconv.Itoa(i), appendPath(path, "["+strconv.Itoa(i)+"]"), oldList[i], newList[i], true, true)
+		}
+		return
+	default:
+		meta, ok := d.typMap[v.Typ]
+		if !ok || meta.Typ != STRUCT || !oldIsMap || !newIsMap {
+			break
+		}
+		d.diffStruct(meta.Meta.(*Struct), ptr, path, oldMap, newMap)
+		return
+	}
+	d.add(VALUE_CHANGED, ptr, path, d.display(v, oldVal), d.display(v, newVal))
+}
+
+// diffStruct 按字段定义顺序比较，未定义的字段排在最后
+func (d *dataDiffer) diffStruct(st *Struct, ptr string, path []string, oldMap, newMap map[string]any) {
+	known := make(map[string]bool, len(st.Vars))
+	for _, v := range st.Vars {
+		known[v.Name] = true
+		oldSub, hasOld := oldMap[v.Name]
+		newSub, hasNew := newMap[v.Name]
+		d.diffValue(v, ptr+"/"+EscapePointer(v.Name), appendPath(path, aliasOr(v.Alias, v.Name)), oldSub, newSub, hasOld, hasNew)
+	}
+	for _, k := range SortedKeys(mergeKeys(oldMap, newMap)) {
+		if known[k] {
+			continue
+		}
+		oldSub, hasOld := oldMap[k]
+		newSub, hasNew := newMap[k]
+		d.diffValue(StructVar{Name: k}, ptr+"/"+EscapePointer(k), appendPath(path, k), oldSub, newSub, hasOld, hasNew)
+	}
+}
+
+// display 展示用的值，枚举显示为 别名(名字)，字符串加引号
+func (d *dataDiffer) display(v StructVar, val any) string {
+	if meta, ok := d.typMap[v.Typ]; ok && meta.Typ == ENUM {
+		if num, ok := toInt(val); ok {
+			for _, enumVar := range meta.Meta.(*Enum).Vars {
+				if enumVar.Default == strconv.Itoa(num) {
+					return fmt.Sprintf("%s(%s)", enumVar.Alias, enumVar.Name)
+				}
+			}
+		}
+	}
+	if str, ok := val.(string); ok {
+		return strconv.Quote(str)
+	}
+	elem := StructVar{Name: v.Name, Typ: v.ValueType}
+	switch {
+	case v.Typ == "list":
+		if list, ok := val.([]any); ok {
+			items := make([]string, len(list))
+			for i, item := range list {
+				items[i] = d.display(elem, item)
+			}
+			return "[" + strings.Join(items, ", ") + "]"
+		}
+	case v.Typ == "map":
+		if m, ok := val.(map[string]any); ok {
+			items := make([]string, 0, len(m))
+			for _, k := range sortedMapKeys(v.KeyType, m) {
+				items = append(items, k+": "+d.display(elem, m[k]))
+			}
+			return "{" + strings.Join(items, ", ") + "}"
+		}
+	}
+	return formatJsonCell(val)
+}
+
+func mergeKeys(a, b map[string]any) map[string]any {
+	keys := make(map[string]any, len(a)+len(b))
+	for k := range a {
+		keys[k] = nil
+	}
+	for k := range b {
+		keys[k] = nil
+	}
+	return keys
+}
+
+func appendPath(path []string, name string) []string {
+	return append(append([]string(nil), path...), name)
+}
+
+func aliasOr(alias, name string) string {
+	if alias == "" {
+		return name
+	}
+	return alias
+}
@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const diffTestXml = `<conf package="p">
+	<enum name="E"><var name="A" default="1" alias="甲"/><var name="B" default="2" alias="乙"/></enum>
+	<struct name="S" alias="结构"><var name="X" type="int" alias="数"/><var name="Y" type="string"/></struct>
+	<table name="T" alias="表">
+		<var name="Int" type="int" alias="整数"/>
+		<var name="Enum" type="E" alias="枚举"/>
+		<var name="List" type="list" valueType="int"/>
+		<var name="Structs" type="list" valueType="S"/>
+		<var name="Rows" type="map" valueType="S" alias="行"/>
+		<var name="IntMap" type="map" keyType="int" valueType="E"/>
+	</table>
+</conf>`
+
+func TestDiffData(t *testing.T) {
+	schema, err := LoadSchema(writeTestFiles(t, map[string]string{"p.xml": diffTestXml}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		table    string
+		old, new string //为空时没有这个文件
+		want     []string
+	}{
+		{name: "same", old: `{"Int":1,"Rows":{"r":{"X":1}}}`, new: `{"Rows":{"r":{"X":1}},"Int":1}`},
+		{name: "int", old: `{"Int":1}`, new: `{"Int":2}`, want: []string{"表 / 整数: 1 → 2"}},
+		{name: "field added", old: `{}`, new: `{"Int":2}`, want: []string{"表 / 整数: + 2"}},
+		{name: "field removed", old: `{"Enum":2}`, new: `{}`, want: []string{"表 / 枚举: - 乙(B)"}},
+		{name: "enum", old: `{"Enum":1}`, new: `{"Enum":2}`, want: []string{"表 / 枚举: 甲(A) → 乙(B)"}},
+		{name: "int list", old: `{"List":[1,2]}`, new: `{"List":[1]}`, want: []string{"表 / List: [1, 2] → [1]"}},
+		{
+			name: "struct list same length",
+			old:  `{"Structs":[{"X":1,"Y":"a"},{"X":2}]}`,
+			new:  `{"Structs":[{"X":3,"Y":"b"},{"X":2}]}`,
+			want: []string{"表 / Structs / [0] / 数: 1 → 3", `表 / Structs / [0] / Y: "a" → "b"`},
+		},
+		{
+			name: "row added and removed",
+			old:  `{"Rows":{"r1":{"X":1},"r2":{"X":2}}}`,
+			new:  `{"Rows":{"r1":{"X":1},"r3":{"X":3}}}`,
+			want: []string{`表 / 行 / r2: - {"X":2}`, `表 / 行 / r3: + {"X":3}`},
+		},
+		{
+			name: "inherited value",
+			old:  `{"Rows":{"r":{"$base":"D","Y":"r"}},"$templates":{"D":{"X":1}}}`,
+			new:  `{"Rows":{"r":{"$base":"D","Y":"r"}},"$templates":{"D":{"X":2}}}`,
+			want: []string{"表 / 行 / r / 数: 1 → 2"},
+		},
+		{name: "inheritance expanded", old: `{"Rows":{"r":{"X":1}}}`, new: `{"Rows":{"r":{"$base":"D"}},"$templates":{"D":{"X":1}}}`},
+		{
+			name: "int keys in order",
+			old:  `{"IntMap":{"9":1,"10":1}}`,
+			new:  `{"IntMap":{"9":2,"10":2}}`,
+			want: []string{"表 / IntMap / 9: 甲(A) → 乙(B)", "表 / IntMap / 10: 甲(A) → 乙(B)"},
+		},
+		{
+			name: "unknown field last",
+			old:  `{"Extra":1,"Int":1}`,
+			new:  `{"Extra":2,"Int":2}`,
+			want: []string{"表 / 整数: 1 → 2", "表 / Extra: 1 → 2"},
+		},
+		{name: "type changed", old: `{"Rows":{"r":1}}`, new: `{"Rows":{"r":{"X":1}}}`, want: []string{`表 / 行 / r: 1 → {"X":1}`}},
+		{name: "table added", new: `{"Int":1}`, want: []string{"表: added"}},
+		{name: "table removed", old: `{"Int":1}`, want: []string{"表: removed"}},
+		{name: "unknown table", table: "U", old: `{"a":1}`, new: `{"a":2}`, want: []string{"U / a: 1 → 2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := tt.table
+			if table == "" {
+				table = "T"
+			}
+			changes, err := DiffData(schema, diffTestFiles(t, table, tt.old), diffTestFiles(t, table, tt.new))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, c := range changes {
+				got = append(got, c.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffDataPointer(t *testing.T) {
+	schema, err := LoadSchema(writeTestFiles(t, map[string]string{"p.xml": diffTestXml}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, err := DiffData(schema, diffTestFiles(t, "T", `{"Rows":{"a/b~":{"X":1}}}`), diffTestFiles(t, "T", `{"Rows":{"a/b~":{"X":2}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DataChange{{
+		Kind: VALUE_CHANGED, Package: "p", Table: "T", TableAlias: "表",
+		Pointer: "/Rows/a~1b~0/X", Path: []string{"行", "a/b~", "数"}, Old: "1", New: "2",
+	}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("got %+v, want %+v", changes, want)
+	}
+}
+
+func diffTestFiles(t *testing.T, table, data string) []DataFile {
+	t.Helper()
+	if data == "" {
+		return nil
+	}
+	file := DataFile{Package: "p", Table: table, Path: DataFileName("p", table)}
+	if err := json.Unmarshal([]byte(data), &file.Data); err != nil {
+		t.Fatal(err)
+	}
+	return []DataFile{file}
+}
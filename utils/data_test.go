@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMergeOverlays(t *testing.T) {
+	root := writeTestFiles(t, map[string]string{
+		"qa/p/T.json":    `{"N":2,"M":{"a":null,"c":3}}`,
+		"dev/p/T.json":   `{"S":"dev"}`,
+		"dev/p/U.json":   `{"N":9}`,
+		"bad/p/T.json":   `{"N":`,
+		"empty/.keep":    "",
+		"other/q/T.json": `{"N":5}`,
+	})
+	base := map[string]any{"N": 1.0, "S": "base", "M": map[string]any{"a": 1.0, "b": 2.0}}
+	dirs := func(names ...string) []string {
+		var paths []string
+		for _, name := range names {
+			paths = append(paths, filepath.Join(root, name))
+		}
+		return paths
+	}
+
+	merged, contents, err := MergeOverlays(base, "p", "T", dirs("qa", "empty", "other", "dev"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"N": 2.0, "S": "dev", "M": map[string]any{"b": 2.0, "c": 3.0}}
+	if !reflect.DeepEqual(merged, want) {
+		t.Errorf("merged %v, want %v", merged, want)
+	}
+	//只返回存在的overlay文件
+	if len(contents) != 2 || string(contents[1]) != `{"S":"dev"}` {
+		t.Errorf("contents %q", contents)
+	}
+	if base["N"] != 1.0 || len(base["M"].(map[string]any)) != 2 {
+		t.Errorf("base modified: %v", base)
+	}
+
+	merged, contents, err = MergeOverlays(base, "p", "T", nil)
+	if err != nil || !reflect.DeepEqual(merged, base) || contents != nil {
+		t.Errorf("no overlay: %v %v %v", merged, contents, err)
+	}
+	if _, _, err := MergeOverlays(base, "p", "T", dirs("qa", "bad")); err == nil {
+		t.Error("invalid overlay: want error")
+	}
+}
@@ -0,0 +1,297 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mogebingxue/game_config_manager/datafile"
+)
+
+// 数据文件的标准格式：4空格缩进，结构体字段按元数据顺序，$base在最前，未定义的字段排在最后，
+// map的key排序(int类型的key按数值排序)，枚举写成数字，文件以换行结尾
+
+// FormatData 按表定义输出标准格式的json
+func FormatData(typMap map[string]Meta, table *Struct, data map[string]any) []byte {
+	f := &dataFormatter{typMap: typMap, templateTypes: templateTypes(typMap, table, data)}
+	f.writeStruct(table, data, 0, true)
+	f.buf.WriteString("\n")
+	return f.buf.Bytes()
+}
+
+// FormatDataDir 检查数据目录下的文件是否为标准格式，返回不是标准格式的文件，write为true时改写这些文件
+func FormatDataDir(schema *Schema, dir string, write bool) ([]string, error) {
+	files, err := LoadDataDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var changed []string
+	for _, file := range files {
+		meta, ok := schema.LookupType(file.Package, file.Table)
+		if !ok || meta.Typ != TABLE {
+			return changed, &DataError{Path: file.Path, Err: fmt.Errorf("table %s.%s not found", file.Package, file.Table)}
+		}
+		content, err := os.ReadFile(file.Path)
+		if err != nil {
+			return changed, err
+		}
+		formatted := FormatData(schema.TypeMap(file.Package), meta.Meta.(*Struct), file.Data)
+		if bytes.Equal(content, formatted) {
+			continue
+		}
+		changed = append(changed, file.Path)
+		if write {
+			if err := os.WriteFile(file.Path, formatted, 0644); err != nil {
+				return changed, err
+			}
+		}
+	}
+	return changed, nil
+}
+
+type dataFormatter struct {
+	typMap        map[string]Meta
+	templateTypes map[string]*Struct //key：模板名
+	buf           bytes.Buffer
+}
+
+// templateTypes 按继承模板的行推断模板的类型，模板按该类型的字段顺序输出
+func templateTypes(typMap map[string]Meta, table *Struct, data map[string]any) map[string]*Struct {
+	templates, _ := data[datafile.TemplatesKey].(map[string]any)
+	types := make(map[string]*Struct)
+	var addType func(name string, st *Struct)
+	addType = func(name string, st *Struct) {
+		tmpl, ok := templates[name].(map[string]any)
+		if !ok || types[name] != nil {
+			return
+		}
+		types[name] = st
+		//模板继承的模板类型相同
+		if base, ok := tmpl[datafile.BaseKey].(string); ok {
+			addType(base, st)
+		}
+	}
+	if base, ok := data[datafile.BaseKey].(string); ok {
+		addType(base, table)
+	}
+	WalkTable(typMap, table, data, func(ptr string, typ string, val any) {
+		m, ok := val.(map[string]any)
+		meta, isType := typMap[typ]
+		if !ok || !isType || meta.Typ != STRUCT {
+			return
+		}
+		if base, ok := m[datafile.BaseKey].(string); ok {
+			addType(base, meta.Meta.(*Struct))
+		}
+	})
+	return types
+}
+
+func (f *dataFormatter) newLine(indent int) {
+	f.buf.WriteString("\n")
+	f.buf.WriteString(strings.Repeat("    ", indent))
+}
+
+func (f *dataFormatter) writeKey(key string, indent int, first bool) {
+	if !first {
+		f.buf.WriteString(",")
+	}
+	f.newLine(indent)
+	f.writeScalar(key)
+	f.buf.WriteString(": ")
+}
+
+// writeStruct root为true时$templates排在最后
+func (f *dataFormatter) writeStruct(st *Struct, data map[string]any, indent int, root bool) {
+	if len(data) == 0 {
+		f.buf.WriteString("{}")
+		return
+	}
+	f.buf.WriteString("{")
+	written := make(map[string]bool, len(data))
+	first := true
+	write := func(key string, v StructVar, known bool) {
+		f.writeKey(key, indent+1, first)
+		first = false
+		written[key] = true
+		if known {
+			f.writeValue(v, data[key], indent+1)
+		} else {
+			f.writeAny(data[key], indent+1)
+		}
+	}
+	if _, ok := data[datafile.BaseKey]; ok {
+		write(datafile.BaseKey, StructVar{}, false)
+	}
+	for _, v := range st.Vars {
+		if _, ok := data[v.Name]; ok {
+			write(v.Name, v, true)
+		}
+	}
+	for _, k := range SortedKeys(data) {
+		if !written[k] && !(root && k == datafile.TemplatesKey) {
+			write(k, StructVar{}, false)
+		}
+	}
+	if templates, ok := data[datafile.TemplatesKey]; ok && root {
+		f.writeKey(datafile.TemplatesKey, indent+1, first)
+		f.writeTemplates(templates, indent+1)
+	}
+	f.newLine(indent)
+	f.buf.WriteString("}")
+}
+
+func (f *dataFormatter) writeTemplates(val any, indent int) {
+	templates, ok := val.(map[string]any)
+	if !ok || len(templates) == 0 {
+		f.writeAny(val, indent)
+		return
+	}
+	f.buf.WriteString("{")
+	for i, k := range SortedKeys(templates) {
+		f.writeKey(k, indent+1, i == 0)
+		tmpl, isMap := templates[k].(map[string]any)
+		if st := f.templateTypes[k]; st != nil && isMap {
+			f.writeStruct(st, tmpl, indent+1, false)
+		} else {
+			f.writeAny(templates[k], indent+1)
+		}
+	}
+	f.newLine(indent)
+	f.buf.WriteString("}")
+}
+
+func (f *dataFormatter) writeValue(v StructVar, val any, indent int) {
+	switch v.Typ {
+	case "int", "string", "bool":
+		f.writeAny(val, indent)
+	case "list":
+		list, ok := val.([]any)
+		if !ok || len(list) == 0 {
+			f.writeAny(val, indent)
+			return
+		}
+		elem := StructVar{Name: v.Name, Typ: v.ValueType}
+		f.buf.WriteString("[")
+		for i, item := range list {
+			if i > 0 {
+				f.buf.WriteString(",")
+			}
+			f.newLine(indent + 1)
+			f.writeValue(elem, item, indent+1)
+		}
+		f.newLine(indent)
+		f.buf.WriteString("]")
+	case "map":
+		m, ok := val.(map[string]any)
+		if !ok || len(m) == 0 {
+			f.writeAny(val, indent)
+			return
+		}
+		elem := StructVar{Name: v.Name, Typ: v.ValueType}
+		f.buf.WriteString("{")
+		for i, k := range sortedMapKeys(v.KeyType, m) {
+			f.writeKey(k, indent+1, i == 0)
+			f.writeValue(elem, m[k], indent+1)
+		}
+		f.newLine(indent)
+		f.buf.WriteString("}")
+	default:
+		meta, ok := f.typMap[v.Typ]
+		switch {
+		case ok && meta.Typ == ENUM:
+			//名字或别名转换为数字
+			if str, isStr := val.(string); isStr {
+				if num, err := parseEnumCell(meta.Meta.(*Enum), str); err == nil {
+					val = num
+				}
+			}
+			f.writeAny(val, indent)
+		case ok && meta.Typ == STRUCT:
+			m, isMap := val.(map[string]any)
+			if !isMap {
+				f.writeAny(val, indent)
+				return
+			}
+			f.writeStruct(meta.Meta.(*Struct), m, indent, false)
+		default:
+			f.writeAny(val, indent)
+		}
+	}
+}
+
+// writeAny 没有类型信息的值，对象的key排序
+func (f *dataFormatter) writeAny(val any, indent int) {
+	switch v := val.(type) {
+	case map[string]any:
+		if len(v) == 0 {
+			f.buf.WriteString("{}")
+			return
+		}
+		f.buf.WriteString("{")
+		for i, k := range SortedKeys(v) {
+			f.writeKey(k, indent+1, i == 0)
+			f.writeAny(v[k], indent+1)
+		}
+		f.newLine(indent)
+		f.buf.WriteString("}")
+	case []any:
+		if len(v) == 0 {
+			f.buf.WriteString("[]")
+			return
+		}
+		f.buf.WriteString("[")
+		for i, item := range v {
+			if i > 0 {
+				f.buf.WriteString(",")
+			}
+			f.newLine(indent + 1)
+			f.writeAny(item, indent+1)
+		}
+		f.newLine(indent)
+		f.buf.WriteString("]")
+	default:
+		f.writeScalar(val)
+	}
+}
+
+func (f *dataFormatter) writeScalar(val any) {
+	if num, ok := toInt(val); ok {
+		f.buf.WriteString(strconv.Itoa(num))
+		return
+	}
+	//超出int范围的数不用科学计数法，也不截断
+	if num, ok := val.(float64); ok {
+		f.buf.WriteString(strconv.FormatFloat(num, 'f', -1, 64))
+		return
+	}
+	//不转义<>&，中文原样输出
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(val); err != nil {
+		f.buf.WriteString("null")
+		return
+	}
+	f.buf.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+}
+
+// sortedMapKeys int类型的key按数值排序
+func sortedMapKeys(keyType string, m map[string]any) []string {
+	keys := SortedKeys(m)
+	if keyType == "int" {
+		sort.SliceStable(keys, func(i, j int) bool {
+			a, errA := strconv.Atoi(keys[i])
+			b, errB := strconv.Atoi(keys[j])
+			if errA != nil || errB != nil {
+				return errA == nil && errB != nil
+			}
+			return a < b
+		})
+	}
+	return keys
+}
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const formatTestXml = `<conf package="p">
+	<enum name="E"><var name="A" default="1" alias="甲"/><var name="B" default="2"/></enum>
+	<struct name="S"><var name="X" type="int"/><var name="Y" type="string"/></struct>
+	<table name="T">
+		<var name="Bool" type="bool"/>
+		<var name="Int" type="int"/>
+		<var name="Enum" type="E"/>
+		<var name="List" type="list" valueType="S"/>
+		<var name="IntMap" type="map" keyType="int" valueType="int"/>
+		<var name="Rows" type="map" valueType="S"/>
+	</table>
+</conf>`
+
+func TestFormatData(t *testing.T) {
+	conf, typMap := testTypMap(t, formatTestXml)
+	table := &conf.Tables[0]
+	tests := []struct {
+		name string
+		data string
+		want string //为空时只检查幂等
+	}{
+		{name: "empty", data: `{}`, want: "{}\n"},
+		{
+			name: "field order",
+			data: `{"Int":1,"Extra":{"b":1,"a":[]},"Bool":true}`,
+			want: "{\n    \"Bool\": true,\n    \"Int\": 1,\n    \"Extra\": {\n        \"a\": [],\n        \"b\": 1\n    }\n}\n",
+		},
+		{
+			name: "enum name",
+			data: `{"Enum":"B","List":[{"Y":"<&>中文","X":2}]}`,
+			want: "{\n    \"Enum\": 2,\n    \"List\": [\n        {\n            \"X\": 2,\n            \"Y\": \"<&>中文\"\n        }\n    ]\n}\n",
+		},
+		{
+			name: "enum alias",
+			data: `{"Enum":"甲"}`,
+			want: "{\n    \"Enum\": 1\n}\n",
+		},
+		{
+			name: "int keys",
+			data: `{"IntMap":{"10":1,"9":2,"x":3,"-1":4}}`,
+			want: "{\n    \"IntMap\": {\n        \"-1\": 4,\n        \"9\": 2,\n        \"10\": 1,\n        \"x\": 3\n    }\n}\n",
+		},
+		{
+			name: "templates",
+			data: `{"$templates":{"D":{"Y":"d","X":1}},"Rows":{"r":{"Y":null,"$base":"D"}},"$base":"D"}`,
+			want: "{\n    \"$base\": \"D\",\n    \"Rows\": {\n        \"r\": {\n            \"$base\": \"D\",\n            \"Y\": null\n        }\n    },\n    \"$templates\": {\n        \"D\": {\n            \"X\": 1,\n            \"Y\": \"d\"\n        }\n    }\n}\n",
+		},
+		{name: "wrong types", data: `{"Int":"x","List":{"a":1},"Rows":[1],"Bool":null}`},
+		{name: "large int", data: `{"Int":9007199254740993,"IntMap":{"1":-0}}`},
+		{name: "float", data: `{"Int":1.5}`},
+		{
+			name: "overlay",
+			data: `{"$replace":true,"IntMap":{"2":null,"1":3},"Int":null}`,
+			want: "{\n    \"Int\": null,\n    \"IntMap\": {\n        \"1\": 3,\n        \"2\": null\n    },\n    \"$replace\": true\n}\n",
+		},
+		{
+			name: "beyond int range",
+			data: `{"Int":1e20,"Extra":[1e21,-9223372036854775808,9223372036854775808,2.5e-7]}`,
+			want: "{\n    \"Int\": 100000000000000000000,\n    \"Extra\": [\n        1000000000000000000000,\n        -9223372036854775808,\n        9223372036854776000,\n        0.00000025\n    ]\n}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var data map[string]any
+			if err := json.Unmarshal([]byte(tt.data), &data); err != nil {
+				t.Fatal(err)
+			}
+			got := FormatData(typMap, table, data)
+			if tt.want != "" && string(got) != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+			//格式化的结果再格式化不变
+			var again map[string]any
+			if err := json.Unmarshal(got, &again); err != nil {
+				t.Fatalf("invalid json %v:\n%s", err, got)
+			}
+			if twice := FormatData(typMap, table, again); string(twice) != string(got) {
+				t.Errorf("not idempotent\n%s\nthen\n%s", got, twice)
+			}
+		})
+	}
+}
+
+func TestFormatDataDirExample(t *testing.T) {
+	schema, err := LoadSchema("../example/metadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"../example/data", "../example/overlay/qa"} {
+		changed, err := FormatDataDir(schema, dir, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(changed) > 0 {
+			t.Errorf("not formatted: %v", changed)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// ConflictKey 三方合并冲突时，在冲突的位置写入 {"$conflict": {"base": ..., "ours": ..., "theirs": ...}}，
+// 数据不能通过校验，需要手动选择其中一个值
+const ConflictKey = "$conflict"
+
+// MergeConflict 双方修改了同一个字段并且修改不同
+type MergeConflict struct {
+	Pointer string
+	Base    any
+	Ours    any
+	Theirs  any
+}
+
+func (c MergeConflict) String() string {
+	return fmt.Sprintf("%s: ours %s, theirs %s, base %s", c.Pointer, formatJsonCell(c.Ours), formatJsonCell(c.Theirs), formatJsonCell(c.Base))
+}
+
+// MergeData 按表定义三方合并数据，结构体按字段、map按key合并，只有双方修改了同一个字段时冲突
+func MergeData(typMap map[string]Meta, table *Struct, base, ours, theirs map[string]any) (map[string]any, []MergeConflict) {
+	m := &merger{typMap: typMap}
+	result := m.mergeObject(table, nil, "", base, ours, theirs)
+	return result, m.conflicts
+}
+
+type merger struct {
+	typMap    map[string]Meta
+	conflicts []MergeConflict
+}
+
+// mergeValue has为false表示这一方没有该字段
+type mergeValue struct {
+	val any
+	has bool
+}
+
+func (a mergeValue) equal(b mergeValue) bool {
+	return a.has == b.has && (!a.has || reflect.DeepEqual(a.val, b.val))
+}
+
+func (m *merger) merge(v StructVar, ptr string, base, ours, theirs mergeValue) mergeValue {
+	switch {
+	case ours.equal(theirs), base.equal(theirs):
+		return ours
+	case base.equal(ours):
+		return theirs
+	}
+	//双方都修改了，都是对象时逐个key合并
+	baseMap, baseIsMap := base.val.(map[string]any)
+	oursMap, oursIsMap := ours.val.(map[string]any)
+	theirsMap, theirsIsMap := theirs.val.(map[string]any)
+	if oursIsMap && theirsIsMap && (baseIsMap || !base.has) {
+		var st *Struct
+		var elem *StructVar
+		if v.Typ == "map" {
+			elem = &StructVar{Name: v.Name, Typ: v.ValueType}
+		} else if meta, ok := m.typMap[v.Typ]; ok && meta.Typ == STRUCT {
+			st = meta.Meta.(*Struct)
+		}
+		return mergeValue{val: m.mergeObject(st, elem, ptr, baseMap, oursMap, theirsMap), has: true}
+	}
+	m.conflicts = append(m.conflicts, MergeConflict{Pointer: ptr, Base: base.val, Ours: ours.val, Theirs: theirs.val})
+	return mergeValue{val: map[string]any{ConflictKey: map[string]any{"base": base.val, "ours": ours.val, "theirs": theirs.val}}, has: true}
+}
+
+// mergeObject st不为空时按结构体字段合并，elem不为空时为map的元素类型，都为空时没有类型信息
+func (m *merger) mergeObject(st *Struct, elem *StructVar, ptr string, base, ours, theirs map[string]any) map[string]any {
+	keys := mergeKeys(base, mergeKeys(ours, theirs))
+	result := make(map[string]any, len(keys))
+	for _, k := range SortedKeys(keys) {
+		v := StructVar{Name: k}
+		if elem != nil {
+			v = *elem
+		} else if st != nil {
+			if structVar, ok := findVar(st, k); ok {
+				v = structVar
+			}
+		}
+		baseVal, hasBase := base[k]
+		oursVal, hasOurs := ours[k]
+		theirsVal, hasTheirs := theirs[k]
+		merged := m.merge(v, ptr+"/"+EscapePointer(k),
+			mergeValue{baseVal, hasBase}, mergeValue{oursVal, hasOurs}, mergeValue{theirsVal, hasTheirs})
+		if merged.has {
+			result[k] = merged.val
+		}
+	}
+	return result
+}
+
+// isConflict 数据中没有解决的合并冲突
+func isConflict(val any) bool {
+	m, ok := val.(map[string]any)
+	if !ok || len(m) != 1 {
+		return false
+	}
+	_, ok = m[ConflictKey]
+	return ok
+}
@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const mergeTestXml = `<conf package="p">
+	<struct name="S"><var name="X" type="int"/><var name="Y" type="string"/></struct>
+	<table name="T">
+		<var name="Int" type="int"/>
+		<var name="List" type="list" valueType="int"/>
+		<var name="Struct" type="S"/>
+		<var name="Rows" type="map" valueType="S"/>
+		<var name="Names" type="map" valueType="string"/>
+	</table>
+</conf>`
+
+func TestMergeData(t *testing.T) {
+	conf, typMap := testTypMap(t, mergeTestXml)
+	table := &conf.Tables[0]
+	tests := []struct {
+		name               string
+		base, ours, theirs string
+		want               string
+		conflicts          []string //冲突的json pointer
+	}{
+		{name: "unchanged", base: `{"Int":1}`, ours: `{"Int":1}`, theirs: `{"Int":1}`, want: `{"Int":1}`},
+		{name: "ours only", base: `{"Int":1}`, ours: `{"Int":2}`, theirs: `{"Int":1}`, want: `{"Int":2}`},
+		{name: "theirs only", base: `{"Int":1}`, ours: `{"Int":1}`, theirs: `{"Int":3}`, want: `{"Int":3}`},
+		{name: "same change", base: `{"Int":1}`, ours: `{"Int":2}`, theirs: `{"Int":2}`, want: `{"Int":2}`},
+		{
+			name: "different fields",
+			base: `{"Int":1,"Struct":{"X":1,"Y":"a"}}`,
+			ours: `{"Int":2,"Struct":{"X":1,"Y":"a"}}`, theirs: `{"Int":1,"Struct":{"X":1,"Y":"b"}}`,
+			want: `{"Int":2,"Struct":{"X":1,"Y":"b"}}`,
+		},
+		{
+			name: "same struct different fields",
+			base: `{"Struct":{"X":1,"Y":"a"}}`,
+			ours: `{"Struct":{"X":2,"Y":"a"}}`, theirs: `{"Struct":{"X":1,"Y":"b"}}`,
+			want: `{"Struct":{"X":2,"Y":"b"}}`,
+		},
+		{
+			name: "rows added on both sides",
+			base: `{"Rows":{"r1":{"X":1}}}`,
+			ours: `{"Rows":{"r1":{"X":1},"r2":{"X":2}}}`, theirs: `{"Rows":{"r1":{"X":1},"r3":{"X":3}}}`,
+			want: `{"Rows":{"r1":{"X":1},"r2":{"X":2},"r3":{"X":3}}}`,
+		},
+		{
+			name: "row deleted and other row changed",
+			base: `{"Rows":{"r1":{"X":1},"r2":{"X":2}}}`,
+			ours: `{"Rows":{"r2":{"X":2}}}`, theirs: `{"Rows":{"r1":{"X":1},"r2":{"X":3}}}`,
+			want: `{"Rows":{"r2":{"X":3}}}`,
+		},
+		{
+			name: "field added without base",
+			base: `{}`,
+			ours: `{"Names":{"a":"x"}}`, theirs: `{"Names":{"b":"y"}}`,
+			want: `{"Names":{"a":"x","b":"y"}}`,
+		},
+		{
+			name: "conflict scalar",
+			base: `{"Int":1}`, ours: `{"Int":2}`, theirs: `{"Int":3}`,
+			want:      `{"Int":{"$conflict":{"base":1,"ours":2,"theirs":3}}}`,
+			conflicts: []string{"/Int"},
+		},
+		{
+			name: "conflict list",
+			base: `{"List":[1]}`, ours: `{"List":[1,2]}`, theirs: `{"List":[3]}`,
+			want:      `{"List":{"$conflict":{"base":[1],"ours":[1,2],"theirs":[3]}}}`,
+			conflicts: []string{"/List"},
+		},
+		{
+			name: "conflict deleted and changed",
+			base: `{"Rows":{"r1":{"X":1}}}`, ours: `{"Rows":{}}`, theirs: `{"Rows":{"r1":{"X":2}}}`,
+			want:      `{"Rows":{"r1":{"$conflict":{"base":{"X":1},"ours":null,"theirs":{"X":2}}}}}`,
+			conflicts: []string{"/Rows/r1"},
+		},
+		{
+			name: "conflict nested field",
+			base: `{"Rows":{"a/b":{"X":1,"Y":"a"}}}`,
+			ours: `{"Rows":{"a/b":{"X":2,"Y":"b"}}}`, theirs: `{"Rows":{"a/b":{"X":3,"Y":"a"}}}`,
+			want:      `{"Rows":{"a/b":{"X":{"$conflict":{"base":1,"ours":2,"theirs":3}},"Y":"b"}}}`,
+			conflicts: []string{"/Rows/a~1b/X"},
+		},
+		{
+			name: "conflict both added",
+			base: `{}`, ours: `{"Int":1}`, theirs: `{"Int":2}`,
+			want:      `{"Int":{"$conflict":{"base":null,"ours":1,"theirs":2}}}`,
+			conflicts: []string{"/Int"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, conflicts := MergeData(typMap, table, mergeTestData(t, tt.base), mergeTestData(t, tt.ours), mergeTestData(t, tt.theirs))
+			if want := mergeTestData(t, tt.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+			var pointers []string
+			for _, c := range conflicts {
+				pointers = append(pointers, c.Pointer)
+			}
+			if !reflect.DeepEqual(pointers, tt.conflicts) {
+				t.Errorf("conflicts %v, want %v", pointers, tt.conflicts)
+			}
+			//没有解决的冲突不能通过校验
+			errs := ValidateData(typMap, table, "p/T.json", got)
+			for _, ptr := range tt.conflicts {
+				found := false
+				for _, err := range errs {
+					found = found || strings.Contains(err.Error(), "#"+ptr+":")
+				}
+				if !found {
+					t.Errorf("conflict %s not reported: %v", ptr, errs)
+				}
+			}
+		})
+	}
+}
+
+func mergeTestData(t *testing.T, s string) map[string]any {
+	t.Helper()
+	var data map[string]any
+	if err := json.Unmarshal([]byte(s), &data); err != nil {
+		t.Fatalf("%s: %v", s, err)
+	}
+	return data
+}
@@ -4,119 +4,142 @@ import (
 	"encoding/xml"
 	"errors"
 	"fmt"
-	"io"
-	"log/slog"
 	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
-	"sync"
 )
 
-func LoadAllConfigs(path string) error {
-	err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
-		//只读取后缀为xml的
-		if !strings.HasSuffix(path, ".xml") {
-			return nil
-		}
-		// 忽略目录
-		if info == nil {
-			return err
-		}
-		if info.IsDir() {
-			return nil
-		}
-		waitLoadConfMap.Add(1)
-		go func() {
-			conf, typMap := LoadMetadata(path)
-			slog.Debug("LoadMetadata", conf)
-			confMapMutex.Lock()
-			confMap[conf.Package] = conf
-			AllTypMap[conf.Package] = typMap
-			confMapMutex.Unlock()
-			waitLoadConfMap.Done()
-		}()
-		return nil
-	})
+func LoadMetadata(path string) (*Conf, map[string]Meta, error) {
+	conf, err := ParseMetadata(path)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	return nil
-}
-
-func WaitLoadConfMap() {
-	waitLoadConfMap.Wait()
-}
-
-var waitLoadConfMap sync.WaitGroup
-var confMapMutex sync.RWMutex
-var confMap = make(map[string]*Conf)
-
-func GetConfMap() map[string]*Conf {
-	confMapMutex.RLock()
-	defer confMapMutex.RUnlock()
-	return confMap
-}
-
-var AllTypMap = make(map[string]map[string]Meta)
-
-func LoadMetadata(path string) (*Conf, map[string]Meta) {
-	xmlFile, err := os.Open(path)
+	err, typMap := CheckConfValid(conf)
 	if err != nil {
-		slog.Error("Error opening input file:", err)
-		os.Exit(1)
+		return nil, nil, err
 	}
-	defer xmlFile.Close()
-	content, err := io.ReadAll(xmlFile)
+	return conf, typMap, nil
+}
+
+// ParseMetadata 只解析xml，不做类型校验，同一个包拆成多个文件时类型可能定义在其他文件里
+func ParseMetadata(path string) (*Conf, error) {
+	content, err := os.ReadFile(path)
 	if err != nil {
-		slog.Error("Error reading input file:", err)
-		os.Exit(1)
+		return nil, err
 	}
 	conf := &Conf{}
 	err = xml.Unmarshal(content, conf)
 	if err != nil {
-		fmt.Println("Error:", err)
-		os.Exit(1)
+		return nil, err
 	}
-	err, typMap := CheckConfValid(conf)
-	if err != nil {
-		fmt.Println("Error:", err)
-		os.Exit(1)
+	conf.Files = []string{path}
+	return conf, nil
+}
+
+// MergeConfs 合并同一个包的多个文件，按传入顺序拼接，类型重名时报告两个文件
+func MergeConfs(confs []*Conf) (*Conf, error) {
+	if len(confs) == 0 {
+		return nil, errors.New("no conf to merge")
 	}
-	return conf, typMap
+	merged := &Conf{Package: confs[0].Package}
+	defined := make(map[string]string) //key：类型名；value：定义所在文件
+	define := func(name, file string) error {
+		if other, ok := defined[name]; ok {
+			return fmt.Errorf("duplicate type name %s in %s and %s", name, other, file)
+		}
+		defined[name] = file
+		return nil
+	}
+	for _, conf := range confs {
+		file := strings.Join(conf.Files, ",")
+		if conf.Package != merged.Package {
+			return nil, fmt.Errorf("package mismatch %s and %s in %s", merged.Package, conf.Package, file)
+		}
+		if conf.Alias != "" {
+			if merged.Alias != "" && merged.Alias != conf.Alias {
+				return nil, fmt.Errorf("package %s alias mismatch %s and %s in %s", conf.Package, merged.Alias, conf.Alias, file)
+			}
+			merged.Alias = conf.Alias
+		}
+		for _, v := range conf.Consts {
+			if err := define(v.Name, file); err != nil {
+				return nil, err
+			}
+		}
+		for _, v := range conf.Enums {
+			if err := define(v.Name, file); err != nil {
+				return nil, err
+			}
+		}
+		for _, v := range conf.Structs {
+			if err := define(v.Name, file); err != nil {
+				return nil, err
+			}
+		}
+		for _, v := range conf.Tables {
+			if err := define(v.Name, file); err != nil {
+				return nil, err
+			}
+		}
+		merged.Consts = append(merged.Consts, conf.Consts...)
+		merged.Enums = append(merged.Enums, conf.Enums...)
+		merged.Structs = append(merged.Structs, conf.Structs...)
+		merged.Tables = append(merged.Tables, conf.Tables...)
+		merged.Files = append(merged.Files, conf.Files...)
+	}
+	return merged, nil
 }
 
 type Conf struct {
 	Package string   `xml:"package,attr"`
 	Alias   string   `xml:"alias,attr"`
+	Consts  []Const  `xml:"const"`
 	Enums   []Enum   `xml:"enum"`
 	Structs []Struct `xml:"struct"`
 	Tables  []Struct `xml:"table"`
+	Files   []string `xml:"-"` //定义所在的xml文件
+}
+
+// Const 命名常量，type支持int、string、bool
+type Const struct {
+	Name  string `xml:"name,attr"`
+	Typ   string `xml:"type,attr"`
+	Value string `xml:"value,attr"`
+	Alias string `xml:"alias,attr"`
 }
 
 type Table Struct
 
 type Enum struct {
-	Name  string    `xml:"name,attr"`
-	Alias string    `xml:"alias,attr"`
-	Vars  []EnumVar `xml:"var"`
+	Name        string      `xml:"name,attr"`
+	Alias       string      `xml:"alias,attr"`
+	Vars        []EnumVar   `xml:"var"`
+	Annotations Annotations `xml:",any,attr"`
 }
 
 type EnumVar struct {
-	Name    string `xml:"name,attr"`
-	Default string `xml:"default,attr"`
-	Alias   string `xml:"alias,attr"`
+	Name        string      `xml:"name,attr"`
+	Default     string      `xml:"default,attr"`
+	Alias       string      `xml:"alias,attr"`
+	Annotations Annotations `xml:",any,attr"`
 }
 type Struct struct {
-	Name  string      `xml:"name,attr"`
-	Alias string      `xml:"alias,attr"`
-	Vars  []StructVar `xml:"var"`
+	Name        string      `xml:"name,attr"`
+	Alias       string      `xml:"alias,attr"`
+	Vars        []StructVar `xml:"var"`
+	Annotations Annotations `xml:",any,attr"`
 }
 
 type StructVar struct {
-	Name      string `xml:"name,attr"`
-	Typ       string `xml:"type,attr"`
-	ValueType string `xml:"valueType,attr"`
-	Alias     string `xml:"alias,attr"`
+	Name        string      `xml:"name,attr"`
+	Typ         string      `xml:"type,attr"`
+	ValueType   string      `xml:"valueType,attr"`
+	KeyType     string      `xml:"keyType,attr"` //map的key类型，支持string(默认)、int
+	Alias       string      `xml:"alias,attr"`
+	Required    bool        `xml:"required,attr"` //数据中必须填写
+	Min         string      `xml:"min,attr"`      //int的最小值，可以是数字或int常量名
+	Max         string      `xml:"max,attr"`      //int的最大值，可以是数字或int常量名
+	Annotations Annotations `xml:",any,attr"`
 }
 
 type Meta struct {
@@ -130,14 +153,35 @@ const (
 	ENUM   META_TYPE = 1
 	STRUCT META_TYPE = 2
 	TABLE  META_TYPE = 3
+	CONST  META_TYPE = 4
 )
 
 func CheckConfValid(conf *Conf) (error, map[string]Meta) {
 	typMap := make(map[string]Meta) //name isTable
+	for _, v := range conf.Consts {
+		if _, ok := typMap[v.Name]; ok {
+			return errors.New("duplicate const name" + v.Name), nil
+		}
+		if err := checkConstValue(v); err != nil {
+			return err, nil
+		}
+		typMap[v.Name] = Meta{
+			Typ:  CONST,
+			Meta: &v,
+		}
+	}
 	for _, v := range conf.Enums {
 		if _, ok := typMap[v.Name]; ok {
 			return errors.New("duplicate enum name" + v.Name), nil
 		}
+		if err := checkAnnotations(v.Name, v.Annotations); err != nil {
+			return err, nil
+		}
+		for _, enumVar := range v.Vars {
+			if err := checkAnnotations(v.Name+"."+enumVar.Name, enumVar.Annotations); err != nil {
+				return err, nil
+			}
+		}
 		typMap[v.Name] = Meta{
 			Typ:  ENUM,
 			Meta: &v,
@@ -174,7 +218,25 @@ func CheckConfValid(conf *Conf) (error, map[string]Meta) {
 
 func checkSubType(typMap map[string]Meta, structs []Struct) error {
 	for _, v := range structs {
+		if err := checkAnnotations(v.Name, v.Annotations); err != nil {
+			return err
+		}
 		for _, structVar := range v.Vars {
+			if err := checkAnnotations(v.Name+"."+structVar.Name, structVar.Annotations); err != nil {
+				return err
+			}
+			if err := checkBound(typMap, v.Name, structVar); err != nil {
+				return err
+			}
+			switch structVar.KeyType {
+			case "", "string":
+			case "int":
+				if structVar.Typ != "map" {
+					return errors.New("keyType only support map " + v.Name + "." + structVar.Name)
+				}
+			default:
+				return errors.New("keyType not support " + v.Name + "." + structVar.Name + " keyType:" + structVar.KeyType)
+			}
 			switch structVar.Typ {
 			case "int", "string", "bool":
 			case "list", "map":
@@ -190,6 +252,9 @@ func checkSubType(typMap map[string]Meta, structs []Struct) error {
 					if meta.Typ == TABLE {
 						return errors.New("type is table" + v.Name + "." + structVar.Name + " type:" + structVar.Typ)
 					}
+					if meta.Typ == CONST {
+						return errors.New("type is const " + v.Name + "." + structVar.Name + " type:" + structVar.Typ)
+					}
 				}
 			default:
 				meta, ok := typMap[structVar.Typ]
@@ -199,8 +264,88 @@ func checkSubType(typMap map[string]Meta, structs []Struct) error {
 				if meta.Typ == TABLE {
 					return errors.New("type is table " + v.Name + "." + structVar.Name)
 				}
+				if meta.Typ == CONST {
+					return errors.New("type is const " + v.Name + "." + structVar.Name)
+				}
 			}
 		}
 	}
 	return nil
 }
+
+func checkConstValue(c Const) error {
+	switch c.Typ {
+	case "int", "bool", "string":
+	default:
+		return errors.New("const type not support " + c.Name + " type:" + c.Typ)
+	}
+	if _, err := c.NormalizedValue(); err != nil {
+		return errors.New("const value invalid " + c.Name + " value:" + c.Value)
+	}
+	return nil
+}
+
+// NormalizedValue 生成代码时使用的值：int为解析后的十进制(例如 "0100" 为 "100"、"+5" 为 "5")，
+// bool为true或false(例如 "1"、"T" 为 "true")，string原样返回
+func (c Const) NormalizedValue() (string, error) {
+	switch c.Typ {
+	case "int":
+		n, err := strconv.Atoi(c.Value)
+		if err != nil {
+			return "", err
+		}
+		return strconv.Itoa(n), nil
+	case "bool":
+		b, err := strconv.ParseBool(c.Value)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatBool(b), nil
+	}
+	return c.Value, nil
+}
+
+// checkBound min、max只能用在int或元素为int的list、map上
+func checkBound(typMap map[string]Meta, structName string, structVar StructVar) error {
+	if structVar.Min == "" && structVar.Max == "" {
+		return nil
+	}
+	if structVar.Typ != "int" && !((structVar.Typ == "list" || structVar.Typ == "map") && structVar.ValueType == "int") {
+		return errors.New("min/max only support int " + structName + "." + structVar.Name)
+	}
+	minVal, hasMin, err := ResolveInt(typMap, structVar.Min)
+	if err != nil {
+		return fmt.Errorf("%s.%s min: %w", structName, structVar.Name, err)
+	}
+	maxVal, hasMax, err := ResolveInt(typMap, structVar.Max)
+	if err != nil {
+		return fmt.Errorf("%s.%s max: %w", structName, structVar.Name, err)
+	}
+	if hasMin && hasMax && minVal > maxVal {
+		return errors.New("min greater than max " + structName + "." + structVar.Name)
+	}
+	return nil
+}
+
+// ResolveInt 解析数字或int常量名，为空时返回false
+func ResolveInt(typMap map[string]Meta, s string) (int, bool, error) {
+	if s == "" {
+		return 0, false, nil
+	}
+	if num, err := strconv.Atoi(s); err == nil {
+		return num, true, nil
+	}
+	meta, ok := typMap[s]
+	if !ok || meta.Typ != CONST {
+		return 0, false, errors.New("const not found " + s)
+	}
+	c := meta.Meta.(*Const)
+	if c.Typ != "int" {
+		return 0, false, errors.New("const is not int " + s)
+	}
+	num, err := strconv.Atoi(c.Value)
+	if err != nil {
+		return 0, false, err
+	}
+	return num, true, nil
+}
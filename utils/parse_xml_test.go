@@ -0,0 +1,243 @@
+package utils
+
+import (
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestConstNormalizedValue(t *testing.T) {
+	tests := []struct {
+		typ, value string
+		want       string
+		wantErr    bool
+	}{
+		{"int", "100", "100", false},
+		{"int", "0100", "100", false},
+		{"int", "+5", "5", false},
+		{"int", "-3", "-3", false},
+		{"int", "0x10", "", true},
+		{"int", "1.5", "", true},
+		{"bool", "true", "true", false},
+		{"bool", "1", "true", false},
+		{"bool", "T", "true", false},
+		{"bool", "0", "false", false},
+		{"bool", "yes", "", true},
+		{"string", "0100", "0100", false},
+	}
+	for _, tt := range tests {
+		c := Const{Name: "C", Typ: tt.typ, Value: tt.value}
+		got, err := c.NormalizedValue()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s %q: err = %v, wantErr %v", tt.typ, tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s %q: got %q, want %q", tt.typ, tt.value, got, tt.want)
+		}
+		if err := checkConstValue(c); (err != nil) != tt.wantErr {
+			t.Errorf("checkConstValue %s %q: err = %v", tt.typ, tt.value, err)
+		}
+	}
+	if err := checkConstValue(Const{Name: "C", Typ: "float", Value: "1"}); err == nil {
+		t.Error("checkConstValue float: want error")
+	}
+}
+
+// testTypMap 解析测试用的元数据
+func testTypMap(t *testing.T, content string) (*Conf, map[string]Meta) {
+	t.Helper()
+	conf := &Conf{}
+	if err := xml.Unmarshal([]byte(content), conf); err != nil {
+		t.Fatal(err)
+	}
+	err, typMap := CheckConfValid(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conf, typMap
+}
+
+func checkXml(t *testing.T, content string) error {
+	t.Helper()
+	conf := &Conf{}
+	if err := xml.Unmarshal([]byte(content), conf); err != nil {
+		t.Fatal(err)
+	}
+	err, _ := CheckConfValid(conf)
+	return err
+}
+
+func TestAnnotations(t *testing.T) {
+	tests := []struct {
+		name    string
+		attr    string
+		wantErr bool
+	}{
+		{"namespaced", `editor:widget="multiline"`, false},
+		{"go tag", `go:tag="json:&quot;a&quot;"`, false},
+		{"plain", `index="true"`, false},
+		{"unknown", `color="red"`, false},
+		{"typo valuetype", `valuetype="int"`, true},
+		{"typo keytype", `keytype="int"`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkXml(t, `<conf package="p"><table name="T"><var name="A" type="int" `+tt.attr+`/></table></conf>`)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	//表、枚举和枚举值上的属性也检查
+	for _, content := range []string{
+		`<conf package="p"><table name="T" ALIAS="t"/></conf>`,
+		`<conf package="p"><enum name="E" Alias="e"/></conf>`,
+		`<conf package="p"><enum name="E"><var name="A" Default="1"/></enum></conf>`,
+	} {
+		if err := checkXml(t, content); err == nil {
+			t.Errorf("%s: want error", content)
+		}
+	}
+
+	//需求中的例子
+	conf, _ := testTypMap(t, `<conf package="p"><table name="T" index="true"><var name="A" type="int" editor:widget="color" go:tag="bson:&quot;a&quot;"/></table></conf>`)
+	table := conf.Tables[0]
+	if got := table.Annotations.Get("index"); got != "true" {
+		t.Errorf("index = %q", got)
+	}
+	if got := table.Vars[0].Annotations.Get("editor:widget"); got != "color" {
+		t.Errorf("editor:widget = %q", got)
+	}
+	if got := table.Vars[0].Annotations.Get("go:tag"); got != `bson:"a"` {
+		t.Errorf("go:tag = %q", got)
+	}
+}
+
+func TestAnnotationsNamespace(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"undeclared", `<conf package="p"><table name="T"><var name="A" type="int" editor:widget="slider"/></table></conf>`},
+		{"declared on conf", `<conf package="p" xmlns:editor="urn:editor"><table name="T"><var name="A" type="int" editor:widget="slider"/></table></conf>`},
+		{"declared on table", `<conf package="p"><table name="T" xmlns:editor="urn:editor"><var name="A" type="int" editor:widget="slider"/></table></conf>`},
+		{"declared on var", `<conf package="p"><table name="T"><var name="A" type="int" xmlns:editor="urn:editor" editor:widget="slider"/></table></conf>`},
+		{"other prefix", `<conf package="p" xmlns:editor="urn:other" xmlns:e="urn:editor"><table name="T"><var name="A" type="int" editor:widget="slider" e:widget="x"/></table></conf>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf, _ := testTypMap(t, tt.content)
+			annotations := conf.Tables[0].Vars[0].Annotations
+			if got := annotations.Get("editor:widget"); got != "slider" {
+				t.Errorf("editor:widget = %q, annotations %v", got, annotations.Map())
+			}
+			if _, ok := annotations.Lookup("urn:editor:widget"); ok {
+				t.Error("key uses namespace uri")
+			}
+		})
+	}
+}
+
+func TestMergeConfs(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		tables  []string //合并后表的顺序
+		wantErr []string //错误中包含的内容
+	}{
+		{
+			name: "merge by path order",
+			files: map[string]string{
+				"b.xml":     `<conf package="p"><table name="B"><var name="S" type="S"/></table></conf>`,
+				"a.xml":     `<conf package="p" alias="包"><table name="A"/><struct name="S"/></conf>`,
+				"sub/c.xml": `<conf package="p"><table name="C"/></conf>`,
+			},
+			tables: []string{"A", "B", "C"},
+		},
+		{
+			name: "duplicate table",
+			files: map[string]string{
+				"a.xml": `<conf package="p"><table name="T"/></conf>`,
+				"b.xml": `<conf package="p"><table name="T"/></conf>`,
+			},
+			wantErr: []string{"duplicate type name T", "a.xml", "b.xml"},
+		},
+		{
+			name: "duplicate across kinds",
+			files: map[string]string{
+				"a.xml": `<conf package="p"><enum name="T"/></conf>`,
+				"b.xml": `<conf package="p"><struct name="T"/></conf>`,
+			},
+			wantErr: []string{"duplicate type name T", "a.xml", "b.xml"},
+		},
+		{
+			name: "same name in other package",
+			files: map[string]string{
+				"a.xml": `<conf package="p"><table name="T"/></conf>`,
+				"b.xml": `<conf package="q"><table name="T"/></conf>`,
+			},
+			tables: []string{"T"},
+		},
+		{
+			name: "alias mismatch",
+			files: map[string]string{
+				"a.xml": `<conf package="p" alias="a"/>`,
+				"b.xml": `<conf package="p" alias="b"/>`,
+			},
+			wantErr: []string{"alias mismatch", "b.xml"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := LoadSchema(writeTestFiles(t, tt.files))
+			if tt.wantErr != nil {
+				if err == nil {
+					t.Fatal("want error")
+				}
+				for _, want := range tt.wantErr {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("error %q does not contain %q", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var tables []string
+			for _, table := range schema.Tables("p") {
+				tables = append(tables, table.Name)
+			}
+			if !slices.Equal(tables, tt.tables) {
+				t.Errorf("tables %v, want %v", tables, tt.tables)
+			}
+		})
+	}
+
+	//多次合并结果相同
+	files := map[string]string{}
+	for i := range 10 {
+		files[fmt.Sprintf("f%d.xml", i)] = fmt.Sprintf(`<conf package="p"><table name="T%d"/></conf>`, i)
+	}
+	dir := writeTestFiles(t, files)
+	var first []Struct
+	for range 5 {
+		schema, err := LoadSchema(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tables := schema.Tables("p")
+		if first == nil {
+			first = tables
+			continue
+		}
+		if !reflect.DeepEqual(tables, first) {
+			t.Fatal("merge order changed between loads")
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Schema 元数据注册表，保存所有包的定义，查询方法可以在多个goroutine中并发调用
+type Schema struct {
+	mu     sync.RWMutex
+	confs  map[string]*Conf           //key：包名
+	typMap map[string]map[string]Meta //key：包名；value：类型名->类型定义
+}
+
+// LoadSchema 读取目录下所有xml元数据并校验
+func LoadSchema(dir string) (*Schema, error) {
+	var paths []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if info == nil {
+			return err
+		}
+		//只读取后缀为xml的
+		if info.IsDir() || !strings.HasSuffix(path, ".xml") {
+			return nil
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	confs := make([]*Conf, len(paths))
+	errs := make([]error, len(paths))
+	var wg sync.WaitGroup
+	for i, path := range paths {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conf, err := ParseMetadata(path)
+			if err != nil {
+				err = fmt.Errorf("%s: %w", path, err)
+			}
+			confs[i], errs[i] = conf, err
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	//同一个包的文件按路径顺序合并，保证结果稳定
+	var pkgs []string
+	pkgConfs := make(map[string][]*Conf)
+	for _, conf := range confs {
+		if _, ok := pkgConfs[conf.Package]; !ok {
+			pkgs = append(pkgs, conf.Package)
+		}
+		pkgConfs[conf.Package] = append(pkgConfs[conf.Package], conf)
+	}
+	s := &Schema{
+		confs:  make(map[string]*Conf),
+		typMap: make(map[string]map[string]Meta),
+	}
+	errs = nil
+	for _, pkg := range pkgs {
+		conf, err := MergeConfs(pkgConfs[pkg])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		err, typMap := CheckConfValid(conf)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("package %s: %w", pkg, err))
+			continue
+		}
+		s.confs[pkg] = conf
+		s.typMap[pkg] = typMap
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Packages 返回所有包名，按字母排序
+func (s *Schema) Packages() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	pkgs := make([]string, 0, len(s.confs))
+	for pkg := range s.confs {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+	return pkgs
+}
+
+// Package 返回包的定义
+func (s *Schema) Package(pkg string) (*Conf, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	conf, ok := s.confs[pkg]
+	return conf, ok
+}
+
+// LookupType 查找包内的类型定义
+func (s *Schema) LookupType(pkg, name string) (Meta, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	meta, ok := s.typMap[pkg][name]
+	return meta, ok
+}
+
+// TypeMap 返回包内类型表的副本
+func (s *Schema) TypeMap(pkg string) map[string]Meta {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	typMap := make(map[string]Meta, len(s.typMap[pkg]))
+	for k, v := range s.typMap[pkg] {
+		typMap[k] = v
+	}
+	return typMap
+}
+
+// Tables 返回包内所有表，按定义顺序
+func (s *Schema) Tables(pkg string) []Struct {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	conf, ok := s.confs[pkg]
+	if !ok {
+		return nil
+	}
+	return append([]Struct(nil), conf.Tables...)
+}
+
+// Enums 返回包内所有枚举，按定义顺序
+func (s *Schema) Enums(pkg string) []Enum {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	conf, ok := s.confs[pkg]
+	if !ok {
+		return nil
+	}
+	return append([]Enum(nil), conf.Enums...)
+}
+
+// Consts 返回包内所有常量，按定义顺序
+func (s *Schema) Consts(pkg string) []Const {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	conf, ok := s.confs[pkg]
+	if !ok {
+		return nil
+	}
+	return append([]Const(nil), conf.Consts...)
+}
+
+// FieldsOf 返回结构或表的字段，按定义顺序
+func (s *Schema) FieldsOf(pkg, name string) ([]StructVar, bool) {
+	meta, ok := s.LookupType(pkg, name)
+	if !ok {
+		return nil, false
+	}
+	st, ok := meta.Meta.(*Struct)
+	if !ok {
+		return nil, false
+	}
+	return append([]StructVar(nil), st.Vars...), true
+}
+
+// ResolveQualified 解析 包名.类型名 形式的全名
+func (s *Schema) ResolveQualified(qualified string) (Meta, bool) {
+	pkg, name, ok := strings.Cut(qualified, ".")
+	if !ok {
+		return Meta{}, false
+	}
+	return s.LookupType(pkg, name)
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSchemaConcurrent(t *testing.T) {
+	schema, err := LoadSchema("../example/metadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(2)
+		//并发加载
+		go func() {
+			defer wg.Done()
+			other, err := LoadSchema("../example/metadata")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if _, ok := other.LookupType("testpkg", "TestTable"); !ok {
+				t.Error("TestTable not found")
+			}
+		}()
+		//并发查询同一个Schema，修改返回的副本不影响Schema
+		go func() {
+			defer wg.Done()
+			for _, pkg := range schema.Packages() {
+				typMap := schema.TypeMap(pkg)
+				for name := range typMap {
+					if _, ok := schema.LookupType(pkg, name); !ok {
+						t.Errorf("%s.%s not found", pkg, name)
+					}
+					if _, ok := schema.ResolveQualified(pkg + "." + name); !ok {
+						t.Errorf("%s.%s not resolved", pkg, name)
+					}
+					delete(typMap, name)
+				}
+				for _, table := range schema.Tables(pkg) {
+					fields, ok := schema.FieldsOf(pkg, table.Name)
+					if !ok || len(fields) != len(table.Vars) {
+						t.Errorf("%s.%s fields %d, want %d", pkg, table.Name, len(fields), len(table.Vars))
+					}
+					if len(fields) > 0 {
+						fields[0].Name = "changed"
+					}
+				}
+				schema.Enums(pkg)
+				schema.Consts(pkg)
+			}
+		}()
+	}
+	wg.Wait()
+
+	fields, _ := schema.FieldsOf("testpkg", "TestTable")
+	if fields[0].Name != "TestInt" {
+		t.Errorf("schema changed by caller: %s", fields[0].Name)
+	}
+	if len(schema.TypeMap("testpkg")) == 0 {
+		t.Error("type map changed by caller")
+	}
+	for _, qualified := range []string{"testpkg", "testpkg.Unknown", "unknown.TestTable"} {
+		if _, ok := schema.ResolveQualified(qualified); ok {
+			t.Errorf("%s: want not found", qualified)
+		}
+	}
+}
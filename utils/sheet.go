@@ -0,0 +1,412 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mogebingxue/game_config_manager/datafile"
+)
+
+// 表格单元格中list、map的分隔符，例如 list：1|2|3，map：a=1|b=2
+// 元素是结构体或者值中包含分隔符时，单元格直接填json
+// 空单元格表示没有填写，空字符串填json字符串 ""
+const (
+	SheetItemSep = "|"
+	SheetKVSep   = "="
+)
+
+// SheetColumn 表格的一列，Path为点分隔的字段路径
+type SheetColumn struct {
+	Path  string
+	Alias string
+	Var   StructVar
+}
+
+// 行继承的列：展开的结构体有"$base"时在它的字段前加一列 <路径.>$base，
+// 数据有"$templates"时最后加一列$templates，单元格为模板的json；list、map中的行继承在单元格的json中
+const (
+	sheetBaseAlias      = "继承"
+	sheetTemplatesAlias = "模板"
+)
+
+// SheetColumns 按表定义展开列，结构体展开为点分隔的路径，list、map各占一列
+func SheetColumns(typMap map[string]Meta, table *Struct) []SheetColumn {
+	return DataSheetColumns(typMap, table, nil)
+}
+
+// DataSheetColumns 在SheetColumns上加入data中用到的行继承的列，导出时使用，保证导回后继承关系不变
+func DataSheetColumns(typMap map[string]Meta, table *Struct, data map[string]any) []SheetColumn {
+	var columns []SheetColumn
+	appendColumns(typMap, table, "", "", data, &columns, 0)
+	if _, ok := data[datafile.TemplatesKey]; ok {
+		columns = append(columns, SheetColumn{Path: datafile.TemplatesKey, Alias: sheetTemplatesAlias})
+	}
+	return columns
+}
+
+func appendColumns(typMap map[string]Meta, st *Struct, prefix, aliasPrefix string, data map[string]any, columns *[]SheetColumn, level int) {
+	if _, ok := data[datafile.BaseKey]; ok {
+		*columns = append(*columns, baseColumn(prefix, aliasPrefix))
+	}
+	for _, v := range st.Vars {
+		path, alias := prefix+v.Name, aliasPrefix+v.Alias
+		if meta, ok := typMap[v.Typ]; ok && meta.Typ == STRUCT && level < 10 {
+			sub, _ := data[v.Name].(map[string]any)
+			appendColumns(typMap, meta.Meta.(*Struct), path+".", alias+".", sub, columns, level+1)
+			continue
+		}
+		*columns = append(*columns, SheetColumn{Path: path, Alias: alias, Var: v})
+	}
+}
+
+func baseColumn(prefix, aliasPrefix string) SheetColumn {
+	return SheetColumn{Path: prefix + datafile.BaseKey, Alias: aliasPrefix + sheetBaseAlias, Var: StructVar{Name: datafile.BaseKey, Typ: "string"}}
+}
+
+// ResolveSheetColumn 按点分隔的路径查找字段
+func ResolveSheetColumn(typMap map[string]Meta, table *Struct, path string) (SheetColumn, error) {
+	if path == datafile.TemplatesKey {
+		return SheetColumn{Path: path, Alias: sheetTemplatesAlias}, nil
+	}
+	st := table
+	names := strings.Split(path, ".")
+	var aliases []string
+	for i, name := range names {
+		if name == datafile.BaseKey && i == len(names)-1 {
+			prefix := strings.TrimSuffix(path, datafile.BaseKey)
+			aliasPrefix := ""
+			if len(aliases) > 0 {
+				aliasPrefix = strings.Join(aliases, ".") + "."
+			}
+			return baseColumn(prefix, aliasPrefix), nil
+		}
+		v, ok := findVar(st, name)
+		if !ok {
+			return SheetColumn{}, fmt.Errorf("column %s: field %s not found in %s", path, name, st.Name)
+		}
+		aliases = append(aliases, v.Alias)
+		if i == len(names)-1 {
+			return SheetColumn{Path: path, Alias: strings.Join(aliases, "."), Var: v}, nil
+		}
+		meta, ok := typMap[v.Typ]
+		if !ok || meta.Typ != STRUCT {
+			return SheetColumn{}, fmt.Errorf("column %s: %s is not struct", path, name)
+		}
+		st = meta.Meta.(*Struct)
+	}
+	return SheetColumn{}, fmt.Errorf("column %s is empty", path)
+}
+
+func findVar(st *Struct, name string) (StructVar, bool) {
+	for _, v := range st.Vars {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return StructVar{}, false
+}
+
+// ParseSheetRow 按列头把一行单元格转换为表数据，base不为空时在base上修改
+func ParseSheetRow(typMap map[string]Meta, table *Struct, header, row []string, base map[string]any) (map[string]any, error) {
+	if base == nil {
+		base = make(map[string]any)
+	}
+	var errs []error
+	for i, path := range header {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		column, err := ResolveSheetColumn(typMap, table, path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		cell := ""
+		if i < len(row) {
+			cell = row[i]
+		}
+		//空单元格表示没有填写，在base上修改时保留原值；字符串只有完全为空时算没有填写
+		if cell == "" || column.Var.Typ != "string" && strings.TrimSpace(cell) == "" {
+			continue
+		}
+		var val any
+		if column.Path == datafile.TemplatesKey {
+			val, err = parseTemplatesCell(cell)
+		} else {
+			val, err = ParseCell(typMap, column.Var, cell)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("column %s: %w", path, err))
+			continue
+		}
+		SetPath(base, strings.Split(path, "."), val)
+	}
+	return base, errors.Join(errs...)
+}
+
+// SetPath 按路径设置值，中间的对象不存在时创建
+func SetPath(data map[string]any, path []string, val any) {
+	for _, name := range path[:len(path)-1] {
+		sub, ok := data[name].(map[string]any)
+		if !ok {
+			sub = make(map[string]any)
+			data[name] = sub
+		}
+		data = sub
+	}
+	data[path[len(path)-1]] = val
+}
+
+// GetPath 按路径取值
+func GetPath(data map[string]any, path []string) (any, bool) {
+	var val any = data
+	for _, name := range path {
+		m, ok := val.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		val, ok = m[name]
+		if !ok {
+			return nil, false
+		}
+	}
+	return val, true
+}
+
+// DeletePath 按路径删除值
+func DeletePath(data map[string]any, path []string) {
+	for _, name := range path[:len(path)-1] {
+		sub, ok := data[name].(map[string]any)
+		if !ok {
+			return
+		}
+		data = sub
+	}
+	delete(data, path[len(path)-1])
+}
+
+// FormatSheetRow 把表数据按列展开为一行单元格，是ParseSheetRow的逆操作
+func FormatSheetRow(typMap map[string]Meta, columns []SheetColumn, data map[string]any) []string {
+	row := make([]string, len(columns))
+	for i, column := range columns {
+		val, ok := GetPath(data, strings.Split(column.Path, "."))
+		if !ok {
+			continue
+		}
+		row[i] = FormatCell(typMap, column.Var, val)
+	}
+	return row
+}
+
+// FormatCell 把值转换为单元格，ParseCell能原样读回；类型不符或无法用分隔符表示时使用json
+func FormatCell(typMap map[string]Meta, v StructVar, val any) string {
+	switch v.Typ {
+	case "int":
+		if num, ok := toInt(val); ok {
+			return strconv.Itoa(num)
+		}
+	case "string":
+		if str, ok := val.(string); ok {
+			return formatStringCell(str)
+		}
+	case "bool":
+		if b, ok := val.(bool); ok {
+			return strconv.FormatBool(b)
+		}
+	case "list":
+		list, ok := val.([]any)
+		if !ok || len(list) == 0 {
+			break
+		}
+		elem := StructVar{Name: v.Name, Typ: v.ValueType}
+		items := make([]string, 0, len(list))
+		for _, item := range list {
+			cell, ok := formatItem(typMap, elem, item)
+			if !ok {
+				return formatJsonCell(val)
+			}
+			items = append(items, cell)
+		}
+		return strings.Join(items, SheetItemSep)
+	case "map":
+		m, ok := val.(map[string]any)
+		if !ok || len(m) == 0 {
+			break
+		}
+		elem := StructVar{Name: v.Name, Typ: v.ValueType}
+		items := make([]string, 0, len(m))
+		for _, k := range SortedKeys(m) {
+			cell, ok := formatItem(typMap, elem, m[k])
+			if !ok || k != strings.TrimSpace(k) || strings.ContainsAny(k, SheetItemSep+SheetKVSep) {
+				return formatJsonCell(val)
+			}
+			items = append(items, k+SheetKVSep+cell)
+		}
+		return strings.Join(items, SheetItemSep)
+	default:
+		meta, ok := typMap[v.Typ]
+		if ok && meta.Typ == ENUM {
+			if num, ok := toInt(val); ok {
+				for _, enumVar := range meta.Meta.(*Enum).Vars {
+					if enumVar.Default == strconv.Itoa(num) {
+						return enumVar.Name
+					}
+				}
+				return strconv.Itoa(num)
+			}
+		}
+	}
+	return formatJsonCell(val)
+}
+
+// formatItem list、map的元素，不能用分隔符表示时返回false
+func formatItem(typMap map[string]Meta, elem StructVar, val any) (string, bool) {
+	if meta, ok := typMap[elem.Typ]; ok && meta.Typ == STRUCT {
+		return "", false
+	}
+	cell := FormatCell(typMap, elem, val)
+	if cell == "" || cell != strings.TrimSpace(cell) || strings.ContainsAny(cell, SheetItemSep+SheetKVSep) ||
+		strings.HasPrefix(cell, "[") || strings.HasPrefix(cell, "{") {
+		return "", false
+	}
+	return cell, true
+}
+
+func formatJsonCell(val any) string {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// formatStringCell 空字符串和看起来像json字符串的值写成json字符串，其他原样
+func formatStringCell(str string) string {
+	if str == "" || isJsonStringCell(str) {
+		return formatJsonCell(str)
+	}
+	return str
+}
+
+// parseStringCell json字符串(例如 "" 表示空字符串)解析后使用，其他原样
+func parseStringCell(cell string) string {
+	if isJsonStringCell(cell) {
+		var str string
+		if err := json.Unmarshal([]byte(cell), &str); err == nil {
+			return str
+		}
+	}
+	return cell
+}
+
+func isJsonStringCell(cell string) bool {
+	return len(cell) >= 2 && strings.HasPrefix(cell, `"`) && strings.HasSuffix(cell, `"`)
+}
+
+// ParseCell 按字段类型转换单元格，结果和json解析出来的值类型一致
+func ParseCell(typMap map[string]Meta, v StructVar, cell string) (any, error) {
+	if v.Typ == "string" {
+		return parseStringCell(cell), nil
+	}
+	cell = strings.TrimSpace(cell)
+	switch v.Typ {
+	case "int":
+		num, err := strconv.Atoi(cell)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not int", cell)
+		}
+		return float64(num), nil
+	case "bool":
+		switch strings.ToLower(cell) {
+		case "true", "1", "是":
+			return true, nil
+		case "false", "0", "否":
+			return false, nil
+		}
+		return nil, fmt.Errorf("%q is not bool", cell)
+	case "list":
+		if strings.HasPrefix(cell, "[") {
+			return parseJsonCell(cell)
+		}
+		elem := StructVar{Name: v.Name, Typ: v.ValueType}
+		list := make([]any, 0)
+		for _, item := range strings.Split(cell, SheetItemSep) {
+			val, err := ParseCell(typMap, elem, strings.TrimSpace(item))
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, val)
+		}
+		return list, nil
+	case "map":
+		if strings.HasPrefix(cell, "{") {
+			return parseJsonCell(cell)
+		}
+		elem := StructVar{Name: v.Name, Typ: v.ValueType}
+		m := make(map[string]any)
+		for _, item := range strings.Split(cell, SheetItemSep) {
+			key, value, ok := strings.Cut(item, SheetKVSep)
+			if !ok {
+				return nil, fmt.Errorf("%q is not key%svalue", item, SheetKVSep)
+			}
+			val, err := ParseCell(typMap, elem, strings.TrimSpace(value))
+			if err != nil {
+				return nil, err
+			}
+			m[strings.TrimSpace(key)] = val
+		}
+		return m, nil
+	}
+	meta, ok := typMap[v.Typ]
+	if !ok {
+		return nil, fmt.Errorf("type not found %s", v.Typ)
+	}
+	switch meta.Typ {
+	case ENUM:
+		return parseEnumCell(meta.Meta.(*Enum), cell)
+	case STRUCT:
+		return parseJsonCell(cell)
+	}
+	return nil, fmt.Errorf("type %s can not be used as field", v.Typ)
+}
+
+// parseEnumCell 枚举可以填数字、名字、别名或编辑器里显示的 别名(名字)
+func parseEnumCell(enum *Enum, cell string) (any, error) {
+	if num, err := strconv.Atoi(cell); err == nil {
+		return float64(num), nil
+	}
+	for _, v := range enum.Vars {
+		if cell == v.Name || cell == v.Alias || cell == fmt.Sprintf("%s(%s)", v.Alias, v.Name) {
+			num, err := strconv.Atoi(v.Default)
+			if err != nil {
+				return nil, err
+			}
+			return float64(num), nil
+		}
+	}
+	return nil, fmt.Errorf("%q is not %s", cell, enum.Name)
+}
+
+// parseTemplatesCell $templates列必须是json对象
+func parseTemplatesCell(cell string) (any, error) {
+	val, err := parseJsonCell(cell)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := val.(map[string]any); !ok {
+		return nil, fmt.Errorf("%s is not object", datafile.TemplatesKey)
+	}
+	return val, nil
+}
+
+func parseJsonCell(cell string) (any, error) {
+	var val any
+	if err := json.Unmarshal([]byte(cell), &val); err != nil {
+		return nil, fmt.Errorf("invalid json %q: %w", cell, err)
+	}
+	return val, nil
+}
@@ -0,0 +1,195 @@
+package utils
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+const sheetTestXml = `<conf package="p">
+	<enum name="E"><var name="A" default="1" alias="甲"/><var name="B" default="2" alias="乙"/></enum>
+	<struct name="S"><var name="X" type="int"/><var name="Y" type="string"/></struct>
+	<table name="T">
+		<var name="Int" type="int"/>
+		<var name="Str" type="string"/>
+		<var name="Bool" type="bool"/>
+		<var name="Enum" type="E"/>
+		<var name="List" type="list" valueType="int"/>
+		<var name="StrList" type="list" valueType="string"/>
+		<var name="Map" type="map" valueType="E"/>
+		<var name="Struct" type="S"/>
+		<var name="StructList" type="list" valueType="S"/>
+	</table>
+</conf>`
+
+func sheetTestTable(t *testing.T) (map[string]Meta, *Struct) {
+	t.Helper()
+	conf, typMap := testTypMap(t, sheetTestXml)
+	return typMap, &conf.Tables[0]
+}
+
+func TestParseSheetRow(t *testing.T) {
+	typMap, table := sheetTestTable(t)
+	header := []string{"Int", "Str", "Bool", "Enum", "List", "Map", "Struct.X", "Struct.Y"}
+	tests := []struct {
+		name string
+		row  []string
+		base map[string]any
+		want map[string]any
+	}{
+		{
+			name: "all filled",
+			row:  []string{"1", "a", "是", "乙", "1|2", "k=A|j=2", "3", "y"},
+			want: map[string]any{"Int": 1.0, "Str": "a", "Bool": true, "Enum": 2.0, "List": []any{1.0, 2.0},
+				"Map": map[string]any{"k": 1.0, "j": 2.0}, "Struct": map[string]any{"X": 3.0, "Y": "y"}},
+		},
+		{
+			name: "empty cells are not filled",
+			row:  []string{"", "", " ", "", "", "", "", ""},
+			want: map[string]any{},
+		},
+		{
+			name: "short row",
+			row:  []string{"5"},
+			want: map[string]any{"Int": 5.0},
+		},
+		{
+			name: "empty string marker",
+			row:  []string{"", `""`, "", "", "", "", "", `""`},
+			want: map[string]any{"Str": "", "Struct": map[string]any{"Y": ""}},
+		},
+		{
+			name: "whitespace string is kept",
+			row:  []string{"", " ", "", "", "", "", "", ""},
+			want: map[string]any{"Str": " "},
+		},
+		{
+			name: "merge keeps fields not filled",
+			row:  []string{"7", "", "", "", "", "", "", "new"},
+			base: map[string]any{"Int": 1.0, "Str": "old", "Struct": map[string]any{"X": 2.0, "Y": "old"}},
+			want: map[string]any{"Int": 7.0, "Str": "old", "Struct": map[string]any{"X": 2.0, "Y": "new"}},
+		},
+		{
+			name: "json cells",
+			row:  []string{"", `"\"q\""`, "", "", "[1,2]", `{"a":1}`, "", ""},
+			want: map[string]any{"Str": `"q"`, "List": []any{1.0, 2.0}, "Map": map[string]any{"a": 1.0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSheetRow(typMap, table, header, tt.row, tt.base)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSheetRowErrors(t *testing.T) {
+	typMap, table := sheetTestTable(t)
+	tests := []struct {
+		header, cell string
+	}{
+		{"Int", "x"},
+		{"Bool", "maybe"},
+		{"Enum", "C"},
+		{"Map", "a"},
+		{"Missing", "1"},
+		{"Struct.Z", "1"},
+		{"Int.X", "1"},
+	}
+	for _, tt := range tests {
+		if _, err := ParseSheetRow(typMap, table, []string{tt.header}, []string{tt.cell}, nil); err == nil {
+			t.Errorf("%s=%q: want error", tt.header, tt.cell)
+		}
+	}
+}
+
+// TestSheetRoundTrip FormatSheetRow导出的一行用ParseSheetRow原样导回
+func TestSheetRoundTrip(t *testing.T) {
+	typMap, table := sheetTestTable(t)
+	columns := SheetColumns(typMap, table)
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.Path
+	}
+	tests := []map[string]any{
+		{},
+		{"Int": 0.0, "Str": "", "Bool": false},
+		{"Str": `"quoted"`, "Enum": 1.0, "List": []any{3.0, 1.0}},
+		{"Str": "a|b=c", "StrList": []any{"", "x", "a|b"}},
+		{"Map": map[string]any{"a=b": 1.0, "c": 2.0}},
+		{"Struct": map[string]any{"X": 1.0, "Y": ""}, "StructList": []any{map[string]any{"X": 1.0}}},
+		{"Enum": 9.0, "List": []any{}},
+	}
+	for _, data := range tests {
+		row := FormatSheetRow(typMap, columns, data)
+		got, err := ParseSheetRow(typMap, table, header, row, nil)
+		if err != nil {
+			t.Errorf("%v: %v", data, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, data) {
+			t.Errorf("row %q: got %v, want %v", row, got, data)
+		}
+	}
+}
+
+// TestSheetRoundTripTemplates 用到行继承的数据导出后原样导回，继承关系不变
+func TestSheetRoundTripTemplates(t *testing.T) {
+	typMap, table := sheetTestTable(t)
+	tests := []string{
+		`{"Struct":{"X":1},"StructList":[{"X":2}]}`,
+		`{"$templates":{"D":{"X":1,"Y":"d"}},"Struct":{"$base":"D","Y":"s"}}`,
+		`{"$templates":{"D":{"X":1,"Y":"d"},"E":{"$base":"D","X":2}},"StructList":[{"$base":"E"},{"$base":"D","Y":null}]}`,
+		`{"Int":1,"$templates":{}}`,
+	}
+	for _, content := range tests {
+		data := make(map[string]any)
+		if err := json.Unmarshal([]byte(content), &data); err != nil {
+			t.Fatal(err)
+		}
+		columns := DataSheetColumns(typMap, table, data)
+		header := make([]string, len(columns))
+		aliases := make([]string, len(columns))
+		for i, column := range columns {
+			header[i] = column.Path
+			resolved, err := ResolveSheetColumn(typMap, table, column.Path)
+			if err != nil {
+				t.Fatalf("%s: %v", column.Path, err)
+			}
+			aliases[i] = resolved.Alias
+			if resolved.Alias != column.Alias {
+				t.Errorf("%s: alias %q, want %q", column.Path, resolved.Alias, column.Alias)
+			}
+		}
+		row := FormatSheetRow(typMap, columns, data)
+		got, err := ParseSheetRow(typMap, table, header, row, nil)
+		if err != nil {
+			t.Errorf("%s: %v", content, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, data) {
+			t.Errorf("header %q row %q: got %v, want %v", header, row, got, data)
+		}
+	}
+
+	columns := DataSheetColumns(typMap, table, map[string]any{"$templates": map[string]any{}, "Struct": map[string]any{"$base": "D"}})
+	var paths []string
+	for _, column := range columns {
+		paths = append(paths, column.Path)
+	}
+	if want := []string{"Struct.$base", "Struct.X", "Struct.Y"}; !slices.Equal(paths[7:10], want) {
+		t.Errorf("columns %q, want %q after Map", paths, want)
+	}
+	if paths[len(paths)-1] != "$templates" {
+		t.Errorf("last column %s", paths[len(paths)-1])
+	}
+	if _, err := ParseSheetRow(typMap, table, []string{"$templates"}, []string{"[1]"}, nil); err == nil {
+		t.Error("templates not object: want error")
+	}
+}
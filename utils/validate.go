@@ -0,0 +1,273 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/mogebingxue/game_config_manager/datafile"
+)
+
+// ValidateDataDir 校验数据目录下所有数据文件，返回所有错误；overlays为conf.yaml的overlays，
+// 有overlay的表还会按顺序合并后校验，和ConfigManager加载的数据一致
+func ValidateDataDir(schema *Schema, dir string, overlays ...string) []error {
+	files, err := ListDataFiles(dir)
+	if err != nil {
+		return []error{err}
+	}
+	var errs []error
+	for _, file := range files {
+		data, err := readDataFile(file.Path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		errs = append(errs, validateTable(schema, file.Package, file.Table, file.Path, data)...)
+	}
+	return append(errs, validateOverlays(schema, dir, overlays)...)
+}
+
+// validateOverlays 校验合并overlay后的数据，错误的路径为最后合并的overlay文件；
+// 基础数据读取失败的表已经在ValidateDataDir中报告，不再校验
+func validateOverlays(schema *Schema, dir string, overlays []string) []error {
+	var errs []error
+	var names []string                        //有overlay的表，按第一次出现的顺序
+	merged := make(map[string]map[string]any) //key：DataFileName；value：合并后的数据，读取失败时为nil
+	last := make(map[string]DataFile)         //key：DataFileName；value：最后合并的overlay文件
+	for _, overlay := range overlays {
+		files, err := ListDataFiles(overlay)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, file := range files {
+			name := DataFileName(file.Package, file.Table)
+			data, ok := merged[name]
+			if !ok {
+				names = append(names, name)
+				data, err = readDataFile(filepath.Join(dir, name))
+				if errors.Is(err, fs.ErrNotExist) {
+					//只在overlay中的表
+					data, err = make(map[string]any), nil
+				}
+				if err != nil {
+					data = nil
+				}
+			}
+			patch, err := readDataFile(file.Path)
+			if err != nil {
+				errs = append(errs, err)
+				data = nil
+			}
+			if data != nil {
+				data = datafile.MergeOverlay(data, patch).(map[string]any)
+			}
+			merged[name], last[name] = data, file
+		}
+	}
+	for _, name := range names {
+		if data := merged[name]; data != nil {
+			file := last[name]
+			errs = append(errs, validateTable(schema, file.Package, file.Table, file.Path, data)...)
+		}
+	}
+	return errs
+}
+
+func validateTable(schema *Schema, pkg, table, path string, data map[string]any) []error {
+	meta, ok := schema.LookupType(pkg, table)
+	if !ok || meta.Typ != TABLE {
+		return []error{&DataError{Path: path, Err: fmt.Errorf("table %s.%s not found", pkg, table)}}
+	}
+	return ValidateData(schema.TypeMap(pkg), meta.Meta.(*Struct), path, data)
+}
+
+func readDataFile(path string) (map[string]any, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data := make(map[string]any)
+	if err := json.Unmarshal(content, &data); err != nil {
+		return nil, &DataError{Path: path, Err: err}
+	}
+	return data, nil
+}
+
+// ValidateData 按表定义校验一个数据文件，先展开行继承再校验
+func ValidateData(typMap map[string]Meta, table *Struct, path string, data map[string]any) []error {
+	data, _, err := datafile.ResolveTemplates(data)
+	if err != nil {
+		return []error{&DataError{Path: path, Err: err}}
+	}
+	v := &validator{typMap: typMap, path: path}
+	v.validateStruct(table, "", data)
+	return v.errs
+}
+
+type validator struct {
+	typMap map[string]Meta
+	path   string
+	errs   []error
+}
+
+func (v *validator) addErr(ptr string, format string, args ...any) {
+	v.errs = append(v.errs, &DataError{Path: v.path, Pointer: ptr, Err: fmt.Errorf(format, args...)})
+}
+
+func (v *validator) validateStruct(st *Struct, ptr string, data map[string]any) {
+	known := make(map[string]bool, len(st.Vars))
+	for _, structVar := range st.Vars {
+		known[structVar.Name] = true
+		val, ok := data[structVar.Name]
+		if !ok {
+			if structVar.Required {
+				v.addErr(ptr+"/"+EscapePointer(structVar.Name), "missing required field %s", structVar.Name)
+			}
+			continue
+		}
+		v.validateValue(structVar, ptr+"/"+EscapePointer(structVar.Name), val)
+	}
+	for _, k := range SortedKeys(data) {
+		if !known[k] {
+			v.addErr(ptr+"/"+EscapePointer(k), "unknown field %s in %s", k, st.Name)
+		}
+	}
+}
+
+func (v *validator) validateValue(structVar StructVar, ptr string, val any) {
+	if isConflict(val) {
+		v.addErr(ptr, "unresolved merge conflict")
+		return
+	}
+	switch structVar.Typ {
+	case "int":
+		num, ok := toInt(val)
+		if !ok {
+			v.addErr(ptr, "expect int, got %s", jsonTypeName(val))
+			return
+		}
+		v.validateBound(structVar, ptr, num)
+	case "string":
+		if _, ok := val.(string); !ok {
+			v.addErr(ptr, "expect string, got %s", jsonTypeName(val))
+		}
+	case "bool":
+		if _, ok := val.(bool); !ok {
+			v.addErr(ptr, "expect bool, got %s", jsonTypeName(val))
+		}
+	case "list":
+		list, ok := val.([]any)
+		if !ok {
+			v.addErr(ptr, "expect list, got %s", jsonTypeName(val))
+			return
+		}
+		elem := StructVar{Name: structVar.Name, Typ: structVar.ValueType, Min: structVar.Min, Max: structVar.Max}
+		for i, item := range list {
+			v.validateValue(elem, ptr+"/"+strconv.Itoa(i), item)
+		}
+	case "map":
+		m, ok := val.(map[string]any)
+		if !ok {
+			v.addErr(ptr, "expect map, got %s", jsonTypeName(val))
+			return
+		}
+		elem := StructVar{Name: structVar.Name, Typ: structVar.ValueType, Min: structVar.Min, Max: structVar.Max}
+		for _, k := range SortedKeys(m) {
+			if err := CheckMapKey(structVar.KeyType, k); err != nil {
+				v.addErr(ptr+"/"+EscapePointer(k), "%v", err)
+			}
+			v.validateValue(elem, ptr+"/"+EscapePointer(k), m[k])
+		}
+	default:
+		meta, ok := v.typMap[structVar.Typ]
+		if !ok {
+			v.addErr(ptr, "type not found %s", structVar.Typ)
+			return
+		}
+		switch meta.Typ {
+		case ENUM:
+			num, ok := toInt(val)
+			if !ok {
+				v.addErr(ptr, "expect enum %s, got %s", structVar.Typ, jsonTypeName(val))
+				return
+			}
+			if !enumHasValue(meta.Meta.(*Enum), num) {
+				v.addErr(ptr, "invalid enum %s value %d", structVar.Typ, num)
+			}
+		case STRUCT:
+			m, ok := val.(map[string]any)
+			if !ok {
+				v.addErr(ptr, "expect struct %s, got %s", structVar.Typ, jsonTypeName(val))
+				return
+			}
+			v.validateStruct(meta.Meta.(*Struct), ptr, m)
+		default:
+			v.addErr(ptr, "type %s can not be used as field", structVar.Typ)
+		}
+	}
+}
+
+func (v *validator) validateBound(structVar StructVar, ptr string, num int) {
+	if minVal, ok, err := ResolveInt(v.typMap, structVar.Min); err == nil && ok && num < minVal {
+		v.addErr(ptr, "%d less than min %s", num, structVar.Min)
+	}
+	if maxVal, ok, err := ResolveInt(v.typMap, structVar.Max); err == nil && ok && num > maxVal {
+		v.addErr(ptr, "%d greater than max %s", num, structVar.Max)
+	}
+}
+
+// CheckMapKey 检查map的key，string类型不允许为空，int类型必须是整数
+func CheckMapKey(keyType, key string) error {
+	if key == "" {
+		return errors.New("map key is empty")
+	}
+	if keyType == "int" {
+		if _, err := strconv.Atoi(key); err != nil {
+			return fmt.Errorf("map key %q is not int", key)
+		}
+	}
+	return nil
+}
+
+func enumHasValue(enum *Enum, num int) bool {
+	for _, v := range enum.Vars {
+		if n, err := strconv.Atoi(v.Default); err == nil && n == num {
+			return true
+		}
+	}
+	return false
+}
+
+// toInt json中的数字都是float64，只接受整数
+// toInt 只有整数并且在int范围内时返回true，例如1e20不是int
+func toInt(val any) (int, bool) {
+	num, ok := val.(float64)
+	if !ok || num != math.Trunc(num) || num < math.MinInt || num >= -math.MinInt {
+		return 0, false
+	}
+	return int(num), true
+}
+
+func jsonTypeName(val any) string {
+	switch v := val.(type) {
+	case nil:
+		return "null"
+	case float64:
+		return "number " + strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case []any:
+		return "list"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", val)
+}
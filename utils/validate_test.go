@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const validateTestXml = `<conf package="p">
+	<table name="T">
+		<var name="N" type="int" min="0" max="10" required="true"/>
+		<var name="S" type="string"/>
+	</table>
+</conf>`
+
+// writeTestFiles 在临时目录中写入文件，key为相对路径
+func writeTestFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestValidateDataDirOverlays(t *testing.T) {
+	schema, err := LoadSchema(writeTestFiles(t, map[string]string{"p.xml": validateTestXml}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		base     string   //为空时没有基础数据
+		overlays []string //为空时这个overlay没有这个表
+		want     []string //错误中包含的内容，按顺序
+	}{
+		{"no overlay", `{"N":1}`, nil, nil},
+		{"valid overlay", `{"N":1}`, []string{`{"N":10,"S":"qa"}`}, nil},
+		{"out of range", `{"N":1}`, []string{`{"N":20}`}, []string{"o0/p/T.json#/N"}},
+		{"delete required", `{"N":1}`, []string{`{"N":null}`}, []string{"o0/p/T.json#/N: missing required field N"}},
+		{"replace without required", `{"N":1}`, []string{`{"$replace":true,"S":"qa"}`}, []string{"missing required field N"}},
+		{"later overlay fixes", `{"N":1}`, []string{`{"N":20}`, `{"N":5}`}, nil},
+		{"later overlay breaks", `{"N":1}`, []string{`{"S":"qa"}`, `{"N":-1}`}, []string{"o1/p/T.json#/N"}},
+		{"skip overlay", `{"N":1}`, []string{`{"N":20}`, ""}, []string{"o0/p/T.json#/N"}},
+		{"overlay only", "", []string{`{"S":"qa"}`}, []string{"o0/p/T.json#/N: missing required field N"}},
+		{"overlay invalid json", `{"N":1}`, []string{`{"N":`}, []string{"o0/p/T.json"}},
+		{"base invalid", `{"N":20}`, []string{`{"S":"qa"}`}, []string{"data/p/T.json#/N", "o0/p/T.json#/N"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//.keep保证目录存在
+			files := map[string]string{"data/.keep": ""}
+			if tt.base != "" {
+				files["data/p/T.json"] = tt.base
+			}
+			for i, overlay := range tt.overlays {
+				dir := fmt.Sprintf("o%d", i)
+				files[dir+"/.keep"] = ""
+				if overlay != "" {
+					files[dir+"/p/T.json"] = overlay
+				}
+			}
+			root := writeTestFiles(t, files)
+			overlays := make([]string, len(tt.overlays))
+			for i := range overlays {
+				overlays[i] = filepath.Join(root, fmt.Sprintf("o%d", i))
+			}
+			errs := ValidateDataDir(schema, filepath.Join(root, "data"), overlays...)
+			if len(errs) != len(tt.want) {
+				t.Fatalf("got %d errors %v, want %v", len(errs), errs, tt.want)
+			}
+			for i, err := range errs {
+				if !strings.Contains(err.Error(), tt.want[i]) {
+					t.Errorf("error %d %q, want containing %q", i, err, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestValidateRequiredKeyType(t *testing.T) {
+	_, typMap := testTypMap(t, `<conf package="p">
+	<struct name="S">
+		<var name="X" type="int" required="true"/>
+	</struct>
+	<table name="T">
+		<var name="N" type="int" required="true"/>
+		<var name="IntMap" type="map" keyType="int" valueType="S"/>
+		<var name="StrMap" type="map" keyType="string" valueType="int"/>
+	</table>
+</conf>`)
+	table := typMap["T"].Meta.(*Struct)
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{"valid", `{"N":0,"IntMap":{"1":{"X":1},"-2":{"X":2}},"StrMap":{"a":1}}`, nil},
+		{"missing required", `{"IntMap":{}}`, []string{"#/N: missing required field N"}},
+		{"missing required in map value", `{"N":1,"IntMap":{"1":{}}}`, []string{"#/IntMap/1/X: missing required field X"}},
+		{"not int key", `{"N":1,"IntMap":{"a":{"X":1}}}`, []string{`#/IntMap/a: map key "a" is not int`}},
+		{"empty key", `{"N":1,"StrMap":{"":1}}`, []string{"#/StrMap/: map key is empty"}},
+		{"string key", `{"N":1,"StrMap":{"1":1,"a":2}}`, nil},
+		{"beyond int range", `{"N":1e20}`, []string{"#/N: expect int, got number 100000000000000000000"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := make(map[string]any)
+			if err := json.Unmarshal([]byte(tt.data), &data); err != nil {
+				t.Fatal(err)
+			}
+			errs := ValidateData(typMap, table, "T.json", data)
+			if len(errs) != len(tt.want) {
+				t.Fatalf("errors %v, want %v", errs, tt.want)
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(errs[i].Error(), want) {
+					t.Errorf("error %q does not contain %q", errs[i], want)
+				}
+			}
+		})
+	}
+
+	//keyType只能用于map，并且只支持string和int
+	for _, content := range []string{
+		`<conf package="p"><table name="T"><var name="L" type="list" keyType="int" valueType="int"/></table></conf>`,
+		`<conf package="p"><table name="T"><var name="M" type="map" keyType="bool" valueType="int"/></table></conf>`,
+	} {
+		if err := checkXml(t, content); err == nil {
+			t.Errorf("%s: want error", content)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/mogebingxue/game_config_manager"
+	"github.com/mogebingxue/game_config_manager/utils"
+)
+
+// 按元数据校验所有数据文件和合并conf.yaml的overlays后的数据，有错误时返回非0，可以用在CI中
+// 用法：go run ./validate [-conf ./conf.yaml]
+func main() {
+	confPath := flag.String("conf", "./conf.yaml", "配置文件")
+	flag.Parse()
+	cfg, err := config.LoadConfig(*confPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	schema, err := utils.LoadSchema(cfg.MetadataPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "load metadata:", err)
+		os.Exit(2)
+	}
+	errs := utils.ValidateDataDir(schema, cfg.DataPath, cfg.Overlays...)
+	for _, err := range errs {
+		fmt.Println(err)
+	}
+	if len(errs) > 0 {
+		fmt.Printf("%d errors\n", len(errs))
+		os.Exit(1)
+	}
+	fmt.Println("ok")
+}
@@ -0,0 +1,249 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPollInterval = 30 * time.Second
+	defaultDebounce     = 200 * time.Millisecond
+)
+
+// WatchConfig 监听数据来源的方式，默认使用数据来源的变化通知，不支持时(例如网络盘、zip、http)回退到轮询
+type WatchConfig struct {
+	Poll          bool          `yaml:"poll"`          //强制轮询
+	PollInterval  time.Duration `yaml:"poll_interval"` //轮询间隔，默认30s
+	Debounce      time.Duration `yaml:"debounce"`      //连续事件合并的时间，默认200ms，保存时多次写入只重新加载一次
+	Transactional bool          `yaml:"transactional"` //事务模式，合并到同一批的表有一个加载或校验失败时都不发布
+}
+
+func (w WatchConfig) pollInterval() time.Duration {
+	if w.PollInterval <= 0 {
+		return defaultPollInterval
+	}
+	return w.PollInterval
+}
+
+func (w WatchConfig) debounce() time.Duration {
+	if w.Debounce <= 0 {
+		return defaultDebounce
+	}
+	return w.Debounce
+}
+
+// sourceState 数据来源和扫描到的文件，只在监听goroutine中修改
+type sourceState struct {
+	source  Source
+	version string                //最后一次轮询的版本
+	files   map[string]SourceFile //key：文件名，Hash为内容的校验值
+	polled  bool
+}
+
+// track 记录文件，返回内容是否变化；修改时间和大小不变时不读取文件，
+// 否则比较内容的校验值，只修改了时间(例如touch)或改回原来的内容时不重新加载
+func (s *sourceState) track(file SourceFile) bool {
+	old, ok := s.files[file.Name]
+	if ok && file.Hash == "" && old.ModTime.Equal(file.ModTime) && old.Size == file.Size {
+		return false
+	}
+	file = fileHash(s.source, file)
+	s.files[file.Name] = file
+	//读取失败时没有校验值，交给加载处理
+	return !ok || file.Hash == "" || file.Hash != old.Hash
+}
+
+// fileHash 数据来源没有提供校验值时计算内容的sha256
+func fileHash(source Source, file SourceFile) SourceFile {
+	if file.Hash != "" {
+		return file
+	}
+	data, err := source.ReadFile(file.Name)
+	if err != nil {
+		return file
+	}
+	sum := sha256.Sum256(data)
+	file.Hash = hex.EncodeToString(sum[:])
+	return file
+}
+
+// sourceEvent 数据来源的变化通知，names为空表示重新列出所有文件
+type sourceEvent struct {
+	state *sourceState
+	names []string
+}
+
+// SetWatchConfig 设置监听方式，在StartService之前调用
+func (m *ConfigManager) SetWatchConfig(watch WatchConfig) {
+	m.watch = watch
+}
+
+// startWatch 支持变化通知的数据来源使用通知，其他的轮询，都在同一个goroutine中重新加载
+func (m *ConfigManager) startWatch() {
+	events := make(chan sourceEvent, 64)
+	for _, state := range m.sources {
+		if !m.watch.Poll {
+			_, err := state.source.Watch(func(names []string) {
+				events <- sourceEvent{state: state, names: names}
+			})
+			if err == nil {
+				continue
+			}
+			if !errors.Is(err, ErrWatchNotSupported) {
+				slog.Warn("config watch unavailable, fallback to polling:", "source", state.source, "err", err)
+			}
+		}
+		state.polled = true
+	}
+	m.watching.Store(true)
+	go m.watchLoop(events)
+}
+
+// inWatchLoop 在监听goroutine中执行fn并等待结果，和监听触发的重新加载、订阅回调串行执行；
+// 没有开始监听时在当前goroutine中执行。不能在订阅回调中调用，ctx结束时不再等待
+func (m *ConfigManager) inWatchLoop(ctx context.Context, fn func() error) error {
+	if !m.watching.Load() {
+		return fn()
+	}
+	done := make(chan error, 1)
+	select {
+	case m.requests <- func() { done <- fn() }:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *ConfigManager) watchLoop(events <-chan sourceEvent) {
+	var poll <-chan time.Time
+	for _, state := range m.sources {
+		if state.polled {
+			ticker := time.NewTicker(m.watch.pollInterval())
+			defer ticker.Stop()
+			poll = ticker.C
+			break
+		}
+	}
+	debounce := time.NewTimer(m.watch.debounce())
+	debounce.Stop()
+	pending := make(map[*sourceState]map[string]struct{})
+	for {
+		select {
+		case event := <-events:
+			names, ok := pending[event.state]
+			if !ok {
+				names = make(map[string]struct{})
+				pending[event.state] = names
+			}
+			if event.names == nil {
+				names[""] = struct{}{}
+			}
+			for _, name := range event.names {
+				names[name] = struct{}{}
+			}
+			debounce.Reset(m.watch.debounce())
+		case <-debounce.C:
+			for state, names := range pending {
+				m.checkNames(state, names)
+			}
+			pending = make(map[*sourceState]map[string]struct{})
+			m.reloadDirty()
+		case fn := <-m.requests:
+			fn()
+		case <-poll:
+			for _, state := range m.sources {
+				if state.polled {
+					m.pollSource(state, false)
+				}
+			}
+			m.reloadDirty()
+		}
+	}
+}
+
+// updateScanDetail 扫描所有数据来源，新增、修改、删除的文件都标记对应的数据文件
+func (m *ConfigManager) updateScanDetail(init bool) {
+	for _, state := range m.sources {
+		m.pollSource(state, init)
+	}
+}
+
+// pollSource 版本不变时不再列出文件
+func (m *ConfigManager) pollSource(state *sourceState, init bool) {
+	version, err := state.source.Version()
+	if err != nil {
+		slog.Warn("config source version failed:", "source", state.source, "err", err)
+		return
+	}
+	if version != "" && version == state.version {
+		return
+	}
+	if m.scanSource(state, init) {
+		state.version = version
+	}
+}
+
+// scanSource 列出所有文件和上次比较，新增、内容变化和删除的文件标记为修改，列出失败时不修改，返回是否成功
+func (m *ConfigManager) scanSource(state *sourceState, init bool) bool {
+	list, err := state.source.List()
+	if err != nil {
+		slog.Warn("config source list failed:", "source", state.source, "err", err)
+		return false
+	}
+	seen := make(map[string]struct{}, len(list))
+	for _, file := range list {
+		if !isDataFile(file.Name) {
+			continue
+		}
+		seen[file.Name] = struct{}{}
+		if state.track(file) && !init {
+			m.dirty[dataFileName(file.Name)] = struct{}{}
+		}
+	}
+	for name := range state.files {
+		if _, ok := seen[name]; !ok {
+			delete(state.files, name)
+			m.dirty[dataFileName(name)] = struct{}{}
+		}
+	}
+	return true
+}
+
+// checkNames 检查通知中的文件，目录变化或需要重新列出时扫描整个数据来源
+func (m *ConfigManager) checkNames(state *sourceState, names map[string]struct{}) {
+	for name := range names {
+		if !isDataFile(name) {
+			m.scanSource(state, false)
+			return
+		}
+	}
+	for name := range names {
+		file, err := state.source.Stat(name)
+		_, exist := state.files[name]
+		switch {
+		case err != nil && exist:
+			delete(state.files, name)
+			m.dirty[dataFileName(name)] = struct{}{}
+		case err == nil && state.track(file):
+			m.dirty[dataFileName(name)] = struct{}{}
+		}
+	}
+}
+
+// dataFileName bin文件对应的json文件
+func dataFileName(name string) string {
+	if strings.HasSuffix(name, ".bin") {
+		return strings.TrimSuffix(name, ".bin") + ".json"
+	}
+	return name
+}
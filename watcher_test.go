@@ -0,0 +1,305 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestScanChanges(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Minute)
+	file := func(content string, modTime time.Time) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte(content), ModTime: modTime}
+	}
+	tests := []struct {
+		name   string
+		change func(fsys fstest.MapFS)
+		want   []string //标记为修改的数据文件
+	}{
+		{name: "unchanged", change: func(fsys fstest.MapFS) {}},
+		{name: "touch", change: func(fsys fstest.MapFS) { fsys["p/a.json"] = file(`{"N":1}`, t1) }},
+		{name: "same size", change: func(fsys fstest.MapFS) { fsys["p/a.json"] = file(`{"N":2}`, t1) }, want: []string{"p/a.json"}},
+		{name: "size changed", change: func(fsys fstest.MapFS) { fsys["p/a.json"] = file(`{"N":10}`, t0) }, want: []string{"p/a.json"}},
+		{name: "added", change: func(fsys fstest.MapFS) { fsys["p/c.json"] = file(`{}`, t1) }, want: []string{"p/c.json"}},
+		{name: "deleted", change: func(fsys fstest.MapFS) { delete(fsys, "p/a.json") }, want: []string{"p/a.json"}},
+		{name: "bin changed", change: func(fsys fstest.MapFS) { fsys["p/b.bin"] = file("bin2", t1) }, want: []string{"p/b.json"}},
+		{name: "bin deleted", change: func(fsys fstest.MapFS) { delete(fsys, "p/b.bin") }, want: []string{"p/b.json"}},
+		{name: "other file", change: func(fsys fstest.MapFS) { fsys["p/readme.txt"] = file("x", t1) }},
+	}
+	scans := []struct {
+		name string
+		scan func(m *ConfigManager, state *sourceState, fsys fstest.MapFS, before map[string]bool)
+	}{
+		{name: "list", scan: func(m *ConfigManager, state *sourceState, fsys fstest.MapFS, before map[string]bool) {
+			m.scanSource(state, false)
+		}},
+		//变化通知只检查通知中的文件
+		{name: "notify", scan: func(m *ConfigManager, state *sourceState, fsys fstest.MapFS, before map[string]bool) {
+			names := make(map[string]struct{})
+			for name := range fsys {
+				names[name] = struct{}{}
+			}
+			for name := range before {
+				names[name] = struct{}{}
+			}
+			m.checkNames(state, names)
+		}},
+	}
+	for _, scan := range scans {
+		for _, tt := range tests {
+			t.Run(scan.name+"/"+tt.name, func(t *testing.T) {
+				fsys := fstest.MapFS{
+					"p/a.json": file(`{"N":1}`, t0),
+					"p/b.bin":  file("bin", t0),
+				}
+				m := newTestManager(fsys)
+				state := m.sources[0]
+				m.scanSource(state, true)
+				if len(m.dirty) > 0 {
+					t.Fatalf("dirty after init: %v", m.dirty)
+				}
+				before := make(map[string]bool)
+				for name := range fsys {
+					before[name] = true
+				}
+				tt.change(fsys)
+				scan.scan(m, state, fsys, before)
+				if got := slices.Sorted(maps.Keys(m.dirty)); !slices.Equal(got, tt.want) {
+					t.Errorf("dirty %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+// testClearConfig 文件删除时发布空数据的表
+type testClearConfig string
+
+func (c testClearConfig) GetFileName() string {
+	return string(c)
+}
+
+func (c testClearConfig) NewResult() interface{} {
+	return new(testClearData)
+}
+
+type testClearData struct {
+	N int
+}
+
+func (d *testClearData) ClearOnDelete() bool {
+	return true
+}
+
+func TestReloadDeleted(t *testing.T) {
+	fsys := fstest.MapFS{"keep.json": testFile(1), "clear.json": testFile(1)}
+	overlay := fstest.MapFS{"overlay.json": testFile(2)}
+	m := newTestManager(fsys, "keep.json")
+	m.Register(testClearConfig("clear.json"))
+	m.overlays = []Source{NewFSSource(overlay)}
+	m.sources = append(m.sources, &sourceState{source: m.overlays[0], files: make(map[string]SourceFile)})
+	fsys["overlay.json"] = testFile(1)
+	m.Register(testConfig("overlay.json"))
+	if err := m.LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+	m.updateScanDetail(true)
+	events := make(map[string]ReloadEvent)
+	m.Subscribe("", func(event ReloadEvent) {
+		events[event.FileName] = event
+	})
+
+	//基础数据中删除，overlay中还有的表重新加载
+	delete(fsys, "keep.json")
+	delete(fsys, "clear.json")
+	delete(fsys, "overlay.json")
+	m.updateScanDetail(false)
+	m.reloadDirty()
+	gen := m.Current()
+
+	keep := events["keep.json"]
+	if !keep.Deleted || keep.Err != nil || keep.New != nil || keep.Old.(*testData).N != 1 {
+		t.Errorf("keep event %+v", keep)
+	}
+	if got := testValue(gen, "keep.json"); got != 1 {
+		t.Errorf("keep %d, want old data", got)
+	}
+	clear := events["clear.json"]
+	if !clear.Deleted || clear.Err != nil || clear.New == nil || clear.New.(*testClearData).N != 0 {
+		t.Errorf("clear event %+v", clear)
+	}
+	if v, _ := gen.Get("clear.json"); v != clear.New {
+		t.Errorf("clear data %v, want published empty data", v)
+	}
+	if ev := events["overlay.json"]; ev.Deleted || ev.Err != nil || ev.New.(*testData).N != 2 {
+		t.Errorf("overlay event %+v", ev)
+	}
+
+	//重新添加时重新加载
+	events = make(map[string]ReloadEvent)
+	fsys["keep.json"] = testFile(3)
+	m.updateScanDetail(false)
+	m.reloadDirty()
+	if ev := events["keep.json"]; ev.Deleted || ev.Err != nil || ev.New.(*testData).N != 3 {
+		t.Errorf("re-added event %+v", ev)
+	}
+	if len(events) != 1 {
+		t.Errorf("events %v", slices.Sorted(maps.Keys(events)))
+	}
+}
+
+// watchTestSource 支持变化通知的数据来源，由测试触发通知
+type watchTestSource struct {
+	*FSSource
+	mu      sync.Mutex
+	changed func(names []string)
+	watches int
+}
+
+func (s *watchTestSource) Watch(changed func(names []string)) (func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.changed = changed
+	s.watches++
+	return func() {}, nil
+}
+
+func (s *watchTestSource) notify(names ...string) {
+	s.mu.Lock()
+	changed := s.changed
+	s.mu.Unlock()
+	changed(names)
+}
+
+// startTestWatch 注册fileNames中的表，加载后开始监听，返回重新加载的事件
+func startTestWatch(t *testing.T, watch WatchConfig, source Source, fileNames ...string) (*ConfigManager, <-chan ReloadEvent) {
+	t.Helper()
+	m := newConfigManager()
+	m.SetWatchConfig(watch)
+	for _, fileName := range fileNames {
+		m.Register(testConfig(fileName))
+	}
+	m.StartSource(source)
+	if err := m.LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan ReloadEvent, 16)
+	m.Subscribe("", func(event ReloadEvent) {
+		events <- event
+	})
+	return m, events
+}
+
+func waitEvent(t *testing.T, events <-chan ReloadEvent) ReloadEvent {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload event")
+		return ReloadEvent{}
+	}
+}
+
+func TestWatchDebounce(t *testing.T) {
+	const debounce = 100 * time.Millisecond
+	fsys := &lockedFS{fsys: fstest.MapFS{"a.json": testFile(0), "b.json": testFile(0)}}
+	src := &watchTestSource{FSSource: NewFSSource(fsys)}
+	m, events := startTestWatch(t, WatchConfig{Debounce: debounce, PollInterval: time.Hour}, src, "a.json", "b.json")
+	if src.watches != 1 {
+		t.Fatalf("watches %d, want 1", src.watches)
+	}
+	version := m.Current().Version
+
+	//保存时多次写入，每次通知都推迟重新加载，最后只加载一次
+	var last time.Time
+	for i := 1; i <= 5; i++ {
+		fsys.set("a.json", fmt.Sprintf(`{"N":%d}`, i))
+		last = time.Now()
+		src.notify("a.json")
+		time.Sleep(debounce / 5)
+	}
+	event := waitEvent(t, events)
+	if elapsed := time.Since(last); elapsed < debounce {
+		t.Errorf("reloaded %v after last change, want >= %v", elapsed, debounce)
+	}
+	if event.FileName != "a.json" || event.Err != nil || event.New.(*testData).N != 5 {
+		t.Errorf("event %+v", event)
+	}
+	select {
+	case event := <-events:
+		t.Errorf("unexpected event %+v", event)
+	case <-time.After(3 * debounce):
+	}
+	if got := m.Current().Version; got != version+1 {
+		t.Errorf("version %d, want %d", got, version+1)
+	}
+
+	//合并到同一批的表在同一个版本中发布，内容没变的文件不重新加载
+	fsys.set("a.json", `{"N":6}`)
+	fsys.set("b.json", `{"N":7}`)
+	src.notify("a.json")
+	src.notify("b.json", "a.json")
+	src.notify("b.json")
+	got := map[string]int{}
+	for range 2 {
+		event := waitEvent(t, events)
+		got[event.FileName] = event.New.(*testData).N
+	}
+	if got["a.json"] != 6 || got["b.json"] != 7 {
+		t.Errorf("events %v", got)
+	}
+	if got := m.Current().Version; got != version+2 {
+		t.Errorf("version %d, want %d", got, version+2)
+	}
+	src.notify("a.json", "b.json")
+	select {
+	case event := <-events:
+		t.Errorf("unexpected event for unchanged file %+v", event)
+	case <-time.After(3 * debounce):
+	}
+}
+
+func TestWatchPollFallback(t *testing.T) {
+	tests := []struct {
+		name   string
+		source func(fsys *lockedFS) Source
+		watch  WatchConfig
+	}{
+		//FSSource不支持变化通知，回退到轮询
+		{name: "not supported", source: func(fsys *lockedFS) Source { return NewFSSource(fsys) }},
+		//强制轮询时不使用变化通知
+		{name: "poll", source: func(fsys *lockedFS) Source {
+			return &watchTestSource{FSSource: NewFSSource(fsys)}
+		}, watch: WatchConfig{Poll: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := &lockedFS{fsys: fstest.MapFS{"a.json": testFile(0)}}
+			src := tt.source(fsys)
+			tt.watch.PollInterval = 20 * time.Millisecond
+			tt.watch.Debounce = time.Hour
+			m, events := startTestWatch(t, tt.watch, src, "a.json")
+			if !m.sources[0].polled {
+				t.Error("source not polled")
+			}
+			if src, ok := src.(*watchTestSource); ok && src.watches != 0 {
+				t.Errorf("watches %d, want 0", src.watches)
+			}
+
+			fsys.set("a.json", `{"N":1}`)
+			if event := waitEvent(t, events); event.FileName != "a.json" || event.Err != nil || event.New.(*testData).N != 1 {
+				t.Errorf("event %+v", event)
+			}
+			fsys.set("a.json", "")
+			if event := waitEvent(t, events); event.FileName != "a.json" || !event.Deleted {
+				t.Errorf("delete event %+v", event)
+			}
+		})
+	}
+}